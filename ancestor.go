@@ -20,6 +20,9 @@ type AncestorPerson struct {
 	Details []string
 	Father  *AncestorPerson
 	Mother  *AncestorPerson
+	Sex     Sex // Sex is the sex of the person. When known, the blurb is widened if needed to leave room for a symbol after the name, whether or not SVGOptions.SexSymbols is set, so that the layout does not depend on how it is drawn.
+
+	Meta map[string]string // Meta is arbitrary data about the person, such as a record id or URL, that is carried through to the blurb for use by custom renderers.
 }
 
 // AncestorLayoutOptions defines various layout parameters for rendering the ancestor chart.
@@ -34,12 +37,24 @@ type AncestorLayoutOptions struct {
 
 	HookLength Pixel // the length of the line drawn from the parent or a child to the vertical line that joins them
 
-	TitleStyle   TextStyle // TitleStyle is the style of the font to use for the title of the chart.
-	NoteStyle    TextStyle // NoteStyle is the style of the font to use for the notes of the chart.
+	TitleStyle TextStyle // TitleStyle is the style of the font to use for the title of the chart.
+	NoteStyle  TextStyle // NoteStyle is the style of the font to use for the notes of the chart.
+
+	TitleWrapWidth Pixel // TitleWrapWidth is the maximum width of the title and notes before wrapping to a new line. Zero wraps them to the width of the chart.
+
 	HeadingStyle TextStyle // HeadingStyle is the style of the font to use for the first line of each blurb.
 	DetailStyle  TextStyle // DetailStyle is the style of the font to use for the subsequent lines of each blurb after the first.
 
 	DetailWrapWidth Pixel // DetailWrapWidth is the maximum width of detail text before wrapping to a new line.
+
+	KeepEmptyDetails bool // KeepEmptyDetails indicates whether empty detail lines should be kept as intentional spacing rather than dropped.
+
+	ShowUnknownAncestors bool      // ShowUnknownAncestors indicates whether placeholder blurbs should be shown for missing parents, up to the depth of the chart.
+	UnknownText          string    // UnknownText is the text to show in placeholder blurbs for unknown ancestors.
+	UnknownStyle         TextStyle // UnknownStyle is the style of the font to use for placeholder blurbs for unknown ancestors.
+
+	MaxColumnWidth Pixel     // MaxColumnWidth is the maximum width of a generation column, text is wrapped to fit. Zero means no maximum.
+	ColumnAlign    Alignment // ColumnAlign controls the horizontal alignment of blurbs within their generation column.
 }
 
 // DefaultAncestorLayoutOptions returns the default layout options for rendering the ancestor chart.
@@ -73,6 +88,13 @@ func DefaultAncestorLayoutOptions() *AncestorLayoutOptions {
 			Color:      "#000",
 		},
 
+		UnknownText: "Unknown",
+		UnknownStyle: TextStyle{
+			FontSize:   20,
+			LineHeight: 22,
+			Color:      "#999",
+		},
+
 		DetailWrapWidth: 18 * 16,
 	}
 }
@@ -89,9 +111,18 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 	l.notes = ch.Notes
 	l.blurbs = make(map[int]*Blurb)
 
+	if ch.Root == nil {
+		// an empty chart still occupies its margins and any title
+		titleHeight, titleWidth := l.wrapTitle(0, l.opts.TitleWrapWidth, l.opts.TitleStyle, l.opts.NoteStyle)
+		l.width = l.opts.Margin*2 + titleWidth
+		l.height = l.opts.Margin*2 + titleHeight
+		return l
+	}
+
 	// calculate the number of rows needed to fit all of the last generation
 	l.rows = 1
 	gens := ch.countGenerations(ch.Root)
+	l.gens = gens
 	for i := 1; i < gens; i++ {
 		l.rows *= 2
 	}
@@ -108,6 +139,7 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 	var gridHeight Pixel
 	var gridWidth Pixel
 	colWidths := make([]Pixel, len(l.grid))
+	emptyCols := make([]bool, len(l.grid)) // columns containing no blurbs with any text
 
 	for col := range l.grid {
 		pop := colPopulation(col)
@@ -125,6 +157,11 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 				largestBlurbWidth = b.Width
 			}
 		}
+		if largestBlurbWidth == 0 {
+			// skip columns for generations that are entirely missing so they don't leave a blank strip
+			emptyCols[col] = true
+			continue
+		}
 		colWidths[col] = largestBlurbWidth + l.opts.Hspace
 
 		// Give each blurb equal vertical space
@@ -157,15 +194,24 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 
 	lowestTopPos := Pixel(200000)
 	x := l.opts.Margin
+	colLefts := make([]Pixel, len(l.grid))
 	// number of divisions is 2^col (col 0 has entire vertical space, col 1 splits it in two, col 2 splits in four)
 	divisions := 1
 	for col := range l.grid {
+		colLefts[col] = x
 		spacing := gridHeight / Pixel(divisions)
 		for row, b := range l.grid[col] {
 			if b == nil {
 				continue
 			}
-			b.LeftPos = x
+			switch l.opts.ColumnAlign {
+			case AlignRight:
+				b.LeftPos = x + colWidths[col] - l.opts.Hspace - b.Width
+			case AlignCentre:
+				b.LeftPos = x + (colWidths[col]-l.opts.Hspace-b.Width)/2
+			default:
+				b.LeftPos = x
+			}
 
 			// centre the blurb in the division
 			y0 := l.opts.Margin + spacing*Pixel(row)
@@ -197,8 +243,10 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 		}
 	}
 
-	// Shift everything down to accomodate title
-	titleHeight, _ := titleDimensions(l.title, l.notes, l.opts.TitleStyle, l.opts.NoteStyle)
+	// Shift everything down to accomodate title, widening the chart for any line of the title that
+	// could not be wrapped
+	titleHeight, titleWidth := l.wrapTitle(l.width-l.opts.Margin*2, l.opts.TitleWrapWidth, l.opts.TitleStyle, l.opts.NoteStyle)
+	l.width = max(l.width, titleWidth+l.opts.Margin*2)
 
 	l.height += titleHeight + l.opts.Vspace*4
 	for col := range l.grid {
@@ -215,18 +263,19 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 		if col == 0 {
 			continue
 		}
-		for row, b := range l.grid[col] {
+		if emptyCols[col] {
+			continue
+		}
+		for _, b := range l.grid[col] {
 			if b == nil {
 				continue
 			}
-			var childIdx int
-			if row%2 == 0 {
-				// male
-				childIdx = row / 2
-			} else {
-				childIdx = (row - 1) / 2
+
+			// connect to the nearest descendant in a column that has not been skipped
+			childBlurb := b.LeftNeighbour
+			for childBlurb.Col > 0 && emptyCols[childBlurb.Col] {
+				childBlurb = childBlurb.LeftNeighbour
 			}
-			childBlurb := l.grid[col-1][childIdx]
 
 			// draw hook projecting from left edge of parent
 			l.connectors = append(l.connectors, &Connector{
@@ -234,14 +283,14 @@ func (ch *AncestorChart) Layout(opts *AncestorLayoutOptions) *AncestorLayout {
 					// Start just to left of blurb
 					{X: b.LeftPos - l.opts.LineGap, Y: b.SideHookY()},
 
-					// Move left by HookLength
-					{X: b.LeftPos - l.opts.LineGap - l.opts.HookLength, Y: b.SideHookY()},
+					// Move left to HookLength from the column edge
+					{X: colLefts[col] - l.opts.LineGap - l.opts.HookLength, Y: b.SideHookY()},
 
 					// Move vertically to hook of child
-					{X: b.LeftPos - l.opts.LineGap - l.opts.HookLength, Y: childBlurb.SideHookY()},
+					{X: colLefts[col] - l.opts.LineGap - l.opts.HookLength, Y: childBlurb.SideHookY()},
 
 					// Move left by HookLength
-					{X: b.LeftPos - l.opts.LineGap - l.opts.HookLength - l.opts.Hspace, Y: childBlurb.SideHookY()},
+					{X: colLefts[col] - l.opts.LineGap - l.opts.HookLength - l.opts.Hspace, Y: childBlurb.SideHookY()},
 				},
 			})
 
@@ -272,14 +321,16 @@ func (ch *AncestorChart) countGenerations(p *AncestorPerson) int {
 
 // AncestorLayout represents the layout of an ancestor chart, including dimensions and layout options.
 type AncestorLayout struct {
+	chartText
+
 	opts       AncestorLayoutOptions
 	width      Pixel
 	height     Pixel
-	title      string
-	notes      []string
 	blurbs     map[int]*Blurb
 	grid       [][]*Blurb // col, row
 	rows       int
+	gens       int // number of generations in the chart
+	unknowns   int // number of placeholder blurbs added for unknown ancestors
 	connectors []*Connector
 }
 
@@ -300,17 +351,14 @@ func (l *AncestorLayout) Title() TextElement {
 	}
 }
 
-// Notes returns the notes elements of the layout.
+// Notes returns the notes elements of the layout, wrapped to fit the width of the chart.
 func (l *AncestorLayout) Notes() []TextElement {
-	tes := make([]TextElement, len(l.notes))
+	return textElements(l.noteLines, l.opts.NoteStyle)
+}
 
-	for i := range l.notes {
-		tes[i] = TextElement{
-			Text:  l.notes[i],
-			Style: l.opts.NoteStyle,
-		}
-	}
-	return tes
+// TitleLines returns the lines of the title of the layout once wrapped to fit the width of the chart.
+func (l *AncestorLayout) TitleLines() []TextElement {
+	return textElements(l.titleLines, l.opts.TitleStyle)
 }
 
 // Blurbs returns all the blurbs in the layout.
@@ -333,6 +381,8 @@ func (l *AncestorLayout) Debug() bool { return l.opts.Debug }
 // addPerson adds a person and their parents to the layout at the specified column and row.
 func (l *AncestorLayout) addPerson(p *AncestorPerson, col int, row int, child *Blurb) *Blurb {
 	b := l.newBlurb(p.ID, p.Details, col, row, child)
+	b.Meta = p.Meta
+	b.setSex(p.Sex)
 
 	for len(l.grid) <= col {
 		l.grid = append(l.grid, make([]*Blurb, colPopulation(len(l.grid)+1)))
@@ -343,19 +393,39 @@ func (l *AncestorLayout) addPerson(p *AncestorPerson, col int, row int, child *B
 	// father goes on next column, previous row
 	if p.Father != nil {
 		l.addPerson(p.Father, col+1, (row * 2), b)
+	} else if l.opts.ShowUnknownAncestors && col+1 < l.gens {
+		l.addUnknown(col+1, (row * 2), b)
 	}
 
 	// mother goes on next column, next row
 	if p.Mother != nil {
 		l.addPerson(p.Mother, col+1, (row*2)+1, b)
+	} else if l.opts.ShowUnknownAncestors && col+1 < l.gens {
+		l.addUnknown(col+1, (row*2)+1, b)
 	}
 
 	return b
 }
 
+// addUnknown adds a placeholder for an unknown ancestor, and its parents, to the layout at the
+// specified column and row. Placeholders are assigned negative ids.
+func (l *AncestorLayout) addUnknown(col int, row int, child *Blurb) *Blurb {
+	l.unknowns++
+	return l.addPerson(&AncestorPerson{
+		ID:      -l.unknowns,
+		Details: []string{l.opts.UnknownText},
+	}, col, row, child)
+}
+
 // newBlurb creates a new blurb for the given person at the specified column and row.
 func (l *AncestorLayout) newBlurb(id int, texts []string, col int, row int, child *Blurb) *Blurb {
 	// texts = l.wrapTexts(texts)
+	headingStyle := l.opts.HeadingStyle
+	if id < 0 {
+		// placeholder for an unknown ancestor
+		headingStyle = l.opts.UnknownStyle
+	}
+
 	b := &Blurb{
 		ID:                  id,
 		Col:                 col,
@@ -364,7 +434,7 @@ func (l *AncestorLayout) newBlurb(id int, texts []string, col int, row int, chil
 
 		HeadingTexts: TextSection{
 			Lines: []string{},
-			Style: l.opts.HeadingStyle,
+			Style: headingStyle,
 		},
 		DetailTexts: TextSection{
 			Lines: []string{},
@@ -375,18 +445,36 @@ func (l *AncestorLayout) newBlurb(id int, texts []string, col int, row int, chil
 		LeftNeighbour:  child,
 	}
 
+	// details wrap at the narrower of the two widths, ignoring either when it is not set
+	detailWrapWidth := l.opts.DetailWrapWidth
+	if l.opts.MaxColumnWidth > 0 && (detailWrapWidth <= 0 || l.opts.MaxColumnWidth < detailWrapWidth) {
+		detailWrapWidth = l.opts.MaxColumnWidth
+	}
+
 	if len(texts) > 0 {
-		b.HeadingTexts.Lines = append(b.HeadingTexts.Lines, texts[0])
-		b.Height = b.HeadingTexts.Style.LineHeight
-		b.Width = textWidth([]rune(b.HeadingTexts.Lines[0]), b.HeadingTexts.Style.FontSize)
+		if l.opts.MaxColumnWidth > 0 {
+			b.HeadingTexts.Lines = wrapText(texts[:1], l.opts.MaxColumnWidth, headingStyle)
+		} else {
+			b.HeadingTexts.Lines = append(b.HeadingTexts.Lines, texts[0])
+		}
+		b.Height = b.HeadingTexts.Style.LineHeight * Pixel(len(b.HeadingTexts.Lines))
+		for i := range b.HeadingTexts.Lines {
+			wl := b.HeadingTexts.Style.width(b.HeadingTexts.Lines[i])
+			if wl > b.Width {
+				b.Width = wl
+			}
+		}
 
-		if len(texts) > 1 {
+		if len(texts) > 1 && !l.opts.KeepEmptyDetails {
+			texts = append(texts[:1:1], dropEmptyLines(texts[1:])...)
+		}
 
-			b.DetailTexts.Lines = wrapText(texts[1:], l.opts.DetailWrapWidth, l.opts.DetailStyle.FontSize)
+		if len(texts) > 1 {
+			b.DetailTexts.Lines = wrapText(texts[1:], detailWrapWidth, l.opts.DetailStyle)
 			b.Height += b.DetailTexts.Style.LineHeight * Pixel(len(b.DetailTexts.Lines))
 
 			for i := range b.DetailTexts.Lines {
-				wl := textWidth([]rune(b.DetailTexts.Lines[i]), b.DetailTexts.Style.FontSize)
+				wl := b.DetailTexts.Style.width(b.DetailTexts.Lines[i])
 				if wl > b.Width {
 					b.Width = wl
 				}
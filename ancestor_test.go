@@ -0,0 +1,327 @@
+package gtree
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var exampleAncestorChart = &AncestorChart{
+	Title: "Example Ancestor Chart",
+	Root: &AncestorPerson{
+		ID:      1,
+		Details: []string{"Person Smith", "b. 25 Oct 1850", "d. 12 Dec 1914"},
+		Father: &AncestorPerson{
+			ID:      2,
+			Details: []string{"Father Smith", "b. 25 Oct 1822", "d. 1 Mar 1868"},
+			Father: &AncestorPerson{
+				ID:      3,
+				Details: []string{"Grandfather Smith", "b. 6 Jan 1799", "d. 27 Sep 1860"},
+			},
+			Mother: &AncestorPerson{
+				ID:      4,
+				Details: []string{"Grandmother Purcell", "b. 12 Oct 1800", "d. 19 Jun 1840"},
+				Father: &AncestorPerson{
+					ID:      5,
+					Details: []string{"Great Grandfather Purcell", "b. 25 May 1777"},
+				},
+			},
+		},
+		Mother: &AncestorPerson{
+			ID:      6,
+			Details: []string{"Mother Brown", "b. 25 Oct 1828", "d. 9 Feb 1890"},
+			Father: &AncestorPerson{
+				ID:      7,
+				Details: []string{"Father Brown", "b. 19 Feb 1800", "d. 11 Oct 1858"},
+			},
+			Mother: &AncestorPerson{
+				ID:      8,
+				Details: []string{"Mother Brown", "b. 14 Jan 1806", "d. 4 Dec 1880"},
+			},
+		},
+	},
+}
+
+func TestAncestorLayoutShowUnknownAncestors(t *testing.T) {
+	opts := DefaultAncestorLayoutOptions()
+
+	l := exampleAncestorChart.Layout(opts)
+	if got, want := len(l.blurbs), 8; got != want {
+		t.Fatalf("got %d blurbs without placeholders, wanted %d", got, want)
+	}
+
+	opts.ShowUnknownAncestors = true
+	l = exampleAncestorChart.Layout(opts)
+
+	// four generations give a complete grid of 1+2+4+8 blurbs
+	if got, want := len(l.blurbs), 15; got != want {
+		t.Fatalf("got %d blurbs with placeholders, wanted %d", got, want)
+	}
+
+	for col := range l.grid {
+		for row := 0; row < colPopulation(col); row++ {
+			b := l.grid[col][row]
+			if b == nil {
+				t.Errorf("grid col %d row %d: missing blurb", col, row)
+				continue
+			}
+			if b.ID < 0 {
+				if b.HeadingTexts.Lines[0] != opts.UnknownText {
+					t.Errorf("grid col %d row %d: got placeholder text %q, wanted %q", col, row, b.HeadingTexts.Lines[0], opts.UnknownText)
+				}
+				if b.HeadingTexts.Style != opts.UnknownStyle {
+					t.Errorf("grid col %d row %d: placeholder does not have unknown style", col, row)
+				}
+			}
+		}
+	}
+
+	// every blurb apart from the root is joined to its child by a connector
+	if got, want := len(l.connectors), 14; got != want {
+		t.Errorf("got %d connectors, wanted %d", got, want)
+	}
+}
+
+func TestAncestorLayoutSkipsEmptyColumns(t *testing.T) {
+	// the parents are recorded only to link the grandparents so their generation is entirely missing
+	ch := &AncestorChart{
+		Root: &AncestorPerson{
+			ID:      1,
+			Details: []string{"Person Smith", "b. 25 Oct 1850"},
+			Father: &AncestorPerson{
+				ID: 2,
+				Father: &AncestorPerson{
+					ID:      3,
+					Details: []string{"Grandfather Smith", "b. 6 Jan 1799"},
+				},
+			},
+			Mother: &AncestorPerson{
+				ID: 4,
+				Mother: &AncestorPerson{
+					ID:      5,
+					Details: []string{"Grandmother Brown", "b. 14 Jan 1806"},
+				},
+			},
+		},
+	}
+
+	opts := DefaultAncestorLayoutOptions()
+	l := ch.Layout(opts)
+
+	root := l.blurbs[1]
+	grandWidth := max(l.blurbs[3].Width, l.blurbs[5].Width)
+	want := root.Width + opts.Hspace + grandWidth + opts.Hspace
+	if l.Width() != want {
+		t.Errorf("got width %d, wanted %d", l.Width(), want)
+	}
+
+	for _, id := range []int{3, 5} {
+		if got, want := l.blurbs[id].Left(), root.Left()+root.Width+opts.Hspace; got != want {
+			t.Errorf("blurb %d: got left %d, wanted %d", id, got, want)
+		}
+	}
+
+	// grandparents are joined directly to the root person
+	if got, want := len(l.connectors), 2; got != want {
+		t.Fatalf("got %d connectors, wanted %d", got, want)
+	}
+	for _, c := range l.connectors {
+		end := c.Points[len(c.Points)-1]
+		if end.Y != root.SideHookY() {
+			t.Errorf("got connector ending at y=%d, wanted %d", end.Y, root.SideHookY())
+		}
+	}
+}
+
+func TestAncestorsOf(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Mary", "SMITH"},
+			Details:  []string{"b. 1820"},
+			Sex:      Female,
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"John Brown"}},
+					Children: []*DescendantPerson{
+						{
+							ID:       3,
+							Headings: []string{"Tom Brown"},
+							Families: []*DescendantFamily{
+								{
+									Other: &DescendantPerson{ID: 4, Headings: []string{"Ann Jones"}},
+									Children: []*DescendantPerson{
+										{ID: 5, Headings: []string{"Sam Brown"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	got, err := ch.AncestorsOf(5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &AncestorChart{
+		Root: &AncestorPerson{
+			ID:      5,
+			Details: []string{"Sam Brown"},
+			Father: &AncestorPerson{
+				ID:      3,
+				Details: []string{"Tom Brown"},
+				Father:  &AncestorPerson{ID: 2, Details: []string{"John Brown"}},
+				Mother:  &AncestorPerson{ID: 1, Details: []string{"Mary SMITH", "b. 1820"}, Sex: Female},
+			},
+			Mother: &AncestorPerson{ID: 4, Details: []string{"Ann Jones"}},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("AncestorsOf() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = ch.AncestorsOf(4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Root.ID != 4 || got.Root.Father != nil || got.Root.Mother != nil {
+		t.Errorf("got %+v, wanted spouse with no known parents", got.Root)
+	}
+
+	if _, err := ch.AncestorsOf(99); err == nil {
+		t.Errorf("got no error for unknown id")
+	}
+}
+
+func TestAncestorLayoutTitleWrap(t *testing.T) {
+	ch := *exampleAncestorChart
+	natural := ch.Layout(nil)
+
+	ch.Title = strings.Repeat("An example ancestor chart with a very long title ", 6)
+	opts := DefaultAncestorLayoutOptions()
+	l := ch.Layout(opts)
+
+	if got, want := l.Width(), natural.Width(); got != want {
+		t.Errorf("got width %d, wanted %d", got, want)
+	}
+	titles := l.TitleLines()
+	if len(titles) < 2 {
+		t.Fatalf("got %d title lines, wanted title to be wrapped", len(titles))
+	}
+	if got, want := l.Height(), natural.Height()+opts.TitleStyle.LineHeight*Pixel(len(titles)-1); got != want {
+		t.Errorf("got height %d, wanted %d", got, want)
+	}
+}
+
+func TestAncestorColumnAlign(t *testing.T) {
+	// the elbow of each connector is fixed by the column so only its first point moves with the blurb
+	elbows := func(l *AncestorLayout) [][]Point {
+		var out [][]Point
+		for _, c := range l.connectors {
+			out = append(out, c.Points[1:])
+		}
+		return out
+	}
+	natural := exampleAncestorChart.Layout(nil)
+
+	for name, align := range map[string]Alignment{"left": AlignLeft, "centre": AlignCentre, "right": AlignRight} {
+		t.Run(name, func(t *testing.T) {
+			opts := DefaultAncestorLayoutOptions()
+			opts.ColumnAlign = align
+			l := exampleAncestorChart.Layout(opts)
+
+			// each column spans from the left to the right of its widest blurb
+			colLeft, colRight := make(map[int]Pixel), make(map[int]Pixel)
+			for _, b := range l.blurbs {
+				if _, ok := colLeft[b.Col]; !ok {
+					colLeft[b.Col], colRight[b.Col] = b.Left(), b.Right()
+				}
+				colLeft[b.Col], colRight[b.Col] = min(colLeft[b.Col], b.Left()), max(colRight[b.Col], b.Right())
+			}
+
+			for _, b := range l.blurbs {
+				var want Pixel
+				switch align {
+				case AlignLeft:
+					want = colLeft[b.Col]
+				case AlignCentre:
+					want = colLeft[b.Col] + (colRight[b.Col]-colLeft[b.Col]-b.Width)/2
+				case AlignRight:
+					want = colRight[b.Col] - b.Width
+				}
+				if b.Left() != want {
+					t.Errorf("blurb %d: got left %d, wanted %d", b.ID, b.Left(), want)
+				}
+				if b.Col == 0 {
+					continue
+				}
+
+				// the connector from the blurb starts just to its left and turns at the edge of the column
+				var found bool
+				for _, c := range l.connectors {
+					if c.Points[0] != (Point{X: b.Left() - opts.LineGap, Y: b.SideHookY()}) {
+						continue
+					}
+					found = true
+					if got, want := c.Points[1].X, colLeft[b.Col]-opts.LineGap-opts.HookLength; got != want {
+						t.Errorf("blurb %d: got connector elbow at %d, wanted %d", b.ID, got, want)
+					}
+					if got, want := c.Points[len(c.Points)-1].Y, b.LeftNeighbour.SideHookY(); got != want {
+						t.Errorf("blurb %d: got connector ending at y %d, wanted the child hook %d", b.ID, got, want)
+					}
+				}
+				if !found {
+					t.Errorf("blurb %d: no connector starts at its hook", b.ID)
+				}
+			}
+
+			if diff := cmp.Diff(elbows(natural), elbows(l)); diff != "" {
+				t.Errorf("connector elbows mismatch (-left +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAncestorMaxColumnWidth(t *testing.T) {
+	natural := exampleAncestorChart.Layout(nil)
+	opts := DefaultAncestorLayoutOptions()
+	opts.MaxColumnWidth = 150
+	l := exampleAncestorChart.Layout(opts)
+
+	var wrapped bool
+	for _, b := range l.blurbs {
+		if b.Width > opts.MaxColumnWidth {
+			t.Errorf("blurb %d: got width %d, wanted at most %d", b.ID, b.Width, opts.MaxColumnWidth)
+		}
+		if len(b.HeadingTexts.Lines) > 1 {
+			wrapped = true
+			if got, want := strings.Join(b.HeadingTexts.Lines, " "), natural.blurbs[b.ID].HeadingTexts.Lines[0]; got != want {
+				t.Errorf("blurb %d: got wrapped heading %q, wanted %q", b.ID, got, want)
+			}
+		}
+	}
+	if !wrapped {
+		t.Errorf("no heading was wrapped, wanted the longest names wrapped at %d", opts.MaxColumnWidth)
+	}
+	if l.Width() >= natural.Width() {
+		t.Errorf("got width %d, wanted less than the natural width %d", l.Width(), natural.Width())
+	}
+	// details are still wrapped to the column when no detail wrap width is set
+	opts.DetailWrapWidth = 0
+	l = exampleAncestorChart.Layout(opts)
+	for _, b := range l.blurbs {
+		if b.Width > opts.MaxColumnWidth {
+			t.Errorf("blurb %d: got width %d without a detail wrap width, wanted at most %d", b.ID, b.Width, opts.MaxColumnWidth)
+		}
+		for _, line := range b.DetailTexts.Lines {
+			if line == "" {
+				t.Errorf("blurb %d: got empty detail line without a detail wrap width", b.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package gtree
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSV writes a tabular representation of the people in a descendant chart to w. A header row is
+// written first followed by one row per person in the order they are encountered in the chart.
+//
+// The columns are:
+// - id: the id of the person
+// - headings: the heading lines of the person, joined by spaces
+// - details: the detail lines of the person, joined by semicolons
+// - parent_id: the id of the parent through whom the person descends, empty for the root person and spouses
+// - other_parent_id: the id of the parent's partner in the family the person belongs to, if known
+// - spouse_of: the id of the person that this person is a spouse of, empty for people who descend from the root
+// - generation: the generation number of the person, starting at 1 for the root person. Spouses share the generation of their partner.
+func CSV(ch *DescendantChart, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "headings", "details", "parent_id", "other_parent_id", "spouse_of", "generation"}); err != nil {
+		return err
+	}
+
+	if ch.Root != nil {
+		if err := csvPerson(cw, ch.Root, nil, nil, nil, 1); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvPerson writes a row for a person followed by rows for their spouses and children.
+func csvPerson(cw *csv.Writer, p *DescendantPerson, parent, otherParent, spouseOf *DescendantPerson, generation int) error {
+	if err := cw.Write([]string{
+		strconv.Itoa(p.ID),
+		strings.Join(p.Headings, " "),
+		strings.Join(p.Details, "; "),
+		csvID(parent),
+		csvID(otherParent),
+		csvID(spouseOf),
+		strconv.Itoa(generation),
+	}); err != nil {
+		return err
+	}
+
+	for _, f := range p.Families {
+		if f.Other != nil {
+			if err := csvPerson(cw, f.Other, nil, nil, p, generation); err != nil {
+				return err
+			}
+		}
+		for _, c := range f.Children {
+			if err := csvPerson(cw, c, p, f.Other, nil, generation+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// csvID returns the id of the person as a string, or an empty string if p is nil.
+func csvID(p *DescendantPerson) string {
+	if p == nil {
+		return ""
+	}
+	return strconv.Itoa(p.ID)
+}
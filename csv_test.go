@@ -0,0 +1,55 @@
+package gtree
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Details:  []string{"b. 1819", "d. 1901"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{
+						ID:       2,
+						Headings: []string{"B. Green"},
+					},
+					Children: []*DescendantPerson{
+						{
+							ID:       3,
+							Headings: []string{"C. Brown"},
+						},
+					},
+				},
+				{
+					Children: []*DescendantPerson{
+						{
+							ID:       4,
+							Headings: []string{"D. Brown", "Jnr"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	buf := new(strings.Builder)
+	if err := CSV(ch, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := lines(
+		"id,headings,details,parent_id,other_parent_id,spouse_of,generation",
+		"1,A. Brown,b. 1819; d. 1901,,,,1",
+		"2,B. Green,,,,1,1",
+		"3,C. Brown,,1,2,,2",
+		"4,D. Brown Jnr,,1,,,2",
+	) + "\n"
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwanted:\n%s", got, want)
+	}
+}
@@ -0,0 +1,240 @@
+package gtree
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DateQualifier describes how a date relates to the day on which an event happened.
+type DateQualifier int
+
+const (
+	DateExact     DateQualifier = iota // DateExact indicates that the event happened on the date, to the precision given.
+	DateAbout                          // DateAbout indicates that the event happened around the date.
+	DateEstimated                      // DateEstimated indicates that the date has been estimated, such as from an age at a later event.
+	DateBefore                         // DateBefore indicates that the event happened before the date.
+	DateAfter                          // DateAfter indicates that the event happened after the date.
+	DateBetween                        // DateBetween indicates that the event happened between the start and end of a range.
+)
+
+// CalendarDate is a day, month or year in the Gregorian calendar. Month and Day are zero when the
+// date is only known to the precision of a year or month.
+type CalendarDate struct {
+	Year  int
+	Month int // Month is the month of the year from 1 to 12, or zero if not known.
+	Day   int // Day is the day of the month from 1 to 31, or zero if not known.
+}
+
+// Date is a normalised date, as found in the detail text of a person or family.
+type Date struct {
+	Qualifier DateQualifier
+	Start     CalendarDate // Start is the date, or the start of the range for DateBetween.
+	End       CalendarDate // End is the end of the range for DateBetween and is otherwise zero.
+}
+
+var monthAbbrevs = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// dateQualifiers maps the words that may introduce a date to the qualifier they denote.
+var dateQualifiers = map[string]DateQualifier{
+	"abt": DateAbout, "about": DateAbout, "c": DateAbout, "ca": DateAbout, "circa": DateAbout,
+	"est": DateEstimated, "estimated": DateEstimated,
+	"bef": DateBefore, "before": DateBefore,
+	"aft": DateAfter, "after": DateAfter,
+	"bet": DateBetween, "btw": DateBetween, "between": DateBetween,
+}
+
+// ParseDate parses a date such as "24 May 1819", "May 1819", "1819", "1867-05-11" or "1916-10",
+// optionally preceded by a qualifier such as "abt.", "c.", "estimated", "before" or "after". A range
+// is written as "between 1916-10-01 and 1916-12-31". Qualifiers and month names are not case
+// sensitive. It reports false if s is not a date in one of these forms.
+func ParseDate(s string) (Date, bool) {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(s), "."))
+	var d Date
+	if len(fields) > 0 {
+		if q, ok := dateQualifiers[strings.ToLower(strings.TrimSuffix(fields[0], "."))]; ok {
+			d.Qualifier = q
+			fields = fields[1:]
+		}
+	}
+
+	if d.Qualifier == DateBetween {
+		and := -1
+		for i, f := range fields {
+			if strings.EqualFold(f, "and") || f == "&" {
+				and = i
+				break
+			}
+		}
+		if and < 0 {
+			return Date{}, false
+		}
+		start, ok := parseCalendarDate(fields[:and])
+		if !ok {
+			return Date{}, false
+		}
+		end, ok := parseCalendarDate(fields[and+1:])
+		if !ok || end.Compare(start) < 0 {
+			return Date{}, false
+		}
+		d.Start, d.End = start, end
+		return d, true
+	}
+
+	start, ok := parseCalendarDate(fields)
+	if !ok {
+		return Date{}, false
+	}
+	d.Start = start
+	return d, true
+}
+
+// parseCalendarDate parses the fields of a date written as day, month and year or in the ISO
+// form year-month-day, where the day and month may be omitted.
+func parseCalendarDate(fields []string) (CalendarDate, bool) {
+	var cd CalendarDate
+	switch len(fields) {
+	case 1:
+		parts := strings.Split(fields[0], "-")
+		if len(parts) > 3 {
+			return CalendarDate{}, false
+		}
+		var ok bool
+		if cd.Year, ok = dateNumber(parts[0], 1, 9999); !ok {
+			return CalendarDate{}, false
+		}
+		if len(parts) > 1 {
+			if len(parts[1]) != 2 {
+				return CalendarDate{}, false
+			}
+			if cd.Month, ok = dateNumber(parts[1], 1, 12); !ok {
+				return CalendarDate{}, false
+			}
+		}
+		if len(parts) > 2 {
+			if len(parts[2]) != 2 {
+				return CalendarDate{}, false
+			}
+			if cd.Day, ok = dateNumber(parts[2], 1, 31); !ok {
+				return CalendarDate{}, false
+			}
+		}
+		return cd, true
+	case 2, 3:
+		var ok bool
+		if cd.Year, ok = dateNumber(fields[len(fields)-1], 1, 9999); !ok {
+			return CalendarDate{}, false
+		}
+		if cd.Month = monthNumber(fields[len(fields)-2]); cd.Month == 0 {
+			return CalendarDate{}, false
+		}
+		if len(fields) == 3 {
+			if cd.Day, ok = dateNumber(fields[0], 1, 31); !ok {
+				return CalendarDate{}, false
+			}
+		}
+		return cd, true
+	default:
+		return CalendarDate{}, false
+	}
+}
+
+// dateNumber parses s as a number between lo and hi inclusive.
+func dateNumber(s string, lo, hi int) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < lo || n > hi {
+		return 0, false
+	}
+	return n, true
+}
+
+// monthNumber returns the number of the month named or abbreviated by s, or zero if s is not the
+// name of a month.
+func monthNumber(s string) int {
+	s = strings.TrimSuffix(s, ".")
+	if len(s) < 3 {
+		return 0
+	}
+	for i, m := range monthAbbrevs {
+		if strings.EqualFold(s[:3], m) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Compare returns -1, 0 or +1 depending on whether cd is earlier than, the same as or later than
+// other. A date known only to the year or month is treated as the start of that year or month.
+func (cd CalendarDate) Compare(other CalendarDate) int {
+	for _, c := range [][2]int{{cd.Year, other.Year}, {cd.Month, other.Month}, {cd.Day, other.Day}} {
+		switch {
+		case c[0] < c[1]:
+			return -1
+		case c[0] > c[1]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// String formats the date as "24 May 1819", "May 1819" or "1819".
+func (cd CalendarDate) String() string {
+	s := strconv.Itoa(cd.Year)
+	if cd.Month >= 1 && cd.Month <= 12 {
+		s = monthAbbrevs[cd.Month-1] + " " + s
+		if cd.Day > 0 {
+			s = strconv.Itoa(cd.Day) + " " + s
+		}
+	}
+	return s
+}
+
+// gedcom formats the date in the form used by GEDCOM, such as "24 MAY 1819".
+func (cd CalendarDate) gedcom() string {
+	return strings.ToUpper(cd.String())
+}
+
+// Compare returns -1, 0 or +1 depending on whether d is earlier than, the same as or later than
+// other, comparing the start of each date and then the end of any range. It may be used to sort
+// dates into order.
+func (d Date) Compare(other Date) int {
+	if c := d.Start.Compare(other.Start); c != 0 {
+		return c
+	}
+	return d.End.Compare(other.End)
+}
+
+// String formats the date for display, such as "c. 1839", "bef. 12 Mar 1871" or
+// "bet. Oct 1916 and Dec 1916".
+func (d Date) String() string {
+	switch d.Qualifier {
+	case DateAbout, DateEstimated:
+		return "c. " + d.Start.String()
+	case DateBefore:
+		return "bef. " + d.Start.String()
+	case DateAfter:
+		return "aft. " + d.Start.String()
+	case DateBetween:
+		return "bet. " + d.Start.String() + " and " + d.End.String()
+	default:
+		return d.Start.String()
+	}
+}
+
+// GEDCOM formats the date as a GEDCOM date value, such as "EST 1839" or
+// "BET 1 OCT 1916 AND 31 DEC 1916".
+func (d Date) GEDCOM() string {
+	switch d.Qualifier {
+	case DateAbout:
+		return "ABT " + d.Start.gedcom()
+	case DateEstimated:
+		return "EST " + d.Start.gedcom()
+	case DateBefore:
+		return "BEF " + d.Start.gedcom()
+	case DateAfter:
+		return "AFT " + d.Start.gedcom()
+	case DateBetween:
+		return "BET " + d.Start.gedcom() + " AND " + d.End.gedcom()
+	default:
+		return d.Start.gedcom()
+	}
+}
@@ -0,0 +1,120 @@
+package gtree
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseDate(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    Date
+		display string
+		gedcom  string
+	}{
+		{
+			in:      "1842",
+			want:    Date{Start: CalendarDate{Year: 1842}},
+			display: "1842",
+			gedcom:  "1842",
+		},
+		{
+			in:      "1867-05-11",
+			want:    Date{Start: CalendarDate{Year: 1867, Month: 5, Day: 11}},
+			display: "11 May 1867",
+			gedcom:  "11 MAY 1867",
+		},
+		{
+			in:      "1916-10",
+			want:    Date{Start: CalendarDate{Year: 1916, Month: 10}},
+			display: "Oct 1916",
+			gedcom:  "OCT 1916",
+		},
+		{
+			in:      "24 May 1819.",
+			want:    Date{Start: CalendarDate{Year: 1819, Month: 5, Day: 24}},
+			display: "24 May 1819",
+			gedcom:  "24 MAY 1819",
+		},
+		{
+			in:      "estimated 1839",
+			want:    Date{Qualifier: DateEstimated, Start: CalendarDate{Year: 1839}},
+			display: "c. 1839",
+			gedcom:  "EST 1839",
+		},
+		{
+			in:      "Abt. 1806",
+			want:    Date{Qualifier: DateAbout, Start: CalendarDate{Year: 1806}},
+			display: "c. 1806",
+			gedcom:  "ABT 1806",
+		},
+		{
+			in:      "abt 1875",
+			want:    Date{Qualifier: DateAbout, Start: CalendarDate{Year: 1875}},
+			display: "c. 1875",
+			gedcom:  "ABT 1875",
+		},
+		{
+			in:      "Bef. 1871",
+			want:    Date{Qualifier: DateBefore, Start: CalendarDate{Year: 1871}},
+			display: "bef. 1871",
+			gedcom:  "BEF 1871",
+		},
+		{
+			in:      "after 3 September 1901",
+			want:    Date{Qualifier: DateAfter, Start: CalendarDate{Year: 1901, Month: 9, Day: 3}},
+			display: "aft. 3 Sep 1901",
+			gedcom:  "AFT 3 SEP 1901",
+		},
+		{
+			in:      "between 1916-10-01 and 1916-12-31",
+			want:    Date{Qualifier: DateBetween, Start: CalendarDate{Year: 1916, Month: 10, Day: 1}, End: CalendarDate{Year: 1916, Month: 12, Day: 31}},
+			display: "bet. 1 Oct 1916 and 31 Dec 1916",
+			gedcom:  "BET 1 OCT 1916 AND 31 DEC 1916",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, ok := ParseDate(tc.in)
+		if !ok {
+			t.Errorf("ParseDate(%q): got no date", tc.in)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseDate(%q): got %+v, wanted %+v", tc.in, got, tc.want)
+		}
+		if s := got.String(); s != tc.display {
+			t.Errorf("ParseDate(%q): got display %q, wanted %q", tc.in, s, tc.display)
+		}
+		if s := got.GEDCOM(); s != tc.gedcom {
+			t.Errorf("ParseDate(%q): got GEDCOM %q, wanted %q", tc.in, s, tc.gedcom)
+		}
+	}
+
+	for _, in := range []string{"", "London", "abt", "1916-13-01", "1916-1-1", "between 1916 and", "between 1917 and 1916", "32 May 1819"} {
+		if d, ok := ParseDate(in); ok {
+			t.Errorf("ParseDate(%q): got %+v, wanted no date", in, d)
+		}
+	}
+}
+
+func TestDateCompare(t *testing.T) {
+	var dates []Date
+	for _, s := range []string{"1916-12-31", "abt 1839", "between 1916-10-01 and 1916-12-31", "Oct 1916", "bef. 1839"} {
+		d, ok := ParseDate(s)
+		if !ok {
+			t.Fatalf("ParseDate(%q): got no date", s)
+		}
+		dates = append(dates, d)
+	}
+	slices.SortStableFunc(dates, Date.Compare)
+
+	var got []string
+	for _, d := range dates {
+		got = append(got, d.String())
+	}
+	want := []string{"c. 1839", "bef. 1839", "Oct 1916", "bet. 1 Oct 1916 and 31 Dec 1916", "31 Dec 1916"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got order %q, wanted %q", got, want)
+	}
+}
@@ -3,6 +3,10 @@ package gtree
 import (
 	"fmt"
 	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strings"
 )
 
 // DescendantChart represents a chart of descendants, with the earliest ancestor (root person) at the top.
@@ -13,6 +17,8 @@ type DescendantChart struct {
 	Title string
 	Notes []string
 	Root  *DescendantPerson
+
+	Options *LayoutOptions // Options holds any layout options given in the source of the chart, such as by option directives in a descendant list, starting from DefaultLayoutOptions. Nil if the source gave none. It is not applied by Layout; callers pass it themselves.
 }
 
 // DescendantPerson represents an individual in the descendant chart, including their ID, details, and families.
@@ -22,41 +28,172 @@ type DescendantPerson struct {
 	Details  []string
 	Families []*DescendantFamily
 	Tags     []string
+	Sex      Sex      // Sex is the sex of the person. When known, the blurb is widened if needed to leave room for a symbol after the name, whether or not SVGOptions.SexSymbols is set, so that the layout does not depend on how it is drawn.
+	Notes    []string // Notes are footnotes or citations for the person, collected into a numbered list beneath the chart.
+
+	Relationship Relationship // Relationship is the relationship of the person to the parents of the family they belong to. It overrides the relationship of the family when not BirthRelationship.
+
+	LifeStatus    LifeStatus // LifeStatus records whether the person is known to be living or deceased.
+	DiedInInfancy bool       // DiedInInfancy indicates that the person died as an infant, such as when their age at death is given as 0.
+	MultipleBirth string     // MultipleBirth is a label shared by the children of a family born at the same birth, such as twins. Empty for a single birth.
+
+	Collapsed bool // Collapsed indicates that the families and descendants of the person should be omitted from the layout and summarised by a count of descendants.
+
+	Meta map[string]string // Meta is arbitrary data about the person, such as a record id or URL, that is carried through to the blurb for use by custom renderers.
+
+	DetailStyles []*TextStyle // DetailStyles overrides the style of individual detail lines, in the same order as Details, such as to show a birth in green and a death in grey. A nil or missing entry uses the DetailStyle of the layout, which also supplies any font size, line height or colour left unset.
 }
 
+// Relationship describes the relationship between a child and their parents. Relationships other
+// than BirthRelationship are drawn with dashed connectors.
+type Relationship int
+
+const (
+	BirthRelationship     Relationship = iota // BirthRelationship indicates that the child is the birth child of the parents.
+	AdoptedRelationship                       // AdoptedRelationship indicates that the child was adopted by the parents.
+	FosterRelationship                        // FosterRelationship indicates that the child was fostered by the parents.
+	UncertainRelationship                     // UncertainRelationship indicates that the parentage of the child is not certain.
+)
+
+// LifeStatus describes whether a person is known to be living or deceased.
+type LifeStatus int
+
+const (
+	LifeStatusUnknown  LifeStatus = iota // LifeStatusUnknown indicates that it is not known whether the person is living.
+	LifeStatusLiving                     // LifeStatusLiving indicates that the person is known to be living.
+	LifeStatusDeceased                   // LifeStatusDeceased indicates that the person is known to have died.
+)
+
 // DescendantFamily represents a family unit, including the spouse and their children.
 type DescendantFamily struct {
 	Other    *DescendantPerson
 	Details  []string
 	Children []*DescendantPerson
+
+	Relationship Relationship // Relationship is the relationship of the children to the parents, unless overridden by a child.
+
+	FamilyDrop Pixel // FamilyDrop overrides LayoutOptions.FamilyDrop for this family when non-zero.
+	ChildDrop  Pixel // ChildDrop overrides LayoutOptions.ChildDrop for this family when non-zero.
+
+	Link string // Link is the URL that the relationship marker and the lines joining the children to their parents link to, wrapped together in a single link when rendered, such as a script that expands or collapses the family. Empty adds no link.
 }
 
+// ConnectorRouter routes the connectors that join children to their parents in a descendant layout.
+// It is called once every blurb in the layout has been positioned.
+type ConnectorRouter interface {
+	// Route returns the connectors to draw in the layout. Each connector should list in Children the
+	// ids of the child blurbs whose path to their parents it forms part of so that paths may be
+	// highlighted. The connector that meets a child should come before any others in its path.
+	Route(l *DescendantLayout) []*Connector
+}
+
+// DNATag is the tag, written as "#dna" in the text format, that marks a person as having DNA test
+// results when the DNAMarkers layout option is set.
+const DNATag = "dna"
+
+// SpouseSide is the side of a person on which their spouses are placed in a descendant chart.
+type SpouseSide int
+
+const (
+	SpouseRight SpouseSide = iota // SpouseRight places each spouse to the right of the person, after the relationship marker.
+	SpouseLeft                    // SpouseLeft places each spouse to the left of the person, before the relationship marker.
+	SpouseBelow                   // SpouseBelow stacks each spouse beneath the person with the relationship marker between them, so a couple is no wider than its widest blurb. The children of every family hang from the foot of the stack, each family from its own point spread across the stack in the order of the families and joined at its own height.
+)
+
 // LayoutOptions defines various layout parameters for rendering the descendant chart.
 type LayoutOptions struct {
 	Debug      bool // Debug indicates whether to emit logging and debug information.
 	Iterations int  // Number of iterations of adjustment to run
 
-	Hspace     Pixel // Hspace is the horizontal spacing between blurbs within the same family.
-	LineWidth  Pixel // LineWidth is the width of the lines connecting blurbs.
-	Margin     Pixel // Margin is the margin added to the entire drawing.
-	FamilyDrop Pixel // FamilyDrop is the length of the line drawn from parents to the children group line.
-	ChildDrop  Pixel // ChildDrop is the length of the line drawn from the children group line to a child.
-	LineGap    Pixel // LineGap is the distance between a connecting line and any text.
+	Hspace       Pixel // Hspace is the horizontal spacing between blurbs within the same family.
+	ChildSpacing Pixel // ChildSpacing is the horizontal spacing between siblings. Zero is treated as Hspace.
+	FamilyGap    Pixel // FamilyGap is the minimum horizontal spacing between blurbs belonging to different families.
+	LineWidth    Pixel // LineWidth is the width of the lines connecting blurbs.
+	Margin       Pixel // Margin is the margin added to the entire drawing.
+	FamilyDrop   Pixel // FamilyDrop is the length of the line drawn from parents to the children group line.
+	ChildDrop    Pixel // ChildDrop is the length of the line drawn from the children group line to a child.
+	LineGap      Pixel // LineGap is the distance between a connecting line and any text.
+	RowGap       Pixel // RowGap is extra vertical space added between each row and the next, beneath the tallest blurb in the row.
+	FixedWidth   Pixel // FixedWidth is the width the layout should be fitted to by reducing spacing and wrapping text. Zero means the layout uses its natural width.
+
+	TitleStyle TextStyle // TitleStyle is the style of the font to use for the title of the chart.
+	NoteStyle  TextStyle // NoteStyle is the style of the font to use for the notes of the chart.
+
+	TitleWrapWidth Pixel // TitleWrapWidth is the maximum width of the title and notes before wrapping to a new line. Zero wraps them to the width of the chart.
 
-	TitleStyle   TextStyle // TitleStyle is the style of the font to use for the title of the chart.
-	NoteStyle    TextStyle // NoteStyle is the style of the font to use for the notes of the chart.
 	HeadingStyle TextStyle // HeadingStyle is the style of the font to use for the first line of each blurb.
 	DetailStyle  TextStyle // DetailStyle is the style of the font to use for the subsequent lines of each blurb after the first.
 
+	MarriageDetailStyle TextStyle // MarriageDetailStyle is the style of the font to use for the family details shown beneath the relationship marker.
+
+	FontScale float64 // FontScale multiplies the font size and line height of every style, and the widths that text is wrapped to, so that all text is enlarged or shrunk in proportion. Zero is treated as 1.
+
 	DetailWrapWidth Pixel // DetailWrapWidth is the maximum width of detail text before wrapping to a new line.
+
+	HeadingWrapWidth        Pixel // HeadingWrapWidth is the maximum width of heading text before wrapping to a new line. Zero leaves headings unwrapped.
+	MarriageDetailWrapWidth Pixel // MarriageDetailWrapWidth is the maximum width of the family details beneath a relationship marker before wrapping to a new line. Zero uses DetailWrapWidth.
+
+	KeepEmptyDetails bool // KeepEmptyDetails indicates whether empty detail lines should be kept as intentional spacing rather than dropped.
+
+	DetailAlign Alignment // DetailAlign is the horizontal alignment of the detail lines of each person within their blurb. Headings are always left aligned.
+
+	DetailColumns int // DetailColumns is the number of columns to arrange the detail lines of each person in, when the columns fit within DetailWrapWidth. Zero or one gives a single column.
+
+	CornerRadius Pixel // CornerRadius is the radius of the arc used to round corners in connecting lines. Zero gives square corners.
+
+	SnapConnectors bool // SnapConnectors indicates whether the connector joining a lone child to the relationship marker above it should drop straight onto the TopHookX of the child whenever the hook lies beneath the marker, rather than jogging aside by the few pixels left over from centring the marker on the child.
+
+	UnionMarker UnionMarker // UnionMarker is the mark placed between a person and each spouse. Shapes are sized to the heading font, with any family number shown above the family details.
+
+	FamilyOrderText func(n int, f *DescendantFamily) string // FamilyOrderText returns the text shown in the relationship marker of a person with more than one family to indicate the order of the family f, where n counts the families of the person from 1. Families hidden by HideChildlessFamilies are not counted. Nil shows the number in parentheses, such as "(2)". Empty text shows nothing.
+
+	OmitChildlessMarker bool // OmitChildlessMarker indicates whether the relationship marker should be left out for a family without children, joining the couple with a short line instead. The marker is kept for a childless family with details so that they are still shown beneath it.
+
+	SpouseSide SpouseSide // SpouseSide is the side of each person on which their spouses and relationship markers are placed. The families of a person placed on the left are ordered outwards from the person so the first is nearest.
+
+	StackSpouses bool // StackSpouses indicates whether the spouses of a person with more than one family should be packed closely together rather than spread over their children.
+
+	MinGeneration int // MinGeneration is the earliest generation to include in the layout, where the root person is generation 1. Zero includes all generations from the root.
+	MaxGeneration int // MaxGeneration is the latest generation to include in the layout. Zero includes all generations.
+
+	InfantDeathMarker string // InfantDeathMarker is the text added after the name of each person who died in infancy, such as "†". Empty adds nothing.
+
+	DNAMarkers bool // DNAMarkers indicates whether people tagged with DNATag should be marked with a dot after their name to show they have DNA test results.
+
+	HideChildlessFamilies bool // HideChildlessFamilies indicates whether families with no children should be omitted from the layout.
+
+	ConnectorColors []string // ConnectorColors is a palette of colors cycled through for the connectors joining the children of each family to their parents, so that each line of descent is easier to follow. Connectors shared by several children are drawn in black. Empty draws every connector in black.
+
+	SiblingBar bool // SiblingBar indicates whether the children of a family should hang from a single horizontal bar rather than each having their own connector to the parent.
+
+	CentreMarkers bool // CentreMarkers indicates whether each relationship marker should be aligned with the middle of its children so that the line of descent drops straight down from it. The children, or failing that the marker, are only moved if there is room to do so.
+
+	FocusID     int       // FocusID is the id of the person the chart is about, who is emphasised with FocusStyle and a border. Zero means no person is emphasised.
+	FocusStyle  TextStyle // FocusStyle is the style of the font to use for the headings of the focus person.
+	FocusMargin Pixel     // FocusMargin is the extra horizontal space reserved on either side of the focus person.
+
+	UniformRowHeight bool // UniformRowHeight indicates whether every row should be as tall as the tallest row in the chart, so that generations are evenly spaced. Otherwise each row is only as tall as its tallest blurb.
+
+	AlignChildrenTop bool // AlignChildrenTop indicates whether the first heading line of every blurb in a row should share a baseline when their heading styles have different line heights, such as beside the focus person. Blurbs with shorter heading lines are lowered to match.
+
+	ConnectorRouter ConnectorRouter // ConnectorRouter routes the connectors between blurbs. Nil uses DefaultConnectorRouter.
+
+	ShowGenerationLabels bool      // ShowGenerationLabels indicates whether each row should be labelled with its generation number in a gutter to the left of the chart.
+	GenerationLabelStyle TextStyle // GenerationLabelStyle is the style of the font to use for the generation labels.
+
+	Watermark      string    // Watermark is text drawn faintly across the middle of the chart, beneath everything else, such as a copyright notice. It does not affect the layout. Empty draws no watermark.
+	WatermarkStyle TextStyle // WatermarkStyle is the style of the font to use for the watermark. Its Opacity should be low so the chart remains legible.
 }
 
 // DefaultLayoutOptions returns the default layout options for rendering the descendant chart.
 func DefaultLayoutOptions() *LayoutOptions {
 	return &LayoutOptions{
 		Iterations:      30000,
+		FontScale:       1,
 		DetailWrapWidth: 18 * 16,
 		Hspace:          16,
+		ChildSpacing:    16,
+		FamilyGap:       48,
 		LineWidth:       2,
 		Margin:          16,
 		FamilyDrop:      48,
@@ -82,7 +219,186 @@ func DefaultLayoutOptions() *LayoutOptions {
 			LineHeight: 18,
 			Color:      "#000",
 		},
+		MarriageDetailStyle: TextStyle{
+			FontSize:   16,
+			LineHeight: 18,
+			Color:      "#000",
+		},
+		GenerationLabelStyle: TextStyle{
+			FontSize:   16,
+			LineHeight: 18,
+			Color:      "#666",
+		},
+		WatermarkStyle: TextStyle{
+			FontSize:   48,
+			LineHeight: 52,
+			Color:      "#000",
+			Opacity:    0.1,
+		},
+		FocusStyle: TextStyle{
+			FontSize:   24,
+			LineHeight: 26,
+			Color:      "#000",
+			Bold:       true,
+		},
+		FocusMargin: 8,
+	}
+}
+
+// AncestorsOf returns an ancestor chart for the person with the given id, using the parents recorded
+// in the descendant chart. Ancestors are included as far as the chart allows, which means the
+// ancestry of anyone who married into the family is not known. A parent is taken to be the mother
+// if their sex is female or the sex of their partner is male, otherwise they are taken to be the
+// father. An error is returned if no person in the chart has the id.
+func (ch *DescendantChart) AncestorsOf(id int) (*AncestorChart, error) {
+	type parents struct {
+		person *DescendantPerson
+		other  *DescendantPerson
+	}
+
+	// record the parents of every person in the chart
+	var target *DescendantPerson
+	parentsOf := make(map[*DescendantPerson]parents)
+	var walk func(p *DescendantPerson)
+	walk = func(p *DescendantPerson) {
+		if p.ID == id && target == nil {
+			target = p
+		}
+		for _, f := range p.Families {
+			if f.Other != nil && f.Other.ID == id && target == nil {
+				target = f.Other
+			}
+			for _, c := range f.Children {
+				parentsOf[c] = parents{person: p, other: f.Other}
+				walk(c)
+			}
+		}
+	}
+	if ch.Root != nil {
+		walk(ch.Root)
+	}
+	if target == nil {
+		return nil, fmt.Errorf("person with id %d not found", id)
+	}
+
+	var ancestor func(p *DescendantPerson) *AncestorPerson
+	ancestor = func(p *DescendantPerson) *AncestorPerson {
+		ap := &AncestorPerson{
+			ID:   p.ID,
+			Sex:  p.Sex,
+			Meta: p.Meta,
+		}
+		if len(p.Headings) > 0 {
+			ap.Details = append(ap.Details, strings.Join(p.Headings, " "))
+		}
+		ap.Details = append(ap.Details, p.Details...)
+
+		ps, ok := parentsOf[p]
+		if !ok {
+			return ap
+		}
+		father, mother := ps.person, ps.other
+		if ps.person.Sex == Female || (ps.other != nil && ps.other.Sex == Male) {
+			father, mother = ps.other, ps.person
+		}
+		if father != nil {
+			ap.Father = ancestor(father)
+		}
+		if mother != nil {
+			ap.Mother = ancestor(mother)
+		}
+		return ap
+	}
+
+	return &AncestorChart{
+		Root: ancestor(target),
+	}, nil
+}
+
+// Prune returns a new chart containing only the people for whom keep returns true, along with the
+// ancestors needed to connect them to the root person. A family is kept when the spouse in it is
+// kept or any of its children remain, so families left without children by pruning are removed.
+// The root person is always included unless no one in the chart is kept. The people and families
+// of the original chart are not modified.
+func (ch *DescendantChart) Prune(keep func(p *DescendantPerson) bool) *DescendantChart {
+	var prune func(p *DescendantPerson) *DescendantPerson
+	prune = func(p *DescendantPerson) *DescendantPerson {
+		var families []*DescendantFamily
+		for _, f := range p.Families {
+			var children []*DescendantPerson
+			for _, c := range f.Children {
+				if pc := prune(c); pc != nil {
+					children = append(children, pc)
+				}
+			}
+			if len(children) == 0 && (f.Other == nil || !keep(f.Other)) {
+				continue
+			}
+			pf := *f
+			pf.Children = children
+			families = append(families, &pf)
+		}
+		if len(families) == 0 && !keep(p) {
+			return nil
+		}
+		pp := *p
+		pp.Families = families
+		return &pp
+	}
+
+	pruned := &DescendantChart{
+		Title: ch.Title,
+		Notes: ch.Notes,
+	}
+	if ch.Root != nil {
+		pruned.Root = prune(ch.Root)
+	}
+	return pruned
+}
+
+// GenStat summarises one generation of a descendant chart.
+type GenStat struct {
+	Generation int // Generation is the generation number, starting at 1 for the root person.
+	People     int // People is the number of descendants in the generation, not including their spouses.
+	Deceased   int // Deceased is the number of those people known to have died.
+	Families   int // Families is the number of families those people formed.
+	Children   int // Children is the number of children in those families, who form the next generation.
+}
+
+// ChildrenPerFamily returns the average number of children in each family of the generation, or
+// zero if the generation formed no families.
+func (s GenStat) ChildrenPerFamily() float64 {
+	if s.Families == 0 {
+		return 0
+	}
+	return float64(s.Children) / float64(s.Families)
+}
+
+// GenerationStats returns a summary of each generation of descendants in the chart, in order
+// starting with the generation of the root person.
+func (ch *DescendantChart) GenerationStats() []GenStat {
+	var stats []GenStat
+	var walk func(p *DescendantPerson, gen int)
+	walk = func(p *DescendantPerson, gen int) {
+		if gen > len(stats) {
+			stats = append(stats, GenStat{Generation: gen})
+		}
+		stats[gen-1].People++
+		if p.LifeStatus == LifeStatusDeceased {
+			stats[gen-1].Deceased++
+		}
+		stats[gen-1].Families += len(p.Families)
+		for _, f := range p.Families {
+			stats[gen-1].Children += len(f.Children)
+			for _, c := range f.Children {
+				walk(c, gen+1)
+			}
+		}
+	}
+	if ch.Root != nil {
+		walk(ch.Root, 1)
 	}
+	return stats
 }
 
 // Layout generates the layout for the descendant chart based on the provided options.
@@ -91,34 +407,217 @@ func (ch *DescendantChart) Layout(opts *LayoutOptions) *DescendantLayout {
 		opts = DefaultLayoutOptions()
 	}
 
+	l := ch.layout(opts)
+	if opts.FixedWidth > 0 {
+		l = ch.fitWidth(l, opts)
+	}
+	return l
+}
+
+// fitWidth attempts to fit the chart into the fixed width given in the options by reducing the
+// spacing between blurbs and wrapping detail text more aggressively. The layout is widened if
+// it is narrower than the fixed width. If the chart cannot be made to fit then the natural layout
+// is returned and marked as overflowing.
+func (ch *DescendantChart) fitWidth(natural *DescendantLayout, opts *LayoutOptions) *DescendantLayout {
+	if natural.width <= opts.FixedWidth {
+		natural.padWidth(opts.FixedWidth)
+		return natural
+	}
+
+	// reduce spacing and wrap widths as far as they will go
+	best := ch.layout(opts.spaced(0))
+	if best.width > opts.FixedWidth {
+		natural.overflow = true
+		return natural
+	}
+
+	// find the least reduction that fits
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 10; i++ {
+		mid := (lo + hi) / 2
+		l := ch.layout(opts.spaced(mid))
+		if l.width <= opts.FixedWidth {
+			best = l
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	best.padWidth(opts.FixedWidth)
+	return best
+}
+
+// spaced returns a copy of the options with the horizontal spacing between blurbs and the widths
+// that text is wrapped to scaled by the factor f. Wrap widths are not reduced below half of their
+// original values.
+func (o *LayoutOptions) spaced(f float64) *LayoutOptions {
+	so := *o
+	so.Hspace = max(1, Pixel(float64(o.Hspace)*f))
+	so.ChildSpacing = Pixel(float64(o.ChildSpacing) * f)
+	so.FamilyGap = max(so.Hspace, Pixel(float64(o.FamilyGap)*f))
+	so.FocusMargin = Pixel(float64(o.FocusMargin) * f)
+	so.DetailWrapWidth = max(o.DetailWrapWidth/2, Pixel(float64(o.DetailWrapWidth)*f))
+	so.HeadingWrapWidth = max(o.HeadingWrapWidth/2, Pixel(float64(o.HeadingWrapWidth)*f))
+	so.MarriageDetailWrapWidth = max(o.MarriageDetailWrapWidth/2, Pixel(float64(o.MarriageDetailWrapWidth)*f))
+	return &so
+}
+
+// Ratios of width to height for pages of the ISO A series, such as A4, for use with FitToAspect.
+const (
+	ASeriesPortrait  = 1 / math.Sqrt2
+	ASeriesLandscape = math.Sqrt2
+)
+
+// FitToAspect returns layout options, based on the default options, tuned so that the layout of
+// the chart approaches the given ratio of width to height, such as ASeriesPortrait for printing
+// on a portrait A4 page. The horizontal spacing and wrap widths are first scaled to bring the
+// ratio as close as they can, then the gap between rows is increased to make a chart that is
+// still too wide taller, or a fixed width is set to widen a chart that is still too tall. The
+// default options are returned if the ratio is not positive or the chart is empty.
+func FitToAspect(ch *DescendantChart, ratio float64) *LayoutOptions {
+	opts := DefaultLayoutOptions()
+	if ratio <= 0 || ch.Root == nil {
+		return opts
+	}
+	aspect := func(l *DescendantLayout) float64 { return float64(l.width) / float64(l.height) }
+
+	// wider spacing and wrapping gives a wider chart, so search for the factor nearest the ratio
+	best, bestLayout := opts, ch.layout(opts)
+	closer := func(o *LayoutOptions, l *DescendantLayout) {
+		if math.Abs(aspect(l)-ratio) < math.Abs(aspect(bestLayout)-ratio) {
+			best, bestLayout = o, l
+		}
+	}
+	lo, hi := 0.0, 2.0
+	for i := 0; i < 10; i++ {
+		mid := (lo + hi) / 2
+		o := opts.spaced(mid)
+		l := ch.layout(o)
+		closer(o, l)
+		if aspect(l) < ratio {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	for _, f := range []float64{lo, hi} {
+		o := opts.spaced(f)
+		closer(o, ch.layout(o))
+	}
+
+	l := bestLayout
+	switch {
+	case aspect(l) > ratio && len(l.rows) > 1:
+		// spread the rows to make up the height
+		extra := Pixel(math.Ceil(float64(l.width)/ratio)) - l.height
+		gaps := Pixel(len(l.rows) - 1)
+		best.RowGap += (extra + gaps - 1) / gaps
+	case aspect(l) < ratio:
+		// pad the sides to make up the width
+		best.FixedWidth = Pixel(float64(l.height) * ratio)
+	}
+	return best
+}
+
+// scaleFonts applies FontScale to every style of text and to the widths that text is wrapped to.
+func (o *LayoutOptions) scaleFonts() {
+	if o.FontScale == 0 || o.FontScale == 1 {
+		return
+	}
+	for _, s := range []*TextStyle{&o.TitleStyle, &o.NoteStyle, &o.HeadingStyle, &o.DetailStyle, &o.MarriageDetailStyle, &o.FocusStyle, &o.GenerationLabelStyle, &o.WatermarkStyle} {
+		*s = s.scaled(o.FontScale)
+	}
+	o.DetailWrapWidth = Pixel(float64(o.DetailWrapWidth) * o.FontScale)
+	o.TitleWrapWidth = Pixel(float64(o.TitleWrapWidth) * o.FontScale)
+	o.HeadingWrapWidth = Pixel(float64(o.HeadingWrapWidth) * o.FontScale)
+	o.MarriageDetailWrapWidth = Pixel(float64(o.MarriageDetailWrapWidth) * o.FontScale)
+}
+
+// layout generates the layout for the descendant chart using the options without any adjustment for a fixed width.
+func (ch *DescendantChart) layout(opts *LayoutOptions) *DescendantLayout {
 	l := new(DescendantLayout)
 	l.title = ch.Title
 	l.notes = ch.Notes
 	l.opts = *opts
+	l.opts.scaleFonts()
 	l.blurbs = make(map[int]*Blurb)
+	l.kin = make(map[*Blurb]*Blurb)
+	l.stacked = make(map[*Blurb]bool)
+	l.beneath = make(map[*Blurb][]*Blurb)
+	l.stackedOn = make(map[*Blurb]*Blurb)
+	l.descents = make(map[*Blurb][]*Blurb)
+	l.partners = make(map[*Blurb]*Blurb)
+	l.parentConnectors = make(map[int][]*Connector)
+	l.familyLinks = make(map[*Blurb]string)
+	l.familyDrops = make(map[*Blurb]Pixel)
+	l.dashed = make(map[*Blurb]bool)
+	l.births = make(map[*Blurb]string)
+	l.childDrops = make(map[*Blurb]Pixel)
 	l.generationDrop = l.opts.LineWidth + l.opts.LineGap + l.opts.LineGap + l.opts.ChildDrop + l.opts.FamilyDrop
 
-	l.addPerson(ch.Root, 0, nil)
+	l.firstGeneration = max(1, l.opts.MinGeneration)
+	if ch.Root != nil {
+		l.addGenerations(ch.Root, 1, nil)
+	}
 
 	a := new(SpreadingDescendantArranger)
 	a.Arrange(l)
 
+	router := l.opts.ConnectorRouter
+	if router == nil {
+		router = new(DefaultConnectorRouter)
+	}
+	l.connectors = router.Route(l)
+	if len(l.partners) > 0 {
+		l.connectors = append(l.connectors, l.partnerConnectors()...)
+	}
+	for _, c := range l.connectors {
+		for _, id := range c.Children {
+			l.parentConnectors[id] = append(l.parentConnectors[id], c)
+		}
+	}
+	if len(l.opts.ConnectorColors) > 0 {
+		l.colorConnectors()
+	}
+	if len(l.familyLinks) > 0 {
+		l.linkConnectors()
+	}
+
 	return l
 }
 
 // DescendantLayout represents the layout of a descendant chart, including dimensions and layout options.
 type DescendantLayout struct {
-	title          string
-	notes          []string
+	chartText
+
+	footnotes      []string // notes attached to individual people, in the order they are numbered
 	width          Pixel
 	height         Pixel
 	generationDrop Pixel // distance between generations
 
+	firstGeneration int  // the generation number of the people in the first row of the layout
+	overflow        bool // true if the layout could not be fitted into the fixed width
+
 	opts LayoutOptions
 
 	blurbs     map[int]*Blurb
 	connectors []*Connector
 	rows       [][]*Blurb
+
+	stacked          map[*Blurb]bool      // relationship blurbs that should be packed closely rather than centred over their children
+	kin              map[*Blurb]*Blurb    // maps a blurb to the next blurb on the path towards the root person
+	parentConnectors map[int][]*Connector // maps the id of a child blurb to the connectors joining it to its parents
+	familyLinks      map[*Blurb]string    // maps the blurb the children of a family hang from to the link for the family
+	dashed           map[*Blurb]bool      // child blurbs whose relationship to their parents should be drawn with a dashed connector
+	births           map[*Blurb]string    // child blurbs born at a multiple birth, mapped to the label shared by the children of that birth
+	familyDrops      map[*Blurb]Pixel     // family drop lengths that override the layout option, keyed by the blurb the children descend from
+	childDrops       map[*Blurb]Pixel     // child drop lengths that override the layout option, keyed by the blurb the children descend from
+
+	beneath   map[*Blurb][]*Blurb // blurbs stacked beneath a person when spouses are placed below, from the top down
+	stackedOn map[*Blurb]*Blurb   // maps each blurb stacked beneath a person to that person
+	descents  map[*Blurb][]*Blurb // blurbs in a stack that children hang from, in family order, keyed by the person at the top
+	partners  map[*Blurb]*Blurb   // maps the spouse in a childless family shown without a relationship marker to the person
 }
 
 // Width returns the width of the layout.
@@ -138,19 +637,66 @@ func (l *DescendantLayout) Title() TextElement {
 	}
 }
 
-// Notes returns the notes elements of the layout.
+// Notes returns the notes elements of the layout, wrapped to fit the width of the chart.
 func (l *DescendantLayout) Notes() []TextElement {
-	tes := make([]TextElement, len(l.notes))
+	return textElements(l.noteLines, l.opts.NoteStyle)
+}
+
+// TitleLines returns the lines of the title of the layout once wrapped to fit the width of the chart.
+func (l *DescendantLayout) TitleLines() []TextElement {
+	return textElements(l.titleLines, l.opts.TitleStyle)
+}
 
-	for i := range l.notes {
+// Footnotes returns the numbered notes attached to individual people in the layout. They are
+// placed in a block at the bottom of the layout.
+func (l *DescendantLayout) Footnotes() []TextElement {
+	tes := make([]TextElement, len(l.footnotes))
+
+	for i := range l.footnotes {
 		tes[i] = TextElement{
-			Text:  l.notes[i],
+			Text:  fmt.Sprintf("%d. %s", i+1, l.footnotes[i]),
 			Style: l.opts.NoteStyle,
 		}
 	}
 	return tes
 }
 
+// Watermark returns the watermark of the chart, which is drawn across the middle of the layout.
+func (l *DescendantLayout) Watermark() TextElement {
+	return TextElement{Text: l.opts.Watermark, Style: l.opts.WatermarkStyle}
+}
+
+// GenerationLabels returns a label for each row of the layout naming its generation, positioned in
+// the left margin and centred vertically on the row. No labels are returned unless the
+// ShowGenerationLabels option is set.
+func (l *DescendantLayout) GenerationLabels() []Label {
+	if !l.opts.ShowGenerationLabels {
+		return nil
+	}
+	labels := make([]Label, 0, len(l.rows))
+	for row, bs := range l.rows {
+		if len(bs) == 0 {
+			continue
+		}
+		top, bottom := l.rowTop(row), Pixel(0)
+		for _, b := range bs {
+			bottom = max(bottom, b.TopPos+l.stackHeight(b))
+		}
+		labels = append(labels, Label{
+			Text:  l.generationLabel(row),
+			Style: l.opts.GenerationLabelStyle,
+			Left:  l.opts.Margin,
+			Y:     (top + bottom) / 2,
+		})
+	}
+	return labels
+}
+
+// generationLabel returns the text of the label for the given row.
+func (l *DescendantLayout) generationLabel(row int) string {
+	return fmt.Sprintf("Generation %d", l.firstGeneration+row)
+}
+
 // Blurbs returns all the blurbs in the layout.
 func (l *DescendantLayout) Blurbs() []*Blurb {
 	bs := make([]*Blurb, 0, len(l.blurbs))
@@ -165,88 +711,689 @@ func (l *DescendantLayout) Connectors() []*Connector {
 	return l.connectors
 }
 
+// ClickRegions returns the regions occupied by the blurbs of each person in the layout, ordered by id.
+// Regions are not included for relationship markers. The regions may be used to construct an image
+// map for a raster image of the layout.
+func (l *DescendantLayout) ClickRegions() []Region {
+	rs := make([]Region, 0, len(l.blurbs))
+	for _, b := range l.blurbs {
+		if b.ID <= 0 {
+			continue
+		}
+		rs = append(rs, Region{
+			ID:     b.ID,
+			Left:   b.Left(),
+			Top:    b.TopPos,
+			Width:  b.Width,
+			Height: b.Height,
+		})
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+	return rs
+}
+
 // Debug reports whether the layout is in debug mode.
 func (l *DescendantLayout) Debug() bool { return l.opts.Debug }
 
+// Overflow reports whether the layout is wider than the fixed width requested in the layout options
+// because the chart could not be made to fit.
+func (l *DescendantLayout) Overflow() bool { return l.overflow }
+
+// HighlightPath marks the blurbs and connectors along the path of relationships between the
+// people with ids fromID and toID. The path runs from each person up to their nearest common
+// ancestor. Nothing is highlighted if either person is not present in the layout.
+func (l *DescendantLayout) HighlightPath(fromID, toID int) {
+	from, ok := l.blurbs[fromID]
+	if !ok {
+		return
+	}
+	to, ok := l.blurbs[toID]
+	if !ok {
+		return
+	}
+
+	// record all of from's ancestors
+	fromAncestors := map[*Blurb]bool{}
+	for b := from; b != nil; b = l.kin[b] {
+		fromAncestors[b] = true
+	}
+
+	common := to
+	for common != nil && !fromAncestors[common] {
+		common = l.kin[common]
+	}
+	if common == nil {
+		return
+	}
+
+	viaChildren := true // whether both paths reach the common ancestor from a child
+	for _, start := range []*Blurb{from, to} {
+		for b := start; b != nil; b = l.kin[b] {
+			if b.ID > 0 {
+				b.Highlight = true
+			}
+			if b == common {
+				break
+			}
+			if l.kin[b] == common && b.Parent != common {
+				viaChildren = false
+			}
+			for _, c := range l.parentConnectors[b.ID] {
+				c.Highlight = true
+			}
+		}
+	}
+
+	// when both people descend from a relationship marker then both partners are common ancestors
+	if common.ID < 0 && viaChildren && from != to {
+		if p, ok := l.kin[common]; ok {
+			p.Highlight = true
+		}
+		if sp, ok := l.blurbs[-common.ID]; ok {
+			sp.Highlight = true
+		}
+	}
+}
+
+// SubtreeBounds returns the top left and bottom right corners of the smallest rectangle enclosing
+// the blurb of the person with the given id and those of all their descendants shown in the
+// layout, including their spouses and relationship markers. It reports false if the person is not
+// in the layout.
+func (l *DescendantLayout) SubtreeBounds(id int) (Point, Point, bool) {
+	root, ok := l.blurbs[id]
+	if !ok {
+		return Point{}, Point{}, false
+	}
+
+	topLeft := Point{X: root.Left(), Y: root.TopPos}
+	bottomRight := Point{X: root.Right(), Y: root.Bottom()}
+	for _, b := range l.blurbs {
+		// a blurb is in the subtree if its path towards the root person passes through the person
+		in := false
+		for k := l.kin[b]; k != nil; k = l.kin[k] {
+			if k == root {
+				in = true
+				break
+			}
+		}
+		if !in {
+			continue
+		}
+		topLeft.X, topLeft.Y = min(topLeft.X, b.Left()), min(topLeft.Y, b.TopPos)
+		bottomRight.X, bottomRight.Y = max(bottomRight.X, b.Right()), max(bottomRight.Y, b.Bottom())
+	}
+	return topLeft, bottomRight, true
+}
+
+// addGenerations adds a person and their descendants to the layout, skipping any generations before
+// the first generation of the layout. People in the first generation of the layout who are
+// not the root person are given a note naming the ancestor they descend from.
+func (l *DescendantLayout) addGenerations(p *DescendantPerson, gen int, parent *DescendantPerson) {
+	if gen >= l.firstGeneration {
+		if parent != nil {
+			var name string
+			if len(parent.Headings) > 0 {
+				name = strings.Join(parent.Headings, " ")
+			} else if len(parent.Details) > 0 {
+				name = parent.Details[0]
+			}
+			if name != "" {
+				np := *p
+				np.Details = append(append([]string{}, p.Details...), "descendant of "+name)
+				p = &np
+			}
+		}
+		l.addPerson(p, 0, nil)
+		return
+	}
+
+	for _, f := range p.Families {
+		for _, c := range f.Children {
+			l.addGenerations(c, gen+1, p)
+		}
+	}
+}
+
 // addPerson adds a person and their family to the layout at the specified row.
 func (l *DescendantLayout) addPerson(p *DescendantPerson, row int, parent *Blurb) *Blurb {
-	b := l.newBlurb(p.ID, p.Headings, p.Details, p.Tags, row, parent)
+	details := p.Details
+	if p.Collapsed {
+		if n := countDescendants(p); n == 1 {
+			details = append(append([]string{}, details...), "1 descendant")
+		} else if n > 1 {
+			details = append(append([]string{}, details...), fmt.Sprintf("%d descendants", n))
+		}
+	}
+
+	headings := p.Headings
+	if p.DiedInInfancy && l.opts.InfantDeathMarker != "" && len(headings) > 0 {
+		// the marker follows the whole name, which may end with a surname on a line of its own
+		last := len(headings) - 1
+		headings = append(append([]string{}, headings[:last]...), headings[last]+" "+l.opts.InfantDeathMarker)
+	}
+
+	b := l.newBlurb(p.ID, headings, details, p.DetailStyles, p.Tags, l.opts.DetailStyle, l.opts.DetailWrapWidth, row, parent)
+	b.Collapsed = p.Collapsed
+	b.Meta = p.Meta
+	b.setSex(p.Sex)
+	if len(p.Notes) > 0 {
+		refs := make([]int, len(p.Notes))
+		for i := range p.Notes {
+			l.footnotes = append(l.footnotes, p.Notes[i])
+			refs[i] = len(l.footnotes)
+		}
+		b.setNoteRefs(refs)
+	}
+	if l.opts.DNAMarkers && slices.ContainsFunc(p.Tags, func(tag string) bool { return strings.EqualFold(tag, DNATag) }) {
+		b.setDNATested()
+	}
+	if parent != nil {
+		l.kin[b] = parent
+	}
+	if p.Collapsed {
+		return b
+	}
 
+	visibleFamilies := 0
+	spouseChildren := false                  // whether any family with a known spouse has children
+	ordinals := make([]int, len(p.Families)) // the position of each visible family among the visible families, from 1
 	for fi := range p.Families {
+		if l.familyVisible(p.Families[fi]) {
+			visibleFamilies++
+			ordinals[fi] = visibleFamilies
+			if p.Families[fi].Other != nil && len(p.Families[fi].Children) > 0 {
+				spouseChildren = true
+			}
+		}
+	}
+
+	order := make([]int, len(p.Families))
+	for fi := range order {
+		order[fi] = fi
+	}
+	if l.opts.SpouseSide == SpouseLeft {
+		// the first family is placed nearest the person so the families are added from the outside in
+		slices.Reverse(order)
+	}
+
+	var firstChild, lastChild *Blurb // the outermost children of all the families of the person
+	for _, fi := range order {
+		if !l.familyVisible(p.Families[fi]) {
+			continue
+		}
 		relText := "="
-		if len(p.Families) > 1 {
-			relText += fmt.Sprintf(" (%d)", fi+1)
+		orderText := ""
+		if visibleFamilies > 1 {
+			orderText = l.familyOrderText(ordinals[fi], p.Families[fi])
+		}
+		if orderText != "" {
+			relText += " " + orderText
 		}
-		relDetails := []string{relText}
-		relDetails = append(relDetails, p.Families[fi].Details...)
 
 		var rel, sp *Blurb
 		var famCentre *Blurb
 		// var famRightmost *Blurb
+		if p.Families[fi].Other != nil && l.opts.OmitChildlessMarker && len(p.Families[fi].Children) == 0 && len(dropEmptyLines(p.Families[fi].Details)) == 0 {
+			// the couple are joined by a line in place of the relationship marker
+			sp = l.addPerson(p.Families[fi].Other, row, nil)
+			switch l.opts.SpouseSide {
+			case SpouseBelow:
+				l.stackBeneath(b, sp)
+			case SpouseLeft:
+				sp.KeepTightRight = b
+				l.rows[row] = append(slices.DeleteFunc(l.rows[row], func(o *Blurb) bool { return o == b }), b)
+			default:
+				if b.KeepTightRight == nil {
+					b.KeepTightRight = sp
+				}
+			}
+			sp.NoShift = true
+			l.kin[sp] = b
+			l.partners[sp] = b
+			continue
+		}
 		if p.Families[fi].Other != nil {
-			rel = l.newBlurb(-p.Families[fi].Other.ID, []string{}, relDetails, []string{}, row, nil)
+			if l.opts.SpouseSide == SpouseLeft {
+				sp = l.addPerson(p.Families[fi].Other, row, nil)
+			}
+
+			// the relationship marker is the heading with family details centred beneath it
+			relDetails := p.Families[fi].Details
+			if l.opts.UnionMarker != UnionEquals {
+				// the shape is drawn on an empty heading line
+				if orderText != "" {
+					relDetails = append([]string{orderText}, relDetails...)
+				}
+				relText = ""
+			}
+			rel = l.newBlurb(-p.Families[fi].Other.ID, []string{relText}, relDetails, nil, []string{}, l.opts.MarriageDetailStyle, l.marriageDetailWrapWidth(), row, nil)
 			rel.CentreText = true
+			if l.opts.UnionMarker != UnionEquals {
+				rel.Marker = l.opts.UnionMarker
+				rel.Width = max(rel.Width, rel.HeadingTexts.Style.FontSize)
+			}
 			famCentre = rel
-
-			// Attempt to keep with spouse relation marker if this is the first one
-			if b.KeepTightRight == nil {
-				b.KeepTightRight = rel
+			l.kin[rel] = b
+			if l.opts.StackSpouses && visibleFamilies > 1 {
+				l.stacked[rel] = true
 			}
 
-			sp = l.addPerson(p.Families[fi].Other, row, nil)
+			if l.opts.SpouseSide == SpouseBelow {
+				l.stackBeneath(b, rel)
+				sp = l.addPerson(p.Families[fi].Other, row, nil)
+				l.stackBeneath(b, sp)
+			} else if l.opts.SpouseSide == SpouseLeft {
+				// keep the spouse with their relationship marker and follow it with the person, who is
+				// kept beside the marker of the family nearest them
+				sp.KeepTightRight = rel
+				rel.KeepTightRight = b
+				l.rows[row] = append(slices.DeleteFunc(l.rows[row], func(o *Blurb) bool { return o == b }), b)
+			} else {
+				// Attempt to keep with spouse relation marker if this is the first one
+				if b.KeepTightRight == nil {
+					b.KeepTightRight = rel
+				}
+				sp = l.addPerson(p.Families[fi].Other, row, nil)
+			}
 			sp.NoShift = true
+			l.kin[sp] = rel
+
+		} else {
+			famCentre = b
+			if spouseChildren && len(p.Families[fi].Children) > 0 {
+				// children without a known other parent hang from a line above the one joining the
+				// children of any spouse so the two families can't be mistaken for one
+				l.familyDrops[b] = l.opts.FamilyDrop / 2
+				l.childDrops[b] = l.opts.ChildDrop + l.opts.FamilyDrop - l.opts.FamilyDrop/2
+			}
+		}
+
+		if p.Families[fi].FamilyDrop > 0 {
+			l.familyDrops[famCentre] = p.Families[fi].FamilyDrop
+		}
+		if p.Families[fi].ChildDrop > 0 {
+			l.childDrops[famCentre] = p.Families[fi].ChildDrop
+		}
+		if p.Families[fi].Link != "" {
+			if rel != nil {
+				rel.Link = p.Families[fi].Link
+			}
+			l.familyLinks[famCentre] = p.Families[fi].Link
+		}
+
+		if l.opts.MaxGeneration > 0 && l.firstGeneration+row+1 > l.opts.MaxGeneration {
+			// children are beyond the last generation to be included
+			continue
+		}
+
+		// var prevChild *Blurb
+		children := birthOrder(p.Families[fi].Children)
+		for ci := range children {
+			c := l.addPerson(children[ci], row+1, famCentre)
+			if firstChild == nil {
+				firstChild = c
+			}
+			lastChild = c
+			if p.Families[fi].Relationship != BirthRelationship || children[ci].Relationship != BirthRelationship {
+				l.dashed[c] = true
+			}
+			if children[ci].MultipleBirth != "" {
+				l.births[c] = children[ci].MultipleBirth
+			}
+
+			if rel != nil {
+
+				if ci == 0 {
+					rel.FirstChild = c
+				}
+				if ci == len(children)-1 {
+					rel.LastChild = c
+				}
+
+			} else {
+				// Attempt to keep with parent
+
+				if ci == 0 {
+					b.FirstChild = c
+				}
+				if ci == len(children)-1 {
+					b.LastChild = c
+				}
+
+			}
+
+		}
+	}
+
+	if len(l.beneath[b]) > 0 {
+		for _, o := range append([]*Blurb{b}, l.beneath[b]...) {
+			if o.FirstChild != nil && o.FirstChild.Parent == o {
+				l.descents[b] = append(l.descents[b], o)
+			}
+		}
+		if n := len(l.descents[b]); n > 1 {
+			// each family joins its children with a line at its own height, the first highest, so the
+			// lines of families descending from neighbouring points can't be mistaken for one
+			for i, o := range l.descents[b] {
+				_, fok := l.familyDrops[o]
+				_, cok := l.childDrops[o]
+				if fok || cok {
+					continue
+				}
+				l.familyDrops[o] = l.opts.FamilyDrop * Pixel(i+1) / Pixel(n)
+				l.childDrops[o] = l.opts.ChildDrop + l.opts.FamilyDrop - l.familyDrops[o]
+			}
+		}
+		// the stack is arranged as a single blurb so it is centred over the children of every family
+		b.FirstChild, b.LastChild = firstChild, lastChild
+	}
+
+	return b
+}
+
+// stackBeneath removes the blurb o from its row and stacks it beneath the person b, below anything
+// already stacked there.
+func (l *DescendantLayout) stackBeneath(b, o *Blurb) {
+	l.rows[o.Row] = slices.DeleteFunc(l.rows[o.Row], func(x *Blurb) bool { return x == o })
+	l.beneath[b] = append(l.beneath[b], o)
+	l.stackedOn[o] = b
+}
+
+// rowTop returns the vertical position of the top of the highest blurb in the given row.
+func (l *DescendantLayout) rowTop(row int) Pixel {
+	top := l.rows[row][0].TopPos
+	for _, b := range l.rows[row][1:] {
+		top = min(top, b.TopPos)
+	}
+	return top
+}
+
+// rowBlurb returns the blurb that is placed in a row on behalf of b, which is the person that b is
+// stacked beneath or b itself.
+func (l *DescendantLayout) rowBlurb(b *Blurb) *Blurb {
+	if o, ok := l.stackedOn[b]; ok {
+		return o
+	}
+	return b
+}
+
+// descentX returns the horizontal position at which the connectors to the children of b leave the
+// foot of its stack. When more than one family in a stack has children, such as a person with
+// several spouses stacked beneath them, each family descends from its own point, spread evenly
+// across the stack in the order of the families, so that their children can be told apart.
+// Otherwise the connectors leave from the middle of b.
+func (l *DescendantLayout) descentX(b *Blurb) Pixel {
+	top := l.rowBlurb(b)
+	i := slices.Index(l.descents[top], b)
+	if i < 0 || len(l.descents[top]) < 2 {
+		return b.X()
+	}
+	left, right := top.Left(), top.Right()
+	for _, o := range l.beneath[top] {
+		left, right = min(left, o.Left()), max(right, o.Right())
+	}
+	return left + (right-left)*Pixel(i+1)/Pixel(len(l.descents[top])+1)
+}
+
+// stackHeight returns the height of b together with any blurbs stacked beneath it.
+func (l *DescendantLayout) stackHeight(b *Blurb) Pixel {
+	h := b.Height
+	for _, o := range l.beneath[b] {
+		h += l.stackGap(o) + o.Height
+	}
+	return h
+}
+
+// stackGap returns the vertical space left above a blurb stacked beneath a person, which leaves
+// room for a line joining a spouse shown without a relationship marker.
+func (l *DescendantLayout) stackGap(o *Blurb) Pixel {
+	if _, ok := l.partners[o]; ok {
+		return l.partnerGap()
+	}
+	return l.opts.LineGap
+}
+
+// partnerGap returns the space left between a person and a spouse shown without a relationship
+// marker, which is enough for a line of length Hspace to join them.
+func (l *DescendantLayout) partnerGap() Pixel {
+	return l.opts.Hspace + 2*l.opts.LineGap
+}
+
+// partnerConnectors returns the lines joining each person to any spouse shown without a
+// relationship marker, ordered by the id of the spouse.
+func (l *DescendantLayout) partnerConnectors() []*Connector {
+	spouses := make([]*Blurb, 0, len(l.partners))
+	for sp := range l.partners {
+		spouses = append(spouses, sp)
+	}
+	slices.SortFunc(spouses, func(a, b *Blurb) int { return a.ID - b.ID })
+
+	connectors := make([]*Connector, 0, len(spouses))
+	for _, sp := range spouses {
+		b := l.partners[sp]
+		var c *Connector
+		if l.stackedOn[sp] == b {
+			c = &Connector{Points: []Point{{X: b.X(), Y: b.Bottom() + l.opts.LineGap}, {X: b.X(), Y: sp.TopPos - l.opts.LineGap}}}
+		} else {
+			left, right := b, sp
+			if sp.Left() < b.Left() {
+				left, right = sp, b
+			}
+			c = &Connector{Points: []Point{{X: left.Right() + l.opts.LineGap, Y: b.SideHookY()}, {X: right.Left() - l.opts.LineGap, Y: b.SideHookY()}}}
+		}
+		connectors = append(connectors, c)
+	}
+	return connectors
+}
+
+// stackBottom returns the vertical position of the foot of the stack containing b, which is where
+// connectors from the children of b begin.
+func (l *DescendantLayout) stackBottom(b *Blurb) Pixel {
+	top := l.rowBlurb(b)
+	return top.TopPos + l.stackHeight(top)
+}
+
+// birthOrder returns the children of a family in the order they should be placed, which is the
+// order given except that the children of a multiple birth are moved to follow the first of them.
+func birthOrder(children []*DescendantPerson) []*DescendantPerson {
+	ordered := make([]*DescendantPerson, 0, len(children))
+	placed := make(map[string]bool)
+	for i, c := range children {
+		if c.MultipleBirth == "" {
+			ordered = append(ordered, c)
+			continue
+		}
+		if placed[c.MultipleBirth] {
+			continue
+		}
+		placed[c.MultipleBirth] = true
+		for _, o := range children[i:] {
+			if o.MultipleBirth == c.MultipleBirth {
+				ordered = append(ordered, o)
+			}
+		}
+	}
+	return ordered
+}
+
+// MultipleBirth returns the label of the multiple birth that child was born at, or an empty
+// string if the child was born at a single birth.
+func (l *DescendantLayout) MultipleBirth(child *Blurb) string {
+	return l.births[child]
+}
+
+// junctionX returns the horizontal position at which the connector from child meets the line
+// joining it to its siblings. The children of a multiple birth meet at a single point midway
+// between the outermost of them.
+func (l *DescendantLayout) junctionX(child *Blurb) Pixel {
+	label := l.births[child]
+	if label == "" {
+		return child.TopHookX()
+	}
+	left, right := child.TopHookX(), child.TopHookX()
+	for _, s := range l.rows[child.Row] {
+		if s.Parent == child.Parent && l.births[s] == label {
+			left, right = min(left, s.TopHookX()), max(right, s.TopHookX())
+		}
+	}
+	return (left + right) / 2
+}
+
+// colorConnectors colors the connectors serving a single child with the layout's palette, taking
+// the colors in turn for each child of a family from left to right. Any color already chosen by
+// the connector router is kept.
+func (l *DescendantLayout) colorConnectors() {
+	for row := range l.rows {
+		index := make(map[*Blurb]int) // the number of children of each parent seen so far in the row
+		for _, b := range l.rows[row] {
+			if b.Parent == nil {
+				continue
+			}
+			color := l.opts.ConnectorColors[index[b.Parent]%len(l.opts.ConnectorColors)]
+			index[b.Parent]++
+			for _, c := range l.parentConnectors[b.ID] {
+				if len(c.Children) == 1 && c.Color == "" {
+					c.Color = color
+				}
+			}
+		}
+	}
+}
+
+// linkConnectors gives the connectors joining the children of a family to their parents the link
+// of the family, when every child served by the connector belongs to that family.
+func (l *DescendantLayout) linkConnectors() {
+	for _, c := range l.connectors {
+		if len(c.Children) == 0 {
+			continue
+		}
+		var link string
+		for i, id := range c.Children {
+			b, ok := l.blurbs[id]
+			if !ok || b.Parent == nil || (i > 0 && l.blurbs[c.Children[0]].Parent != b.Parent) {
+				link = ""
+				break
+			}
+			link = l.familyLinks[b.Parent]
+		}
+		c.Link = link
+	}
+}
+
+// ChildDrop returns the length of the line drawn from the children group line to each child of parent.
+func (l *DescendantLayout) ChildDrop(parent *Blurb) Pixel {
+	if d, ok := l.childDrops[parent]; ok {
+		return d
+	}
+	return l.opts.ChildDrop
+}
+
+// DashedConnector reports whether the connector joining child to its parents should be dashed because
+// the child is not the birth child of the parents.
+func (l *DescendantLayout) DashedConnector(child *Blurb) bool {
+	return l.dashed[child]
+}
+
+// Options returns the options used to generate the layout.
+func (l *DescendantLayout) Options() LayoutOptions { return l.opts }
+
+// rowDrop returns the vertical distance between the given row and the next, which is the largest
+// needed by any of the families whose children are in the next row.
+func (l *DescendantLayout) rowDrop(row int) Pixel {
+	if row+1 >= len(l.rows) || (len(l.familyDrops) == 0 && len(l.childDrops) == 0) {
+		return l.generationDrop
+	}
+	drop := Pixel(0)
+	for _, b := range l.rows[row+1] {
+		if b.Parent == nil {
+			continue
+		}
+		familyDrop := l.opts.FamilyDrop
+		if d, ok := l.familyDrops[b.Parent]; ok {
+			familyDrop = d
+		}
+		drop = max(drop, l.opts.LineWidth+l.opts.LineGap+l.opts.LineGap+l.ChildDrop(b.Parent)+familyDrop)
+	}
+	if drop == 0 {
+		return l.generationDrop
+	}
+	return drop
+}
 
-		} else {
-			famCentre = b
+// countDescendants returns the number of descendants of a person, not including their spouses.
+func countDescendants(p *DescendantPerson) int {
+	n := 0
+	for _, f := range p.Families {
+		for _, c := range f.Children {
+			n += 1 + countDescendants(c)
 		}
+	}
+	return n
+}
 
-		// var prevChild *Blurb
-		for ci := range p.Families[fi].Children {
-			c := l.addPerson(p.Families[fi].Children[ci], row+1, famCentre)
-
-			if rel != nil {
-
-				if ci == 0 {
-					rel.FirstChild = c
-				}
-				if ci == len(p.Families[fi].Children)-1 {
-					rel.LastChild = c
-				}
-
-			} else {
-				// Attempt to keep with parent
+// familyVisible reports whether the family should be included in the layout.
+func (l *DescendantLayout) familyVisible(f *DescendantFamily) bool {
+	if l.opts.HideChildlessFamilies && len(f.Children) == 0 {
+		return false
+	}
+	return true
+}
 
-				if ci == 0 {
-					b.FirstChild = c
-				}
-				if ci == len(p.Families[fi].Children)-1 {
-					b.LastChild = c
-				}
+// familyOrderText returns the text indicating the order of a family among the families of a person.
+func (l *DescendantLayout) familyOrderText(n int, f *DescendantFamily) string {
+	if l.opts.FamilyOrderText != nil {
+		return l.opts.FamilyOrderText(n, f)
+	}
+	return fmt.Sprintf("(%d)", n)
+}
 
-			}
+// marriageDetailWrapWidth returns the width the family details beneath a relationship marker are
+// wrapped to.
+func (l *DescendantLayout) marriageDetailWrapWidth() Pixel {
+	if l.opts.MarriageDetailWrapWidth > 0 {
+		return l.opts.MarriageDetailWrapWidth
+	}
+	return l.opts.DetailWrapWidth
+}
 
+// newBlurb creates a new blurb for the given person or family at the specified row, wrapping the
+// detail text to detailWrapWidth. Any textStyles override the style of the corresponding detail
+// texts.
+func (l *DescendantLayout) newBlurb(id int, headings []string, texts []string, textStyles []*TextStyle, tags []string, detailStyle TextStyle, detailWrapWidth Pixel, row int, parent *Blurb) *Blurb {
+	var lineStyles []TextStyle
+	if len(textStyles) > 0 {
+		texts, lineStyles = l.styledLines(texts, textStyles, detailStyle, detailWrapWidth)
+	} else {
+		if !l.opts.KeepEmptyDetails {
+			texts = dropEmptyLines(texts)
 		}
+		texts = wrapText(texts, detailWrapWidth, detailStyle)
 	}
 
-	return b
-}
+	headingStyle := l.opts.HeadingStyle
+	focus := id > 0 && id == l.opts.FocusID
+	if focus {
+		headingStyle = l.opts.FocusStyle
+	}
+	if l.opts.HeadingWrapWidth > 0 {
+		headings = wrapText(headings, l.opts.HeadingWrapWidth, headingStyle)
+	}
 
-// newBlurb creates a new blurb for the given person or family at the specified row.
-func (l *DescendantLayout) newBlurb(id int, headings []string, texts []string, tags []string, row int, parent *Blurb) *Blurb {
-	texts = wrapText(texts, l.opts.DetailWrapWidth, l.opts.DetailStyle.FontSize)
 	b := &Blurb{
 		ID:             id,
 		Row:            row,
 		Parent:         parent,
+		Focus:          focus,
 		TopHookOffset:  l.opts.Hspace * 2,
-		SideHookOffset: l.opts.HeadingStyle.LineHeight / 2,
+		SideHookOffset: headingStyle.LineHeight / 2,
 		HeadingTexts: TextSection{
 			Lines: []string{},
-			Style: l.opts.HeadingStyle,
+			Style: headingStyle,
 		},
 		DetailTexts: TextSection{
 			Lines: []string{},
-			Style: l.opts.DetailStyle,
+			Style: detailStyle,
+			Align: l.opts.DetailAlign,
 		},
 		Tags: tags,
 	}
@@ -254,27 +1401,42 @@ func (l *DescendantLayout) newBlurb(id int, headings []string, texts []string, t
 	if len(headings) > 0 {
 		b.HeadingTexts.Lines = headings
 		b.Height = b.HeadingTexts.Style.LineHeight * Pixel(len(b.HeadingTexts.Lines))
-	} else {
+	} else if len(texts) > 0 {
 		b.HeadingTexts.Lines = append(b.HeadingTexts.Lines, texts[0])
 		b.Height = b.HeadingTexts.Style.LineHeight
 		texts = texts[1:]
+		if len(lineStyles) > 0 {
+			lineStyles = lineStyles[1:]
+		}
+	} else {
+		// no text at all, reserve a single empty heading line
+		b.HeadingTexts.Lines = append(b.HeadingTexts.Lines, "")
+		b.Height = b.HeadingTexts.Style.LineHeight
 	}
 
 	if len(texts) > 0 {
 		b.DetailTexts.Lines = texts
-		b.Height += b.DetailTexts.Style.LineHeight * Pixel(len(b.DetailTexts.Lines))
+		b.DetailTexts.LineStyles = lineStyles
+		if l.opts.DetailColumns > 1 {
+			b.DetailTexts.arrangeColumns(l.opts.DetailColumns, l.opts.Hspace, detailWrapWidth)
+		}
+		b.Height += b.DetailTexts.LineHeight() * Pixel(b.DetailTexts.Rows())
 	}
 
 	for i := range b.HeadingTexts.Lines {
-		wl := textWidth([]rune(b.HeadingTexts.Lines[i]), b.HeadingTexts.Style.FontSize)
+		wl := b.HeadingTexts.Style.width(b.HeadingTexts.Lines[i])
 		if wl > b.Width {
 			b.Width = wl
 		}
 	}
-	for i := range b.DetailTexts.Lines {
-		wl := textWidth([]rune(b.DetailTexts.Lines[i]), b.DetailTexts.Style.FontSize)
-		if wl > b.Width {
-			b.Width = wl
+	if b.DetailTexts.Columns > 1 {
+		b.Width = max(b.Width, b.DetailTexts.ColumnsWidth())
+	} else {
+		for i := range b.DetailTexts.Lines {
+			wl := b.DetailTexts.LineStyle(i).width(b.DetailTexts.Lines[i])
+			if wl > b.Width {
+				b.Width = wl
+			}
 		}
 	}
 
@@ -288,22 +1450,93 @@ func (l *DescendantLayout) newBlurb(id int, headings []string, texts []string, t
 	return b
 }
 
+// styledLines wraps each of the texts in its own style, returning the wrapped lines together with
+// the style of each line. Styles missing from styles, or nil, use base. Sizes given in styles are
+// scaled by the FontScale option in the same way as the layout's own styles.
+func (l *DescendantLayout) styledLines(texts []string, styles []*TextStyle, base TextStyle, wrapWidth Pixel) ([]string, []TextStyle) {
+	var lines []string
+	var lineStyles []TextStyle
+	for i, text := range texts {
+		if !l.opts.KeepEmptyDetails && strings.TrimSpace(text) == "" {
+			continue
+		}
+		style := base
+		if i < len(styles) && styles[i] != nil {
+			style = *styles[i]
+			if l.opts.FontScale != 0 {
+				style = style.scaled(l.opts.FontScale)
+			}
+			style = style.inherit(base)
+		}
+		for _, line := range wrapText([]string{text}, wrapWidth, style) {
+			lines = append(lines, line)
+			lineStyles = append(lineStyles, style)
+		}
+	}
+	return lines, lineStyles
+}
+
 type SpreadingDescendantArranger struct{}
 
 func (a *SpreadingDescendantArranger) Arrange(l *DescendantLayout) {
-	// spread rows vertically
-	top := Pixel(0)
-	for _, bs := range l.rows {
-		rowHeight := Pixel(0)
+	if len(l.rows) == 0 {
+		// an empty chart still occupies its margins and any title
+		a.centreBlurbs(l)
+		return
+	}
+
+	// a person with spouses stacked beneath them is arranged as a single blurb as wide as the stack
+	widths := make(map[*Blurb]Pixel, len(l.beneath))
+	for b, bs := range l.beneath {
+		widths[b] = b.Width
+		for _, o := range bs {
+			b.Width = max(b.Width, o.Width)
+		}
+	}
+
+	// spread rows vertically, first positioning each blurb relative to the top of its row
+	heights := make([]Pixel, len(l.rows))
+	drops := make([]Pixel, len(l.rows))
+	for row, bs := range l.rows {
+		headingHeight := Pixel(0) // the tallest first heading line in the row
+		if l.opts.AlignChildrenTop {
+			for i := range bs {
+				headingHeight = max(headingHeight, bs[i].HeadingTexts.Style.LineHeight)
+			}
+		}
 		for i := range bs {
 			bs[i].AbsolutePositioning = true
-			bs[i].TopPos = top
+			bs[i].TopPos = 0
+			if l.opts.AlignChildrenTop {
+				// lower the blurb so the foot of its first heading line meets that of the tallest
+				bs[i].TopPos += headingHeight - bs[i].HeadingTexts.Style.LineHeight
+			}
 			if i > 0 {
 				bs[i].LeftNeighbour = bs[i-1]
 			}
-			rowHeight = max(rowHeight, bs[i].Height)
+			heights[row] = max(heights[row], bs[i].TopPos+l.stackHeight(bs[i]))
+		}
+		drops[row] = l.rowDrop(row)
+	}
+	if l.opts.UniformRowHeight {
+		// every row is as tall as the tallest, and every drop as long as the longest
+		var height, drop Pixel
+		for row := range l.rows {
+			height = max(height, heights[row])
+			if row < len(l.rows)-1 {
+				drop = max(drop, drops[row])
+			}
 		}
-		top += rowHeight + l.generationDrop
+		for row := range l.rows {
+			heights[row], drops[row] = height, drop
+		}
+	}
+	top := Pixel(0)
+	for row, bs := range l.rows {
+		for i := range bs {
+			bs[i].TopPos += top
+		}
+		top += heights[row] + drops[row] + l.opts.RowGap
 	}
 
 	// spread blurbs in last row evenly
@@ -311,51 +1544,105 @@ func (a *SpreadingDescendantArranger) Arrange(l *DescendantLayout) {
 	bs := l.rows[len(l.rows)-1]
 	for i := range bs {
 		if i > 0 {
-			left += l.opts.Hspace
-			if bs[i].Parent != bs[i-1].Parent {
-				// extra space between families
-				left += l.opts.Hspace * 2
-			}
+			left += a.gap(l, bs[i-1], bs[i])
 		}
 		bs[i].LeftPos = left
 		left += bs[i].Width
 	}
 
+	a.spreadSubtrees(l)
+
+	// keep blurbs close to the blurb on their right, working leftwards so that a spouse follows
+	// their relationship marker when it is pulled across to the person
+	for row := range l.rows {
+		bs := l.rows[row]
+		for i := len(bs) - 2; i >= 0; i-- {
+			if bs[i].KeepTightRight == nil {
+				continue
+			}
+			if bs[i].KeepTightRight != bs[i+1] {
+				continue
+			}
+			space := l.opts.Hspace
+			if l.partners[bs[i]] == bs[i+1] || l.partners[bs[i+1]] == bs[i] {
+				space = l.partnerGap()
+			}
+			bs[i].LeftPos = bs[i+1].Left() - space - bs[i].Width
+
+		}
+	}
+	// close up gaps by pulling across any early siblings that don't have children
+	for row := range l.rows {
+		bs := l.rows[row]
+		for i := len(bs) - 1; i >= 1; i-- {
+			if bs[i-1].FirstChild == nil && bs[i].Parent != nil && bs[i-1].Parent != nil && bs[i].Parent == bs[i-1].Parent {
+				if gap := a.gap(l, bs[i-1], bs[i]); bs[i].Left()-bs[i-1].Right() > gap {
+					bs[i-1].LeftPos = bs[i].Left() - gap - bs[i-1].Width
+				}
+			}
+		}
+	}
+
+	a.alignLoneChildren(l)
+	if l.opts.CentreMarkers {
+		a.alignMarkers(l)
+	}
+
+	// centre each blurb of a stack beneath the one above it
+	for b, bs := range l.beneath {
+		left, width := b.LeftPos, b.Width
+		b.Width = widths[b]
+		b.LeftPos = left + (width-b.Width)/2
+		y := b.Bottom()
+		for _, o := range bs {
+			o.AbsolutePositioning = true
+			o.TopPos = y + l.stackGap(o)
+			o.LeftPos = left + (width-o.Width)/2
+			y = o.Bottom()
+		}
+	}
+
+	a.centreBlurbs(l)
+}
+
+// spreadSubtrees works up from the bottom row spreading out blurbs so subtrees don't overlap,
+// centring each blurb over its children. The blurbs of the bottom row must already be placed.
+func (a *SpreadingDescendantArranger) spreadSubtrees(l *DescendantLayout) {
 	if len(l.rows) == 1 {
 		return
 	}
 
-	// work up from bottom row spreading out blurbs so subtrees don't overlap
+	// Rather than moving each subtree as soon as it needs to be shifted, the shift needed by the
+	// children of each blurb is accumulated and applied in a single downward sweep afterwards.
+	offsets := make(map[*Blurb]Pixel) // shift to be applied to the descendants of a blurb
 	for row := len(l.rows) - 2; row >= 0; row-- {
 		minLeft := Pixel(0)
+		carry := Pixel(0) // shift applied to the children of this and all subsequent blurbs in the row
 		bs := l.rows[row]
 		for i := range bs {
 			if i > 0 {
-				minLeft += l.opts.Hspace
-				if bs[i].Parent != bs[i-1].Parent {
-					// extra space between families
-					minLeft += l.opts.Hspace * 2
-				}
+				minLeft += a.gap(l, bs[i-1], bs[i])
 			}
-			if bs[i].FirstChild != nil {
+			if bs[i].FirstChild != nil && !l.stacked[bs[i]] {
 				// centre over children
 				w := bs[i].LastChild.Right() - bs[i].FirstChild.Left()
 
-				// This is centre point over children
-				x := bs[i].FirstChild.Left() + w/2
+				// This is centre point over children, allowing for any pending shift
+				x := bs[i].FirstChild.Left() + carry + w/2
 
 				// adjust to the left side of the blurb
 				x -= bs[i].Width / 2
 
 				if x < minLeft {
-					for j := i; j < len(bs); j++ {
-						a.shiftChildren(l, row+1, bs[j], minLeft-x)
-					}
+					carry += minLeft - x
 				} else {
 					minLeft = x
 				}
 
 			}
+			if carry != 0 {
+				offsets[bs[i]] = carry
+			}
 
 			bs[i].LeftPos = minLeft
 			minLeft += bs[i].Width
@@ -363,61 +1650,374 @@ func (a *SpreadingDescendantArranger) Arrange(l *DescendantLayout) {
 		}
 	}
 
-	// close up gaps by pulling across any early siblings that don't have children
-	for row := range l.rows {
-		bs := l.rows[row]
-		for i := 0; i < len(bs)-2; i++ {
-			if bs[i].KeepTightRight == nil {
-				continue
-			}
-			if bs[i].KeepTightRight != bs[i+1] {
-				continue
-			}
-			bs[i].LeftPos = bs[i+1].Left() - l.opts.Hspace - bs[i].Width
-
-		}
-	}
-	// close up gaps by pulling across any early siblings that don't have children
-	for row := range l.rows {
-		bs := l.rows[row]
-		for i := len(bs) - 1; i >= 1; i-- {
-			if bs[i-1].FirstChild == nil && bs[i].Parent != nil && bs[i-1].Parent != nil && bs[i].Parent == bs[i-1].Parent && bs[i].Left()-bs[i-1].Right() > l.opts.Hspace {
-				bs[i-1].LeftPos = bs[i].Left() - l.opts.Hspace - bs[i-1].Width
+	// apply the accumulated shifts, working down from the top row so each blurb receives the
+	// shifts of all of its ancestors
+	if len(offsets) > 0 {
+		shifts := make(map[*Blurb]Pixel)
+		for row := 1; row < len(l.rows); row++ {
+			for _, b := range l.rows[row] {
+				if b.Parent == nil {
+					continue
+				}
+				parent := l.rowBlurb(b.Parent)
+				shift := shifts[parent] + offsets[parent]
+				if shift != 0 {
+					shifts[b] = shift
+					b.LeftPos += shift
+				}
 			}
 		}
 	}
+}
 
-	a.centreBlurbs(l)
+// DefaultConnectorRouter is the ConnectorRouter used when none is set in the layout options. It
+// joins each child to the centre of its parent with a stepped line, or hangs the children of each
+// family from a single horizontal bar when the SiblingBar option is set.
+type DefaultConnectorRouter struct{}
+
+// Route returns the connectors joining each child in the layout to its parents.
+func (r *DefaultConnectorRouter) Route(l *DescendantLayout) []*Connector {
+	if l.opts.SiblingBar {
+		return r.siblingBars(l)
+	}
 
 	// Descendant chart is a top-down layout
-	l.connectors = []*Connector{}
+	connectors := []*Connector{}
 	for _, b := range l.blurbs {
 		if b.Parent != nil {
-			if b.Parent.ID > 0 && b.Parent.FirstChild == b.Parent.LastChild {
-				l.connectors = append(l.connectors, &Connector{
-					Points: []Point{
-						// Start just above blurb
-						{X: b.TopHookX(), Y: b.TopPos - l.opts.LineGap},
-						// Move up to parent
-						{X: b.TopHookX(), Y: b.Parent.Bottom() + l.opts.LineGap},
-					},
-				})
+			var c *Connector
+			if r.straight(l, b) {
+				c = &Connector{
+					CornerRadius: l.opts.CornerRadius,
+					Points:       r.loneChildPath(l, b),
+				}
 			} else {
-				l.connectors = append(l.connectors, &Connector{
+				c = &Connector{
+					CornerRadius: l.opts.CornerRadius,
 					Points: []Point{
 						// Start just above blurb
 						{X: b.TopHookX(), Y: b.TopPos - l.opts.LineGap},
-						// Move up by ChildDrop
-						{X: b.TopHookX(), Y: b.TopPos - l.opts.LineGap - l.opts.ChildDrop},
+						// Move up by ChildDrop, meeting any other children of the same birth
+						{X: l.junctionX(b), Y: l.rowTop(b.Row) - l.opts.LineGap - l.ChildDrop(b.Parent)},
 						// Move horizontally to centre of parent
-						{X: b.Parent.X(), Y: b.TopPos - l.opts.LineGap - l.opts.ChildDrop},
+						{X: l.descentX(b.Parent), Y: l.rowTop(b.Row) - l.opts.LineGap - l.ChildDrop(b.Parent)},
 						// Move up to centre of parent
-						{X: b.Parent.X(), Y: b.Parent.Bottom() + l.opts.LineGap},
+						{X: l.descentX(b.Parent), Y: l.stackBottom(b.Parent) + l.opts.LineGap},
 					},
+				}
+			}
+			c.Dashed = l.DashedConnector(b)
+			c.Children = []int{b.ID}
+			connectors = append(connectors, c)
+		}
+	}
+	return connectors
+}
+
+// straight reports whether the connector from child to its parent should be a vertical line, which
+// is when the child is the only child of a person and lies beneath them or, with SnapConnectors, the
+// only child of a relationship marker that lies beneath it.
+func (r *DefaultConnectorRouter) straight(l *DescendantLayout, child *Blurb) bool {
+	if child.Parent.FirstChild != child.Parent.LastChild || !r.underParent(child) || l.descentX(child.Parent) != child.Parent.X() {
+		return false
+	}
+	return child.Parent.ID > 0 || l.opts.SnapConnectors
+}
+
+// underParent reports whether a vertical line rising from the top of a child meets its parent.
+func (r *DefaultConnectorRouter) underParent(child *Blurb) bool {
+	x := child.TopHookX()
+	return x >= child.Parent.Left() && x <= child.Parent.Right()
+}
+
+// loneChildPath returns the points of a vertical line joining a lone child to the parent directly
+// above it. Any blurbs lying between them are skirted by stepping aside on whichever side is nearer,
+// keeping within the left edge of the chart, and the line rises beside them to meet the parent when
+// it can.
+func (r *DefaultConnectorRouter) loneChildPath(l *DescendantLayout, child *Blurb) []Point {
+	x := child.TopHookX()
+	top, bottom := l.stackBottom(child.Parent)+l.opts.LineGap, child.TopPos-l.opts.LineGap
+
+	var left, right, upper, lower Pixel
+	blocked := false
+	for _, b := range l.blurbs {
+		if b == child || b == child.Parent || x < b.Left() || x > b.Right() || b.TopPos >= bottom || b.Bottom() <= top {
+			continue
+		}
+		if !blocked {
+			left, right, upper, lower = b.Left(), b.Right(), b.TopPos, b.Bottom()
+			blocked = true
+			continue
+		}
+		left, right = min(left, b.Left()), max(right, b.Right())
+		upper, lower = min(upper, b.TopPos), max(lower, b.Bottom())
+	}
+	if !blocked {
+		return []Point{{X: x, Y: bottom}, {X: x, Y: top}}
+	}
+
+	// step aside on whichever side is nearer, unless only the other still meets the parent
+	meets := func(ax Pixel) bool { return ax >= child.Parent.Left() && ax <= child.Parent.Right() }
+	aside, other := left-l.opts.Hspace/2, right+l.opts.Hspace/2
+	if right-x < x-left || aside < 0 {
+		aside, other = other, aside
+	}
+	if !meets(aside) && meets(other) && other >= 0 {
+		aside = other
+	}
+	below := min(lower+l.opts.LineGap, bottom)
+	if meets(aside) {
+		// the line can rise beside the blurbs all the way to the parent
+		return slices.Compact([]Point{
+			{X: x, Y: bottom},
+			{X: x, Y: below},
+			{X: aside, Y: below},
+			{X: aside, Y: top},
+		})
+	}
+	above := max(upper-l.opts.LineGap, top)
+	return slices.Compact([]Point{
+		{X: x, Y: bottom},
+		{X: x, Y: below},
+		{X: aside, Y: below},
+		{X: aside, Y: above},
+		{X: x, Y: above},
+		{X: x, Y: top},
+	})
+}
+
+// siblingBars returns connectors that join the children of each family to a single horizontal bar
+// with a short vertical stub down to each child and one up to the parent. The bar is divided at each
+// stub so that the path from any child to its parent may be highlighted independently.
+func (r *DefaultConnectorRouter) siblingBars(l *DescendantLayout) []*Connector {
+	connectors := []*Connector{}
+	line := func(x1, y1, x2, y2 Pixel, children []int) *Connector {
+		c := &Connector{Points: []Point{{X: x1, Y: y1}, {X: x2, Y: y2}}, Children: children}
+		connectors = append(connectors, c)
+		return c
+	}
+
+	for row := 1; row < len(l.rows); row++ {
+		// group the children in the row by parent, keeping their left to right order
+		var parents []*Blurb
+		children := make(map[*Blurb][]*Blurb)
+		for _, b := range l.rows[row] {
+			if b.Parent == nil {
+				continue
+			}
+			if _, ok := children[b.Parent]; !ok {
+				parents = append(parents, b.Parent)
+			}
+			children[b.Parent] = append(children[b.Parent], b)
+		}
+
+		for _, p := range parents {
+			cs := children[p]
+			parentX := l.descentX(p)
+			if len(cs) == 1 && r.straight(l, cs[0]) {
+				// a lone child of a person is joined by a straight line
+				connectors = append(connectors, &Connector{
+					Points:   r.loneChildPath(l, cs[0]),
+					Dashed:   l.DashedConnector(cs[0]),
+					Children: []int{cs[0].ID},
 				})
+				continue
+			}
+
+			barY := l.rowTop(row) - l.opts.LineGap - l.ChildDrop(p)
+
+			// the stub that meets each child comes first in the path from that child to its parent
+			ids := make([]int, len(cs))
+			for i, c := range cs {
+				ids[i] = c.ID
+				childStub := line(c.TopHookX(), c.TopPos-l.opts.LineGap, l.junctionX(c), barY, []int{c.ID})
+				childStub.Dashed = l.DashedConnector(c)
+			}
+			line(parentX, barY, parentX, l.stackBottom(p)+l.opts.LineGap, ids)
+
+			// divide the bar at each point where a stub joins it
+			xs := []Pixel{parentX}
+			for _, c := range cs {
+				xs = append(xs, l.junctionX(c))
+			}
+			slices.Sort(xs)
+			xs = slices.Compact(xs)
+			for i := 0; i < len(xs)-1; i++ {
+				var via []int
+				for _, c := range cs {
+					cx := l.junctionX(c)
+					if xs[i] >= min(cx, parentX) && xs[i+1] <= max(cx, parentX) {
+						via = append(via, c.ID)
+					}
+				}
+				line(xs[i], barY, xs[i+1], barY, via)
+			}
+		}
+	}
+	return connectors
+}
+
+// gap returns the minimum horizontal space to leave between two adjacent blurbs in the same row.
+func (a *SpreadingDescendantArranger) gap(l *DescendantLayout, left, right *Blurb) Pixel {
+	var gap Pixel
+	switch {
+	case l.partners[left] == right || l.partners[right] == left:
+		// a couple joined by a line
+		gap = l.partnerGap()
+	case left.Parent != right.Parent:
+		// extra space between families
+		gap = max(l.opts.FamilyGap, l.opts.Hspace)
+	case left.Parent != nil && l.opts.ChildSpacing > 0:
+		// siblings
+		gap = l.opts.ChildSpacing
+	default:
+		gap = l.opts.Hspace
+	}
+	if left.Focus || right.Focus {
+		gap += l.opts.FocusMargin
+	}
+	return gap
+}
+
+// alignLoneChildren positions any child that is the only child of a person directly beneath them
+// so that the connector joining them is a straight vertical line. A child is only moved if it and
+// its descendants can be moved without encroaching on their neighbours.
+func (a *SpreadingDescendantArranger) alignLoneChildren(l *DescendantLayout) {
+	for row := range l.rows {
+		for _, b := range l.rows[row] {
+			if b.ID <= 0 || b.FirstChild == nil || b.FirstChild != b.LastChild || b.FirstChild.Parent != b {
+				continue
+			}
+			c := b.FirstChild
+			shift := b.X() - c.X()
+			if shift == 0 || !a.canShift(l, row+1, c, shift) {
+				continue
+			}
+			c.LeftPos += shift
+			a.shiftChildren(l, row+2, c, shift)
+		}
+	}
+}
+
+// alignMarkers moves the children of each relationship marker, along with their descendants, so
+// that they are centred beneath the marker. Closing up the gaps between children can leave them off
+// centre. If the children can't be moved without encroaching on their neighbours then the marker
+// is moved instead, if there is room.
+func (a *SpreadingDescendantArranger) alignMarkers(l *DescendantLayout) {
+	for row := range l.rows {
+		for i, b := range l.rows[row] {
+			if b.ID >= 0 || b.FirstChild == nil || l.stacked[b] {
+				continue
 			}
+			shift := b.X() - (b.FirstChild.Left()+b.LastChild.Right())/2
+			if shift == 0 {
+				continue
+			}
+			if family := a.family(l, row, b); a.canShiftFamily(l, row, family, shift) {
+				for r, bs := range family {
+					for _, o := range bs {
+						if r > 0 {
+							o.LeftPos += shift
+						}
+					}
+				}
+				continue
+			}
+
+			bs := l.rows[row]
+			if i > 0 && b.Left()-shift-bs[i-1].Right() < a.gap(l, bs[i-1], b) {
+				continue
+			}
+			if i < len(bs)-1 && bs[i+1].Left()-b.Right()+shift < a.gap(l, b, bs[i+1]) {
+				continue
+			}
+			b.LeftPos -= shift
+		}
+	}
+}
+
+// family returns the blurbs descended from the blurb b in the given row, indexed by the number of
+// rows beneath b, along with the spouses and relationship markers of those descendants. The first
+// element holds b alone.
+func (a *SpreadingDescendantArranger) family(l *DescendantLayout, row int, b *Blurb) [][]*Blurb {
+	family := [][]*Blurb{{b}}
+	in := map[*Blurb]bool{b: true}
+	for r := row + 1; r < len(l.rows); r++ {
+		var bs []*Blurb
+		for _, o := range l.rows[r] {
+			// spouses and markers are joined to a person who has a parent
+			k := o
+			for k != nil && k.Parent == nil {
+				k = l.kin[k]
+			}
+			if k != nil && in[l.rowBlurb(k.Parent)] {
+				bs = append(bs, o)
+			}
+		}
+		if len(bs) == 0 {
+			break
+		}
+		for _, o := range bs {
+			in[o] = true
+		}
+		family = append(family, bs)
+	}
+	return family
+}
+
+// canShiftFamily reports whether the descendants in a family returned by family can be moved
+// horizontally by shift without encroaching on the blurbs of other families.
+func (a *SpreadingDescendantArranger) canShiftFamily(l *DescendantLayout, row int, family [][]*Blurb, shift Pixel) bool {
+	for r := 1; r < len(family); r++ {
+		in := make(map[*Blurb]bool, len(family[r]))
+		for _, o := range family[r] {
+			in[o] = true
+		}
+		bs := l.rows[row+r]
+		for i := range bs {
+			if !in[bs[i]] {
+				continue
+			}
+			if i > 0 && !in[bs[i-1]] && bs[i].Left()+shift-bs[i-1].Right() < a.gap(l, bs[i-1], bs[i]) {
+				return false
+			}
+			if i < len(bs)-1 && !in[bs[i+1]] && bs[i+1].Left()-bs[i].Right()-shift < a.gap(l, bs[i], bs[i+1]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// canShift reports whether the blurb b in the given row, along with all of its descendants, can
+// be moved horizontally by shift without encroaching on neighbouring blurbs.
+func (a *SpreadingDescendantArranger) canShift(l *DescendantLayout, row int, b *Blurb, shift Pixel) bool {
+	if row > len(l.rows)-1 {
+		return true
+	}
+	bs := l.rows[row]
+	for i := range bs {
+		if bs[i] != b {
+			continue
+		}
+		if i > 0 && bs[i].Left()+shift-bs[i-1].Right() < a.gap(l, bs[i-1], bs[i]) {
+			return false
+		}
+		if i < len(bs)-1 && bs[i+1].Left()-bs[i].Right()-shift < a.gap(l, bs[i], bs[i+1]) {
+			return false
+		}
+		break
+	}
+
+	if b.FirstChild == nil || row+1 > len(l.rows)-1 {
+		return true
+	}
+	for _, c := range l.rows[row+1] {
+		if l.rowBlurb(c.Parent) == b && !a.canShift(l, row+1, c, shift) {
+			return false
 		}
 	}
+	return true
 }
 
 func (a *SpreadingDescendantArranger) shiftChildren(l *DescendantLayout, row int, parent *Blurb, shift Pixel) {
@@ -426,7 +2026,7 @@ func (a *SpreadingDescendantArranger) shiftChildren(l *DescendantLayout, row int
 	}
 	bs := l.rows[row]
 	for i := range bs {
-		if bs[i].Parent == parent {
+		if l.rowBlurb(bs[i].Parent) == parent {
 			bs[i].LeftPos += shift
 			a.shiftChildren(l, row+1, bs[i], shift)
 		}
@@ -456,14 +2056,33 @@ func (a *SpreadingDescendantArranger) centreBlurbs(l *DescendantLayout) {
 		maxY = max(maxY, b.Bottom())
 	}
 
+	if l.opts.ShowGenerationLabels {
+		// reserve a gutter on the left for the labels
+		gutter := Pixel(0)
+		for row := range l.rows {
+			gutter = max(gutter, textWidth([]rune(l.generationLabel(row)), l.opts.GenerationLabelStyle.FontSize))
+		}
+		if gutter > 0 {
+			minX -= gutter + l.opts.Hspace
+		}
+	}
+
+	// wrap the title to the width of the chart, widening the chart for any line that could not be wrapped
+	th, tw := l.wrapTitle(maxX-minX, l.opts.TitleWrapWidth, l.opts.TitleStyle, l.opts.NoteStyle)
+	maxX = max(maxX, minX+tw)
+
 	minX -= l.opts.Margin
 	maxX += l.opts.Margin
 	minY -= l.opts.Margin
 	maxY += l.opts.Margin
 
-	th, _ := titleDimensions(l.title, l.notes, l.opts.TitleStyle, l.opts.NoteStyle)
 	minY -= th
 
+	if len(l.footnotes) > 0 {
+		// reserve space for the footnotes, separated from the chart by a blank line
+		maxY += l.opts.NoteStyle.LineHeight * Pixel(len(l.footnotes)+1)
+	}
+
 	for _, bs := range l.rows {
 		for i := range bs {
 			if i == 0 {
@@ -472,7 +2091,38 @@ func (a *SpreadingDescendantArranger) centreBlurbs(l *DescendantLayout) {
 			bs[i].TopPos -= minY
 		}
 	}
+	for o := range l.stackedOn {
+		o.LeftPos -= minX
+		o.TopPos -= minY
+	}
+
+	// blurbs in the rows are absolutely positioned once arranged so they are moved clear of the
+	// left margin and any gutter for generation labels rather than padded
+	for _, bs := range l.rows {
+		for _, b := range bs {
+			if b.AbsolutePositioning {
+				b.LeftPos -= minX
+			}
+		}
+	}
 
 	l.width = maxX - minX
 	l.height = maxY - minY
 }
+
+// padWidth widens the layout to the given width, keeping the blurbs centred.
+func (l *DescendantLayout) padWidth(width Pixel) {
+	if width <= l.width {
+		return
+	}
+	extra := (width - l.width) / 2
+	for _, b := range l.blurbs {
+		b.LeftPos += extra
+	}
+	for _, c := range l.connectors {
+		for i := range c.Points {
+			c.Points[i].X += extra
+		}
+	}
+	l.width = width
+}
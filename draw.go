@@ -0,0 +1,390 @@
+package gtree
+
+import (
+	"sort"
+)
+
+// DrawOp is a primitive drawing operation produced by DrawOps. It is one of DrawText, DrawLine,
+// DrawRect, DrawCircle, DrawPolygon or DrawGroup.
+type DrawOp interface {
+	drawOp()
+}
+
+// DrawText draws a single line of text. The text occupies a line of Style.LineHeight pixels whose
+// top edge is at Y.
+type DrawText struct {
+	X      Pixel     // X is the horizontal position of the text, interpreted according to Anchor.
+	Y      Pixel     // Y is the vertical position of the top of the line of text.
+	Anchor Alignment // Anchor is the part of the text placed at X: its left edge, centre or right edge.
+	Text   string
+	Style  TextStyle // Style is the style of the text. Its Direction is resolved to DirectionLTR or DirectionRTL for the text.
+
+	Angle float64 // Angle is the angle in degrees by which the text is rotated clockwise about the middle of its line at X.
+}
+
+// DrawLine draws a line through a series of points, such as a connector between blurbs.
+type DrawLine struct {
+	Points       []Point
+	Width        float64 // Width is the width of the line.
+	Color        string
+	Dashed       bool  // Dashed indicates that the line should be drawn as a dashed line.
+	CornerRadius Pixel // CornerRadius is the radius of the arc used to round each corner. Zero gives square corners.
+}
+
+// DrawRect draws a rectangle, such as the border of a blurb or the background of the chart.
+type DrawRect struct {
+	X, Y          Pixel // X and Y are the position of the top left corner of the rectangle.
+	Width, Height Pixel
+	Radius        Pixel   // Radius is the radius of the rounded corners of the rectangle.
+	Fill          string  // Fill is the color the rectangle is filled with. Empty leaves it unfilled.
+	Stroke        string  // Stroke is the color of the outline of the rectangle. Empty draws no outline.
+	StrokeWidth   float64 // StrokeWidth is the width of the outline.
+	Dashed        bool    // Dashed indicates that the outline should be drawn as a dashed line.
+}
+
+// DrawCircle draws a filled circle, such as a union or DNA marker.
+type DrawCircle struct {
+	X, Y   Pixel // X and Y are the position of the centre of the circle.
+	Radius Pixel
+	Fill   string
+}
+
+// DrawPolygon draws a filled polygon, such as a diamond union marker.
+type DrawPolygon struct {
+	Points []Point
+	Fill   string
+}
+
+// DrawGroup draws a group of operations that make up a single element of the chart, such as a
+// blurb, that has a link or metadata. A family's relationship marker is grouped with the lines to
+// its children under the link of the family.
+type DrawGroup struct {
+	Ops  []DrawOp
+	Link string            // Link is the URL the element links to. Empty adds no link.
+	Meta map[string]string // Meta is the metadata of the element, such as a record id, as given by Blurb.Meta.
+}
+
+func (DrawText) drawOp()    {}
+func (DrawLine) drawOp()    {}
+func (DrawRect) drawOp()    {}
+func (DrawCircle) drawOp()  {}
+func (DrawPolygon) drawOp() {}
+func (DrawGroup) drawOp()   {}
+
+// DrawOps returns the primitive operations needed to draw a layout, in the order they should be
+// drawn, for use by renderers other than SVG such as an HTML canvas. The operations draw the same
+// chart as SVGWithOptions with the same options, using the coordinates of the layout without any
+// scaling or conversion of units. Blurbs are drawn in order of id. A blurb or connector with a
+// link or metadata is drawn as a DrawGroup holding its operations. The wedges of a fan chart are
+// not included since they can't be drawn with these operations. If opts is nil then the default
+// options are used.
+func DrawOps(lay Layout, opts *SVGOptions) []DrawOp {
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+
+	d := new(drawList)
+	if opts.Background != "" && opts.Background != "transparent" {
+		d.add(DrawRect{Width: lay.Width(), Height: lay.Height(), Fill: opts.Background})
+	}
+
+	if wl, ok := lay.(watermarker); ok {
+		if wm := wl.Watermark(); wm.Text != "" {
+			wm.Style.Direction = wm.Style.Direction.resolve(wm.Text)
+			d.add(DrawText{
+				X:      lay.Width() / 2,
+				Y:      lay.Height()/2 - wm.Style.LineHeight/2,
+				Anchor: AlignCentre,
+				Text:   wm.Text,
+				Style:  wm.Style,
+				Angle:  watermarkAngle(lay.Width(), lay.Height()),
+			})
+		}
+	}
+
+	y := lay.Margin()
+	titles := []TextElement{lay.Title()}
+	if tl, ok := lay.(titleLiner); ok {
+		titles = tl.TitleLines()
+	}
+	for _, title := range titles {
+		if title.Text == "" {
+			continue
+		}
+		d.text(lay.Margin(), y, AlignLeft, title.Text, title.Style)
+		y += title.Style.LineHeight
+	}
+	for _, note := range lay.Notes() {
+		d.text(lay.Margin(), y, AlignLeft, note.Text, note.Style)
+		y += note.Style.LineHeight
+	}
+
+	// a family's relationship marker is grouped with the lines to its children under a single link,
+	// as it is in SVG
+	connectors := lay.Connectors()
+	families, grouped := linkedFamilies(lay.Blurbs(), connectors)
+
+	blurbs := lay.Blurbs()
+	sort.Slice(blurbs, func(i, j int) bool { return blurbs[i].ID < blurbs[j].ID })
+	for _, b := range blurbs {
+		if grouped[b] {
+			continue
+		}
+		d.blurb(b, opts)
+	}
+
+	for _, c := range connectors {
+		if c.Link == "" {
+			d.add(connectorLine(c, opts))
+			continue
+		}
+		f, ok := families[c]
+		if !ok {
+			continue
+		}
+		var ops []DrawOp
+		if f.marker != nil {
+			ops = blurbOps(f.marker, opts)
+		}
+		for _, o := range f.connectors {
+			ops = append(ops, connectorLine(o, opts))
+		}
+		d.add(DrawGroup{Ops: ops, Link: f.link})
+	}
+
+	if ll, ok := lay.(labeler); ok {
+		for _, lb := range ll.GenerationLabels() {
+			d.text(lb.Left, lb.Y-lb.Style.LineHeight/2, AlignLeft, lb.Text, lb.Style)
+		}
+	}
+
+	if fl, ok := lay.(footnoter); ok {
+		footnotes := fl.Footnotes()
+		y := lay.Height() - lay.Margin()
+		for i := len(footnotes) - 1; i >= 0; i-- {
+			y -= footnotes[i].Style.LineHeight
+			d.text(lay.Margin(), y, AlignLeft, footnotes[i].Text, footnotes[i].Style)
+		}
+	}
+
+	return d.ops
+}
+
+// drawList collects drawing operations in the order they are to be drawn.
+type drawList struct {
+	ops []DrawOp
+}
+
+func (d *drawList) add(op DrawOp) {
+	d.ops = append(d.ops, op)
+}
+
+// text adds a line of text, resolving the direction of its style for the text.
+func (d *drawList) text(x, y Pixel, anchor Alignment, s string, style TextStyle) {
+	style.Direction = style.Direction.resolve(s)
+	d.add(DrawText{X: x, Y: y, Anchor: anchor, Text: s, Style: style})
+}
+
+// blurb adds the operations needed to draw a blurb, grouped with its link if it has one.
+func (d *drawList) blurb(b *Blurb, opts *SVGOptions) {
+	ops := blurbOps(b, opts)
+	if b.Link != "" {
+		d.add(DrawGroup{Ops: ops, Link: b.Link})
+		return
+	}
+	d.ops = append(d.ops, ops...)
+}
+
+// blurbOps returns the operations needed to draw a blurb without its link, grouped with its
+// metadata if it has any.
+func blurbOps(b *Blurb, opts *SVGOptions) []DrawOp {
+	ops := blurbShapes(b, opts)
+	for _, line := range blurbLines(b) {
+		ops = append(ops, line.DrawText)
+	}
+	ops = append(ops, blurbMarks(b, opts)...)
+	if len(b.Meta) > 0 {
+		return []DrawOp{DrawGroup{Ops: ops, Meta: b.Meta}}
+	}
+	return ops
+}
+
+// linkedFamily is a family whose relationship marker and connectors are drawn under a single link.
+type linkedFamily struct {
+	link       string
+	marker     *Blurb // marker is the blurb the children of the family descend from, or nil if it is drawn on its own
+	connectors []*Connector
+}
+
+// linkedFamilies groups the connectors that have a link by the family whose children they join to
+// their parents, keyed by the first connector of each family. The blurb that the children descend
+// from, such as the relationship marker, joins the group when it has the same link. Families are
+// told apart by that blurb rather than by their link, so families sharing a link are kept apart.
+// The blurbs drawn as part of a group are also returned.
+func linkedFamilies(blurbs []*Blurb, connectors []*Connector) (map[*Connector]*linkedFamily, map[*Blurb]bool) {
+	byID := make(map[int]*Blurb, len(blurbs))
+	for _, b := range blurbs {
+		byID[b.ID] = b
+	}
+
+	families := make(map[*Connector]*linkedFamily)
+	grouped := make(map[*Blurb]bool)
+	byParent := make(map[*Blurb]*linkedFamily)
+	for _, c := range connectors {
+		if c.Link == "" {
+			continue
+		}
+		var parent *Blurb
+		if len(c.Children) > 0 {
+			if child, ok := byID[c.Children[0]]; ok {
+				parent = child.Parent
+			}
+		}
+		if f, ok := byParent[parent]; ok && parent != nil && f.link == c.Link {
+			f.connectors = append(f.connectors, c)
+			continue
+		}
+		f := &linkedFamily{link: c.Link, connectors: []*Connector{c}}
+		if parent != nil && parent.Link == c.Link && !grouped[parent] {
+			f.marker = parent
+			grouped[parent] = true
+		}
+		if parent != nil {
+			byParent[parent] = f
+		}
+		families[c] = f
+	}
+	return families, grouped
+}
+
+// The functions below place the parts of a blurb and a connector. Both DrawOps and the SVG
+// renderer draw from them so that the two always agree.
+
+// blurbShapes returns the operations that draw any border and union marker shape of a blurb,
+// which lie beneath its text.
+func blurbShapes(b *Blurb, opts *SVGOptions) []DrawOp {
+	var ops []DrawOp
+	const pad = 4
+	border := DrawRect{X: b.Left() - pad, Y: b.TopPos - pad, Width: b.Width + 2*pad, Height: b.Height + 2*pad, Radius: pad, StrokeWidth: 2}
+	switch {
+	case b.Highlight:
+		border.Stroke = highlightColor(opts)
+		ops = append(ops, border)
+	case b.Focus:
+		border.Stroke = b.HeadingTexts.Style.Color
+		ops = append(ops, border)
+	case b.Collapsed:
+		// a dashed border indicates that there is more of the tree to be seen
+		border.Stroke, border.StrokeWidth, border.Dashed = "#999", 1, true
+		ops = append(ops, border)
+	}
+
+	if b.Marker != UnionEquals {
+		// centred in the first heading line
+		cx, cy, size := b.X(), b.TopPos+b.HeadingTexts.Style.LineHeight/2, b.HeadingTexts.Style.FontSize
+		switch b.Marker {
+		case UnionDot:
+			ops = append(ops, DrawCircle{X: cx, Y: cy, Radius: size / 4, Fill: b.HeadingTexts.Style.Color})
+		case UnionDiamond:
+			r := size / 3
+			ops = append(ops, DrawPolygon{Points: []Point{{cx, cy - r}, {cx + r, cy}, {cx, cy + r}, {cx - r, cy}}, Fill: b.HeadingTexts.Style.Color})
+		}
+	}
+	return ops
+}
+
+// blurbLine is a line of text placed within a blurb.
+type blurbLine struct {
+	DrawText
+	InColumn bool // InColumn indicates that the line is placed within a column rather than directly beneath the previous line.
+}
+
+// blurbLines returns the placement of each heading and detail line of a blurb. The LineHeight of
+// the style of each line is the height of the row it occupies in its section.
+func blurbLines(b *Blurb) []blurbLine {
+	var lines []blurbLine
+	sectionTop := b.TopPos
+	for _, sec := range []TextSection{b.HeadingTexts, b.DetailTexts} {
+		pitch := sec.LineHeight()
+		for i, line := range sec.Lines {
+			row, left, right := i, b.Left(), b.Right()
+			if sec.Columns > 1 {
+				var col int
+				col, row = sec.Cell(i)
+				left += Pixel(col) * (sec.ColumnWidth + sec.ColumnGap)
+				right = left + sec.ColumnWidth
+			}
+			style := sec.LineStyle(i)
+			style.LineHeight = pitch
+			style.Direction = style.Direction.resolve(line)
+			dt := DrawText{Y: sectionTop + Pixel(row)*pitch, Text: line, Style: style}
+			switch {
+			case b.CentreText || sec.Align == AlignCentre:
+				dt.X, dt.Anchor = (left+right)/2, AlignCentre
+			case sec.Align == AlignRight:
+				dt.X, dt.Anchor = right, AlignRight
+			default:
+				dt.X, dt.Anchor = left, AlignLeft
+			}
+			lines = append(lines, blurbLine{DrawText: dt, InColumn: sec.Columns > 1})
+		}
+		sectionTop += pitch * Pixel(sec.Rows())
+	}
+	return lines
+}
+
+// blurbMarks returns the operations that draw any sex symbol, note references and DNA marker
+// that follow the first heading line of a blurb.
+func blurbMarks(b *Blurb, opts *SVGOptions) []DrawOp {
+	if b.CentreText {
+		return nil
+	}
+	var ops []DrawOp
+	heading := b.HeadingTexts.Style
+
+	if opts.SexSymbols && b.Sex.Symbol() != "" {
+		// within the first heading line
+		fontSize := heading.FontSize * 3 / 4
+		style := TextStyle{FontSize: fontSize, LineHeight: heading.LineHeight, Color: heading.Color}
+		ops = append(ops, DrawText{X: b.Left() + b.SexSymbolOffset(), Y: b.TopPos, Anchor: AlignLeft, Text: b.Sex.Symbol(), Style: style})
+	}
+
+	if len(b.NoteRefs) > 0 {
+		// raised above the first heading line
+		fontSize := b.NoteRefFontSize()
+		style := TextStyle{FontSize: fontSize, LineHeight: fontSize, Color: heading.Color}
+		ops = append(ops, DrawText{X: b.Left() + b.NoteRefOffset(), Y: b.TopPos - fontSize, Anchor: AlignLeft, Text: b.NoteRefText(), Style: style})
+	}
+
+	if b.DNATested {
+		// centred in the first heading line, after any note numbers
+		r := heading.FontSize / 4
+		color := opts.DNAMarkerColor
+		if color == "" {
+			color = heading.Color
+		}
+		ops = append(ops, DrawCircle{X: b.Left() + b.DNAMarkerOffset() + r, Y: b.TopPos + heading.LineHeight/2, Radius: r, Fill: color})
+	}
+	return ops
+}
+
+// connectorLine returns the line that draws a connector.
+func connectorLine(c *Connector, opts *SVGOptions) DrawLine {
+	line := DrawLine{Points: c.Points, Width: 2.375, Color: "#000000", Dashed: c.Dashed, CornerRadius: c.CornerRadius}
+	if c.Color != "" {
+		line.Color = c.Color
+	}
+	if c.Highlight {
+		line.Color, line.Width = highlightColor(opts), 4.75
+	}
+	return line
+}
+
+// highlightColor returns the color used to draw highlighted blurbs and connectors, falling back
+// to the default when the options do not give one.
+func highlightColor(opts *SVGOptions) string {
+	if opts.HighlightColor == "" {
+		return DefaultHighlightColor
+	}
+	return opts.HighlightColor
+}
@@ -0,0 +1,314 @@
+package gtree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDrawOps(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+	ops := DrawOps(lay, nil)
+
+	if len(ops) == 0 {
+		t.Fatalf("got no operations")
+	}
+	if diff := cmp.Diff(DrawRect{Width: lay.Width(), Height: lay.Height(), Fill: "white"}, ops[0]); diff != "" {
+		t.Errorf("background mismatch (-want +got):\n%s", diff)
+	}
+
+	texts := make(map[string]DrawText)
+	var lines []DrawLine
+	for _, op := range ops {
+		switch op := op.(type) {
+		case DrawText:
+			texts[op.Text] = op
+		case DrawLine:
+			lines = append(lines, op)
+		}
+	}
+
+	// every line of every blurb is drawn at the position of the blurb
+	for _, b := range lay.blurbs {
+		for i, line := range append(b.HeadingTexts.Lines, b.DetailTexts.Lines...) {
+			dt, ok := texts[line]
+			if !ok {
+				t.Errorf("blurb %d: no text drawn for %q", b.ID, line)
+				continue
+			}
+			if i == 0 && dt.Y != b.TopPos {
+				t.Errorf("blurb %d: got first line at %d, wanted %d", b.ID, dt.Y, b.TopPos)
+			}
+			if b.CentreText {
+				if dt.X != b.X() || dt.Anchor != AlignCentre {
+					t.Errorf("blurb %d: got centred text at %d anchored %v, wanted %d", b.ID, dt.X, dt.Anchor, b.X())
+				}
+			} else if dt.X != b.Left() || dt.Anchor != AlignLeft {
+				t.Errorf("blurb %d: got text at %d anchored %v, wanted %d", b.ID, dt.X, dt.Anchor, b.Left())
+			}
+		}
+	}
+
+	// every connector is drawn as a line through the same points
+	if got, want := len(lines), len(lay.Connectors()); got != want {
+		t.Fatalf("got %d lines, wanted %d", got, want)
+	}
+	for i, c := range lay.Connectors() {
+		if diff := cmp.Diff(c.Points, lines[i].Points); diff != "" {
+			t.Errorf("line %d points mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestDrawOpsMarkers(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	opts.UnionMarker = UnionDiamond
+	lay := onePersonWithSpouseAndChildren.Layout(opts)
+	lay.blurbs[3].Highlight = true
+
+	var polygons, borders int
+	for _, op := range DrawOps(lay, &SVGOptions{HighlightColor: "red"}) {
+		switch op := op.(type) {
+		case DrawPolygon:
+			polygons++
+		case DrawRect:
+			if op.Stroke != "red" {
+				t.Errorf("got border color %q, wanted red", op.Stroke)
+			}
+			borders++
+		}
+	}
+	if polygons != 1 {
+		t.Errorf("got %d diamond markers, wanted 1", polygons)
+	}
+	if borders != 1 {
+		t.Errorf("got %d borders, wanted 1 for the highlighted blurb and none for the transparent background", borders)
+	}
+}
+
+func TestDrawOpsHighlightDefault(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+	lay.blurbs[3].Highlight = true
+	lay.connectors[0].Highlight = true
+
+	// options without a highlight color, such as the zero value, use the default
+	var borders, lines int
+	for _, op := range DrawOps(lay, &SVGOptions{}) {
+		switch op := op.(type) {
+		case DrawRect:
+			if op.Stroke != DefaultHighlightColor {
+				t.Errorf("got border color %q, wanted %q", op.Stroke, DefaultHighlightColor)
+			}
+			borders++
+		case DrawLine:
+			if op.Width > 4 {
+				if op.Color != DefaultHighlightColor {
+					t.Errorf("got highlighted line color %q, wanted %q", op.Color, DefaultHighlightColor)
+				}
+				lines++
+			}
+		}
+	}
+	if borders != 1 || lines != 1 {
+		t.Errorf("got %d highlighted borders and %d highlighted lines, wanted 1 of each", borders, lines)
+	}
+
+	s, err := SVGWithOptions(lay, &SVGOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(s, `stroke="`+DefaultHighlightColor+`"`) {
+		t.Errorf("highlighted border not drawn with %s", DefaultHighlightColor)
+	}
+	if !strings.Contains(s, "stroke:"+DefaultHighlightColor+";") {
+		t.Errorf("highlighted connector not drawn with %s", DefaultHighlightColor)
+	}
+}
+
+// svgParts describes each piece of text, shape, line, link and metadata group in an SVG
+// document, with text placed by its baseline, so that it may be compared with drawing operations.
+func svgParts(t *testing.T, s string) []string {
+	t.Helper()
+	attrs := func(se xml.StartElement) map[string]string {
+		m := make(map[string]string)
+		for _, a := range se.Attr {
+			m[a.Name.Local] = a.Value
+		}
+		return m
+	}
+
+	var parts []string
+	var text, span map[string]string // the current text element and the tspan within it, or the text element itself until a tspan starts
+	var y int                        // the baseline of the current line
+	var content string
+	dec := xml.NewDecoder(strings.NewReader(s))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("output is not well formed: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			a := attrs(tok)
+			switch tok.Name.Local {
+			case "text":
+				text, span, content = a, a, ""
+				y, _ = strconv.Atoi(a["y"])
+			case "tspan":
+				span, content = a, ""
+				if a["text-anchor"] == "" {
+					a["text-anchor"] = text["text-anchor"]
+				}
+				if v, ok := a["dy"]; ok {
+					dy, _ := strconv.Atoi(v)
+					y += dy
+				} else {
+					y, _ = strconv.Atoi(a["y"])
+				}
+			case "rect":
+				if a["width"] != "100%" {
+					parts = append(parts, fmt.Sprintf("rect %s,%s %sx%s rx=%s fill=%s stroke=%s/%s dash=%v", a["x"], a["y"], a["width"], a["height"], a["rx"], a["fill"], a["stroke"], a["stroke-width"], a["stroke-dasharray"] != ""))
+				}
+			case "circle":
+				parts = append(parts, fmt.Sprintf("circle %s,%s r=%s fill=%s", a["cx"], a["cy"], a["r"], a["fill"]))
+			case "polygon":
+				parts = append(parts, fmt.Sprintf("polygon %s fill=%s", a["points"], a["fill"]))
+			case "path":
+				stroke, _, _ := strings.Cut(strings.SplitN(a["style"], "stroke:", 2)[1], ";")
+				parts = append(parts, fmt.Sprintf("line %s stroke=%s", a["d"], stroke))
+			case "a":
+				parts = append(parts, "link "+a["href"])
+			case "g":
+				var meta []string
+				for _, attr := range tok.Attr {
+					meta = append(meta, attr.Name.Local+"="+attr.Value)
+				}
+				parts = append(parts, "meta "+strings.Join(meta, " "))
+			}
+		case xml.CharData:
+			content += string(tok)
+		case xml.EndElement:
+			if (tok.Name.Local != "tspan" && tok.Name.Local != "text") || span == nil {
+				continue
+			}
+			if content = strings.TrimSpace(content); content != "" {
+				parts = append(parts, fmt.Sprintf("text %q x=%s y=%d anchor=%s size=%s fill=%s", content, span["x"], y, span["text-anchor"], span["font-size"], span["fill"]))
+			}
+			span = nil
+		}
+	}
+	sort.Strings(parts)
+	return parts
+}
+
+// opParts describes drawing operations in the same way as svgParts describes an SVG document.
+func opParts(ops []DrawOp) []string {
+	var parts []string
+	for _, op := range ops {
+		switch op := op.(type) {
+		case DrawText:
+			if strings.TrimSpace(op.Text) == "" {
+				continue
+			}
+			dir := op.Style.Direction.resolve(op.Text)
+			parts = append(parts, fmt.Sprintf("text %q x=%d y=%d anchor=%s size=%dpx fill=%s", op.Text, op.X, op.Y+op.Style.LineHeight, svgAnchor(op.Anchor, dir), op.Style.FontSize, op.Style.Color))
+		case DrawRect:
+			if op.Fill == "" {
+				dash := ""
+				if op.Dashed {
+					dash = "4,3"
+				}
+				parts = append(parts, fmt.Sprintf("rect %d,%d %dx%d rx=%d fill=none stroke=%s/%v dash=%v", op.X, op.Y, op.Width, op.Height, op.Radius, op.Stroke, op.StrokeWidth, dash != ""))
+			}
+		case DrawCircle:
+			parts = append(parts, fmt.Sprintf("circle %d,%d r=%d fill=%s", op.X, op.Y, op.Radius, op.Fill))
+		case DrawPolygon:
+			var points []string
+			for _, p := range op.Points {
+				points = append(points, fmt.Sprintf("%d,%d", p.X, p.Y))
+			}
+			parts = append(parts, fmt.Sprintf("polygon %s fill=%s", strings.Join(points, " "), op.Fill))
+		case DrawLine:
+			parts = append(parts, fmt.Sprintf("line %s stroke=%s", connectorPath(&Connector{Points: op.Points, CornerRadius: op.CornerRadius}), op.Color))
+		case DrawGroup:
+			if op.Link != "" {
+				parts = append(parts, "link "+op.Link)
+			}
+			if len(op.Meta) > 0 {
+				keys := make([]string, 0, len(op.Meta))
+				for k := range op.Meta {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				var meta []string
+				for _, k := range keys {
+					meta = append(meta, "data-"+k+"="+op.Meta[k])
+				}
+				parts = append(parts, "meta "+strings.Join(meta, " "))
+			}
+			parts = append(parts, opParts(op.Ops)...)
+		}
+	}
+	sort.Strings(parts)
+	return parts
+}
+
+func TestDrawOpsMatchSVG(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Anne Brown"},
+			Details:  []string{"b. 1820", "d. 1901", "Tredegar"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"Bill Green"}, Details: []string{"b. 1818"}},
+					Link:  "https://example.com/f1",
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"Cara Brown"}, Details: []string{"b. 1850"}, Meta: map[string]string{"record": "I3"}},
+						{ID: 4, Headings: []string{"Dan Brown"}, Details: []string{"b. 1852", "d. 1853"}, DetailStyles: []*TextStyle{nil, {Color: "#808080", LineHeight: 30}}},
+					},
+				},
+			},
+		},
+	}
+
+	align := DefaultLayoutOptions()
+	align.DetailAlign = AlignRight
+	align.UnionMarker = UnionDot
+	columns := DefaultLayoutOptions()
+	columns.DetailColumns = 2
+	columns.UnionMarker = UnionDiamond
+	columns.FocusID = 1
+	columns.ConnectorColors = []string{"#aa0000", "#00aa00"}
+
+	for name, opts := range map[string]*LayoutOptions{"default": DefaultLayoutOptions(), "align": align, "columns": columns} {
+		t.Run(name, func(t *testing.T) {
+			lay := ch.Layout(opts)
+			lay.blurbs[1].Sex = Female
+			lay.blurbs[1].NoteRefs = []int{1, 2}
+			lay.blurbs[1].DNATested = true
+			lay.blurbs[3].Highlight = true
+			lay.blurbs[4].Collapsed = true
+			lay.Connectors()[0].Highlight = true
+
+			svgOpts := DefaultSVGOptions()
+			svgOpts.SexSymbols = true
+			s, err := SVGWithOptions(lay, svgOpts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(svgParts(t, s), opParts(DrawOps(lay, svgOpts))); diff != "" {
+				t.Errorf("drawing operations differ from svg (-svg +ops):\n%s", diff)
+			}
+		})
+	}
+}
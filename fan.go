@@ -0,0 +1,335 @@
+package gtree
+
+import (
+	"math"
+	"sort"
+)
+
+// FanChart represents a chart of ancestors drawn as a fan, with the root person at the centre and
+// each successive generation in a ring of wedges around the one before.
+type FanChart struct {
+	Title string
+	Notes []string
+	Root  *AncestorPerson
+}
+
+// FanLayoutOptions defines various layout parameters for rendering the fan chart.
+type FanLayoutOptions struct {
+	Debug bool
+
+	Margin    Pixel   // margin to add to entire drawing
+	LineWidth Pixel   // width of the lines drawn around each wedge
+	LineColor string  // LineColor is the color of the lines drawn around each wedge.
+	Sweep     float64 // Sweep is the angle in degrees covered by the fan, such as 180 for a half fan or 270 for a three quarter fan. Angles outside the range 0 to 360 are treated as 180.
+
+	CentreRadius Pixel // CentreRadius is the radius of the sector at the centre of the fan holding the root person.
+	RingWidth    Pixel // RingWidth is the distance between the inner and outer edges of the ring holding each generation.
+
+	MaxGeneration int // MaxGeneration is the number of generations to include, counting the root person as the first. Zero includes every generation.
+
+	TitleStyle TextStyle // TitleStyle is the style of the font to use for the title of the chart.
+	NoteStyle  TextStyle // NoteStyle is the style of the font to use for the notes of the chart.
+
+	TitleWrapWidth Pixel // TitleWrapWidth is the maximum width of the title and notes before wrapping to a new line. Zero wraps them to the width of the chart.
+
+	HeadingStyle TextStyle // HeadingStyle is the style of the font to use for the first line of each wedge.
+	DetailStyle  TextStyle // DetailStyle is the style of the font to use for the subsequent lines of each wedge after the first.
+
+	ShowUnknownAncestors bool      // ShowUnknownAncestors indicates whether placeholder wedges should be shown for missing parents, up to the depth of the chart.
+	UnknownText          string    // UnknownText is the text to show in placeholder wedges for unknown ancestors.
+	UnknownStyle         TextStyle // UnknownStyle is the style of the font to use for placeholder wedges for unknown ancestors.
+}
+
+// DefaultFanLayoutOptions returns the default layout options for rendering the fan chart.
+func DefaultFanLayoutOptions() *FanLayoutOptions {
+	return &FanLayoutOptions{
+		Margin:    16,
+		LineWidth: 1,
+		LineColor: "#000",
+		Sweep:     180,
+
+		CentreRadius: 96,
+		RingWidth:    96,
+
+		TitleStyle: TextStyle{
+			FontSize:   40,
+			LineHeight: 42,
+			Color:      "#000",
+		},
+		NoteStyle: TextStyle{
+			FontSize:   20,
+			LineHeight: 22,
+			Color:      "#000",
+		},
+		HeadingStyle: TextStyle{
+			FontSize:   14,
+			LineHeight: 16,
+			Color:      "#000",
+		},
+		DetailStyle: TextStyle{
+			FontSize:   11,
+			LineHeight: 13,
+			Color:      "#000",
+		},
+
+		UnknownText: "Unknown",
+		UnknownStyle: TextStyle{
+			FontSize:   14,
+			LineHeight: 16,
+			Color:      "#999",
+		},
+	}
+}
+
+// Wedge is the segment of a ring in a fan chart that holds one person. Angles are measured in
+// degrees clockwise from straight up, so a half fan runs from -90 to 90.
+type Wedge struct {
+	ID           int
+	Generation   int // Generation is the ring the wedge is in, counting the root person at the centre as 0.
+	Index        int // Index is the position of the wedge in its ring, counting from 0 at the start of the sweep. The parents of the person in the wedge at index i are at indexes 2i and 2i+1 of the next ring.
+	HeadingTexts TextSection
+	DetailTexts  TextSection
+	Sex          Sex               // Sex is the sex of the person represented by the wedge
+	Meta         map[string]string // Meta is arbitrary data about the person represented by the wedge, written by SVG as data-* attributes on the group containing the wedge
+
+	Centre      Point   // Centre is the centre of the circle the wedge is cut from.
+	InnerRadius Pixel   // InnerRadius is the radius of the inner edge of the wedge. It is zero for the root person.
+	OuterRadius Pixel   // OuterRadius is the radius of the outer edge of the wedge.
+	StartAngle  float64 // StartAngle is the angle of the edge of the wedge nearest the start of the sweep.
+	EndAngle    float64 // EndAngle is the angle of the edge of the wedge nearest the end of the sweep.
+
+	TextPos   Point   // TextPos is the position of the middle of the text of the wedge.
+	TextAngle float64 // TextAngle is the angle in degrees by which the text is rotated clockwise about TextPos so that it runs along the ring, or along the radius of a narrow wedge, and reads upright.
+}
+
+// point returns the position at distance r from the centre of the wedge's circle at angle a.
+func (w *Wedge) point(r float64, a float64) (float64, float64) {
+	rad := a * math.Pi / 180
+	return float64(w.Centre.X) + r*math.Sin(rad), float64(w.Centre.Y) - r*math.Cos(rad)
+}
+
+// Layout generates the layout for the fan chart based on the provided options.
+func (ch *FanChart) Layout(opts *FanLayoutOptions) *FanLayout {
+	if opts == nil {
+		opts = DefaultFanLayoutOptions()
+	}
+
+	l := new(FanLayout)
+	l.opts = *opts
+	if l.opts.Sweep <= 0 || l.opts.Sweep > 360 {
+		l.opts.Sweep = 180
+	}
+	l.title = ch.Title
+	l.notes = ch.Notes
+
+	if ch.Root == nil {
+		// an empty chart still occupies its margins and any title
+		titleHeight, titleWidth := l.wrapTitle(0, l.opts.TitleWrapWidth, l.opts.TitleStyle, l.opts.NoteStyle)
+		l.width = l.opts.Margin*2 + titleWidth
+		l.height = l.opts.Margin*2 + titleHeight
+		return l
+	}
+
+	l.gens = (&AncestorChart{}).countGenerations(ch.Root)
+	if l.opts.MaxGeneration > 0 {
+		l.gens = min(l.gens, l.opts.MaxGeneration)
+	}
+	l.addPerson(ch.Root, 0, 0)
+	sort.Slice(l.wedges, func(i, j int) bool {
+		if l.wedges[i].Generation != l.wedges[j].Generation {
+			return l.wedges[i].Generation < l.wedges[j].Generation
+		}
+		return l.wedges[i].Index < l.wedges[j].Index
+	})
+
+	// find the extent of the fan around its centre from the ends of the sweep and any of the
+	// points straight up, down, left or right of the centre that it passes
+	radius := float64(l.opts.CentreRadius + Pixel(l.gens-1)*l.opts.RingWidth)
+	minX, maxX, minY, maxY := 0.0, 0.0, 0.0, 0.0
+	extend := func(a float64) {
+		rad := a * math.Pi / 180
+		x, y := radius*math.Sin(rad), -radius*math.Cos(rad)
+		minX, maxX, minY, maxY = min(minX, x), max(maxX, x), min(minY, y), max(maxY, y)
+	}
+	extend(-l.opts.Sweep / 2)
+	extend(l.opts.Sweep / 2)
+	for a := -180.0; a <= 180; a += 90 {
+		if math.Abs(a) <= l.opts.Sweep/2 {
+			extend(a)
+		}
+	}
+	fanWidth, fanHeight := Pixel(math.Ceil(maxX-minX)), Pixel(math.Ceil(maxY-minY))
+
+	titleHeight, titleWidth := l.wrapTitle(fanWidth, l.opts.TitleWrapWidth, l.opts.TitleStyle, l.opts.NoteStyle)
+	contentWidth := max(fanWidth, titleWidth)
+	l.width = contentWidth + l.opts.Margin*2
+	l.height = fanHeight + titleHeight + l.opts.Margin*2
+
+	centre := Point{
+		X: l.opts.Margin + (contentWidth-fanWidth)/2 + Pixel(math.Round(-minX)),
+		Y: l.opts.Margin + titleHeight + Pixel(math.Round(-minY)),
+	}
+	for _, w := range l.wedges {
+		w.Centre = centre
+		w.TextPos.X += centre.X
+		w.TextPos.Y += centre.Y
+	}
+
+	return l
+}
+
+// FanLayout represents the layout of a fan chart, including dimensions and layout options.
+type FanLayout struct {
+	chartText
+
+	opts     FanLayoutOptions
+	width    Pixel
+	height   Pixel
+	wedges   []*Wedge
+	gens     int // number of generations in the chart
+	unknowns int // number of placeholder wedges added for unknown ancestors
+}
+
+// Width returns the width of the layout.
+func (l *FanLayout) Width() Pixel { return l.width }
+
+// Height returns the height of the layout.
+func (l *FanLayout) Height() Pixel { return l.height }
+
+// Margin returns the margin of the layout.
+func (l *FanLayout) Margin() Pixel { return l.opts.Margin }
+
+// Title returns the title element of the layout.
+func (l *FanLayout) Title() TextElement {
+	return TextElement{
+		Text:  l.title,
+		Style: l.opts.TitleStyle,
+	}
+}
+
+// Notes returns the notes elements of the layout, wrapped to fit the width of the chart.
+func (l *FanLayout) Notes() []TextElement {
+	return textElements(l.noteLines, l.opts.NoteStyle)
+}
+
+// TitleLines returns the lines of the title of the layout once wrapped to fit the width of the chart.
+func (l *FanLayout) TitleLines() []TextElement { return textElements(l.titleLines, l.opts.TitleStyle) }
+
+// Blurbs returns the blurbs in the layout. A fan chart has none since each person is drawn in a
+// wedge, returned by Wedges.
+func (l *FanLayout) Blurbs() []*Blurb { return nil }
+
+// Connectors returns the connectors in the layout. A fan chart has none since the wedges of
+// parents adjoin the wedge of their child.
+func (l *FanLayout) Connectors() []*Connector { return nil }
+
+// Wedges returns all the wedges in the layout, ordered by generation and then by index.
+func (l *FanLayout) Wedges() []*Wedge { return l.wedges }
+
+// Debug reports whether the layout is in debug mode.
+func (l *FanLayout) Debug() bool { return l.opts.Debug }
+
+// Options returns the options used to generate the layout.
+func (l *FanLayout) Options() FanLayoutOptions { return l.opts }
+
+// addPerson adds a person and their parents to the layout in the wedge at the given index of the
+// ring for the generation.
+func (l *FanLayout) addPerson(p *AncestorPerson, gen int, index int) {
+	l.addWedge(p.ID, p.Details, gen, index, p.Sex, p.Meta)
+	if gen+1 >= l.gens {
+		return
+	}
+	for i, parent := range []*AncestorPerson{p.Father, p.Mother} {
+		if parent != nil {
+			l.addPerson(parent, gen+1, index*2+i)
+		} else if l.opts.ShowUnknownAncestors {
+			l.addUnknown(gen+1, index*2+i)
+		}
+	}
+}
+
+// addUnknown adds a placeholder for an unknown ancestor, and its parents, to the layout in the
+// wedge at the given index of the ring for the generation. Placeholders are assigned negative ids.
+func (l *FanLayout) addUnknown(gen int, index int) {
+	l.unknowns++
+	l.addWedge(-l.unknowns, []string{l.opts.UnknownText}, gen, index, UnknownSex, nil)
+	if gen+1 >= l.gens {
+		return
+	}
+	l.addUnknown(gen+1, index*2)
+	l.addUnknown(gen+1, index*2+1)
+}
+
+// addWedge adds the wedge for a person to the layout, placed relative to the centre of the fan.
+func (l *FanLayout) addWedge(id int, texts []string, gen int, index int, sex Sex, meta map[string]string) {
+	headingStyle := l.opts.HeadingStyle
+	if id < 0 {
+		headingStyle = l.opts.UnknownStyle
+	}
+	w := &Wedge{
+		ID:         id,
+		Generation: gen,
+		Index:      index,
+		HeadingTexts: TextSection{
+			Lines: []string{},
+			Style: headingStyle,
+			Align: AlignCentre,
+		},
+		DetailTexts: TextSection{
+			Lines: []string{},
+			Style: l.opts.DetailStyle,
+			Align: AlignCentre,
+		},
+		Sex:  sex,
+		Meta: meta,
+	}
+	for i, text := range texts {
+		if text == "" {
+			continue
+		}
+		if i == 0 {
+			w.HeadingTexts.Lines = append(w.HeadingTexts.Lines, text)
+		} else {
+			w.DetailTexts.Lines = append(w.DetailTexts.Lines, text)
+		}
+	}
+
+	span := l.opts.Sweep / float64(int(1)<<gen)
+	w.StartAngle = -l.opts.Sweep/2 + float64(index)*span
+	w.EndAngle = w.StartAngle + span
+	mid := (w.StartAngle + w.EndAngle) / 2
+	if gen == 0 {
+		w.OuterRadius = l.opts.CentreRadius
+	} else {
+		w.InnerRadius = l.opts.CentreRadius + Pixel(gen-1)*l.opts.RingWidth
+		w.OuterRadius = w.InnerRadius + l.opts.RingWidth
+	}
+
+	// the text sits halfway across the wedge, or at the centre of a complete circle
+	r := float64(w.InnerRadius+w.OuterRadius) / 2
+	if gen == 0 && l.opts.Sweep >= 360 {
+		r = 0
+	}
+	x, y := w.point(r, mid)
+	w.TextPos = Point{X: Pixel(math.Round(x)), Y: Pixel(math.Round(y))}
+
+	switch {
+	case gen == 0:
+		// the root person is written horizontally
+	case r*span*math.Pi/180 < float64(l.opts.RingWidth):
+		// a wedge narrower than it is deep is written along its radius
+		if mid >= 0 {
+			w.TextAngle = mid - 90
+		} else {
+			w.TextAngle = mid + 90
+		}
+	case mid > 90:
+		w.TextAngle = mid - 180
+	case mid < -90:
+		w.TextAngle = mid + 180
+	default:
+		w.TextAngle = mid
+	}
+
+	l.wedges = append(l.wedges, w)
+}
@@ -0,0 +1,179 @@
+package gtree
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// fanAncestors returns a person with every ancestor known for the given number of generations.
+func fanAncestors(gens int) *AncestorPerson {
+	id := 0
+	var person func(gen int) *AncestorPerson
+	person = func(gen int) *AncestorPerson {
+		id++
+		p := &AncestorPerson{ID: id, Details: []string{fmt.Sprintf("Person %d", id), "b. 1900"}}
+		if gen+1 < gens {
+			p.Father = person(gen + 1)
+			p.Mother = person(gen + 1)
+		}
+		return p
+	}
+	return person(0)
+}
+
+func ringCounts(l *FanLayout) []int {
+	var counts []int
+	for _, w := range l.Wedges() {
+		for len(counts) <= w.Generation {
+			counts = append(counts, 0)
+		}
+		counts[w.Generation]++
+	}
+	return counts
+}
+
+func TestFanWedgesPerRing(t *testing.T) {
+	partial := &AncestorPerson{
+		ID:      1,
+		Details: []string{"A. Brown"},
+		Father: &AncestorPerson{
+			ID:      2,
+			Details: []string{"B. Brown"},
+			Mother:  &AncestorPerson{ID: 3, Details: []string{"C. Green"}},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		root    *AncestorPerson
+		maxGen  int
+		unknown bool
+		want    []int
+	}{
+		{
+			name: "complete",
+			root: fanAncestors(4),
+			want: []int{1, 2, 4, 8},
+		},
+		{
+			name:   "max_generation",
+			root:   fanAncestors(4),
+			maxGen: 3,
+			want:   []int{1, 2, 4},
+		},
+		{
+			name: "partial",
+			root: partial,
+			want: []int{1, 1, 1},
+		},
+		{
+			name:    "partial_unknown",
+			root:    partial,
+			unknown: true,
+			want:    []int{1, 2, 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultFanLayoutOptions()
+			opts.MaxGeneration = tc.maxGen
+			opts.ShowUnknownAncestors = tc.unknown
+			l := (&FanChart{Root: tc.root}).Layout(opts)
+
+			if got := ringCounts(l); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("got wedges per ring %v, wanted %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFanWedgeGeometry(t *testing.T) {
+	for _, sweep := range []float64{180, 270, 360} {
+		t.Run(fmt.Sprint(sweep), func(t *testing.T) {
+			opts := DefaultFanLayoutOptions()
+			opts.Sweep = sweep
+			l := (&FanChart{Root: fanAncestors(3)}).Layout(opts)
+
+			for _, w := range l.Wedges() {
+				span := sweep / float64(int(1)<<w.Generation)
+				if got, want := w.StartAngle, -sweep/2+float64(w.Index)*span; got != want {
+					t.Errorf("wedge %d: got start angle %v, wanted %v", w.ID, got, want)
+				}
+				if got := w.EndAngle - w.StartAngle; got != span {
+					t.Errorf("wedge %d: got span %v, wanted %v", w.ID, got, span)
+				}
+				if w.Generation > 0 {
+					if got, want := w.InnerRadius, opts.CentreRadius+Pixel(w.Generation-1)*opts.RingWidth; got != want {
+						t.Errorf("wedge %d: got inner radius %d, wanted %d", w.ID, got, want)
+					}
+				}
+				if got, want := w.OuterRadius-w.InnerRadius, opts.RingWidth; w.Generation > 0 && got != want {
+					t.Errorf("wedge %d: got ring width %d, wanted %d", w.ID, got, want)
+				}
+
+				// every corner of the wedge lies within the layout
+				for _, r := range []Pixel{w.InnerRadius, w.OuterRadius} {
+					for _, a := range []float64{w.StartAngle, w.EndAngle} {
+						x, y := w.point(float64(r), a)
+						if x < float64(opts.Margin)-1 || x > float64(l.Width()-opts.Margin)+1 || y < float64(opts.Margin)-1 || y > float64(l.Height()-opts.Margin)+1 {
+							t.Errorf("wedge %d: corner (%.1f,%.1f) outside layout %dx%d", w.ID, x, y, l.Width(), l.Height())
+						}
+					}
+				}
+			}
+
+			// a half fan is twice as wide as it is high
+			if sweep == 180 {
+				radius := float64(opts.CentreRadius + 2*opts.RingWidth)
+				if got, want := float64(l.Width()-2*opts.Margin), 2*radius; math.Abs(got-want) > 1 {
+					t.Errorf("got width %v, wanted %v", got, want)
+				}
+				if got, want := float64(l.Height()-2*opts.Margin), radius; math.Abs(got-want) > 1 {
+					t.Errorf("got height %v, wanted %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFanSVG(t *testing.T) {
+	for _, sweep := range []float64{180, 270, 360} {
+		t.Run(fmt.Sprint(sweep), func(t *testing.T) {
+			opts := DefaultFanLayoutOptions()
+			opts.Sweep = sweep
+			root := fanAncestors(3)
+			root.Meta = map[string]string{"record": "I1"}
+			l := (&FanChart{Title: "Ancestors", Root: root}).Layout(opts)
+
+			s, err := SVG(l)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+				t.Fatalf("output is not well formed: %v", err)
+			}
+			if got, want := strings.Count(s, "<path "), len(l.Wedges()); got != want {
+				t.Errorf("got %d wedge paths, wanted %d", got, want)
+			}
+			for _, want := range []string{">Ancestors</text>", ">Person 1</tspan>", ">Person 7</tspan>", `<g data-record="I1">`} {
+				if !strings.Contains(s, want) {
+					t.Errorf("missing %s", want)
+				}
+			}
+		})
+	}
+}
+
+func TestFanEmpty(t *testing.T) {
+	l := (&FanChart{Title: "Ancestors"}).Layout(nil)
+	if len(l.Wedges()) != 0 {
+		t.Errorf("got %d wedges, wanted none", len(l.Wedges()))
+	}
+	if l.Width() <= 2*l.Margin() || l.Height() <= 2*l.Margin() {
+		t.Errorf("got size %dx%d, wanted room for the title", l.Width(), l.Height())
+	}
+}
@@ -0,0 +1,333 @@
+package gtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// gedcomMaxValue is the maximum length in bytes of the value of a single GEDCOM line. Longer values
+// are continued using CONC lines.
+const gedcomMaxValue = 200
+
+// gedcomIndi holds the links from a person to the families they belong to.
+type gedcomIndi struct {
+	person *DescendantPerson
+	fams   []int // the numbers of the families in which the person is a partner
+	famc   []int // the numbers of the families in which the person is a child
+	pedi   []Relationship
+}
+
+// gedcomFam holds the partners and children of a family.
+type gedcomFam struct {
+	husb     *DescendantPerson
+	wife     *DescendantPerson
+	children []*DescendantPerson
+	details  []string
+}
+
+// WriteGEDCOM writes the people and families in a descendant chart to w as a GEDCOM 5.5.1 file
+// encoded in UTF-8. Each person is written as an INDI record with the cross reference @I<id>@,
+// followed by a FAM record for each family, numbered in the order they are encountered.
+//
+// The headings of each person are joined to form their name, with the last word taken to be the
+// surname. Details that describe an event, such as "b. 24 May 1819, London" or "d. 1901", are
+// written as events with a date and place. Other details are written as notes. A person who
+// appears more than once in the chart is written once.
+func WriteGEDCOM(w io.Writer, ch *DescendantChart) error {
+	var order []int
+	indis := make(map[int]*gedcomIndi)
+	var fams []*gedcomFam
+
+	indi := func(p *DescendantPerson) *gedcomIndi {
+		if gi, ok := indis[p.ID]; ok {
+			return gi
+		}
+		gi := &gedcomIndi{person: p}
+		indis[p.ID] = gi
+		order = append(order, p.ID)
+		return gi
+	}
+
+	added := make(map[int]bool)
+	var addPerson func(p *DescendantPerson)
+	addPerson = func(p *DescendantPerson) {
+		indi(p)
+		if added[p.ID] {
+			// the families of a person who appears more than once are only written once
+			return
+		}
+		added[p.ID] = true
+		for _, f := range p.Families {
+			gf := &gedcomFam{details: f.Details}
+			fams = append(fams, gf)
+			num := len(fams)
+
+			indi(p).fams = append(indi(p).fams, num)
+			if f.Other != nil {
+				indi(f.Other).fams = append(indi(f.Other).fams, num)
+			}
+			gf.husb, gf.wife = p, f.Other
+			if p.Sex == Female || (f.Other != nil && f.Other.Sex == Male) {
+				gf.husb, gf.wife = f.Other, p
+			}
+
+			for _, c := range f.Children {
+				gf.children = append(gf.children, c)
+				rel := f.Relationship
+				if c.Relationship != BirthRelationship {
+					rel = c.Relationship
+				}
+				gc := indi(c)
+				gc.famc = append(gc.famc, num)
+				gc.pedi = append(gc.pedi, rel)
+				addPerson(c)
+			}
+		}
+	}
+	if ch.Root != nil {
+		addPerson(ch.Root)
+	}
+
+	gw := &gedcomWriter{w: bufio.NewWriter(w)}
+	gw.line(0, "", "HEAD", "")
+	gw.line(1, "", "SOUR", "gtree")
+	gw.line(1, "", "GEDC", "")
+	gw.line(2, "", "VERS", "5.5.1")
+	gw.line(2, "", "FORM", "LINEAGE-LINKED")
+	gw.line(1, "", "CHAR", "UTF-8")
+
+	for _, id := range order {
+		gi := indis[id]
+		p := gi.person
+		gw.line(0, gedcomIndiXref(id), "INDI", "")
+		if name := gedcomName(p.Headings); name != "" {
+			gw.line(1, "", "NAME", name)
+		}
+		switch p.Sex {
+		case Male:
+			gw.line(1, "", "SEX", "M")
+		case Female:
+			gw.line(1, "", "SEX", "F")
+		}
+		if died := gw.details(p.Details); !died && p.LifeStatus == LifeStatusDeceased {
+			// known to have died, but with no details of the death
+			gw.line(1, "", "DEAT", "Y")
+		}
+		for _, n := range p.Notes {
+			gw.line(1, "", "NOTE", n)
+		}
+		for i, num := range gi.famc {
+			gw.write(1, "", "FAMC", gedcomFamXref(num))
+			switch gi.pedi[i] {
+			case AdoptedRelationship:
+				gw.line(2, "", "PEDI", "adopted")
+			case FosterRelationship:
+				gw.line(2, "", "PEDI", "foster")
+			}
+		}
+		for _, num := range gi.fams {
+			gw.write(1, "", "FAMS", gedcomFamXref(num))
+		}
+	}
+
+	for i, gf := range fams {
+		gw.line(0, gedcomFamXref(i+1), "FAM", "")
+		if gf.husb != nil {
+			gw.write(1, "", "HUSB", gedcomIndiXref(gf.husb.ID))
+		}
+		if gf.wife != nil {
+			gw.write(1, "", "WIFE", gedcomIndiXref(gf.wife.ID))
+		}
+		for _, c := range gf.children {
+			gw.write(1, "", "CHIL", gedcomIndiXref(c.ID))
+		}
+		gw.details(gf.details)
+	}
+
+	gw.line(0, "", "TRLR", "")
+	if gw.err != nil {
+		return gw.err
+	}
+	return gw.w.Flush()
+}
+
+func gedcomIndiXref(id int) string { return "@I" + strconv.Itoa(id) + "@" }
+
+func gedcomFamXref(num int) string { return "@F" + strconv.Itoa(num) + "@" }
+
+// gedcomName returns the GEDCOM name formed from the heading lines of a person, with the last
+// word marked as the surname.
+func gedcomName(headings []string) string {
+	words := strings.Fields(strings.Join(headings, " "))
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	default:
+		return strings.Join(words[:len(words)-1], " ") + " /" + words[len(words)-1] + "/"
+	}
+}
+
+// gedcomWriter writes GEDCOM lines, remembering the first error encountered.
+type gedcomWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a single GEDCOM line. Values containing line breaks are continued with CONT lines and
+// long values are split across CONC lines. Any @ in the value is escaped as @@ so it cannot be read
+// as a pointer; pointers to other records are written with write.
+func (gw *gedcomWriter) line(level int, xref string, tag string, value string) {
+	value = strings.ReplaceAll(value, "@", "@@")
+	for i, text := range strings.Split(value, "\n") {
+		lvl, t := level, tag
+		if i > 0 {
+			lvl, t, xref = level+1, "CONT", ""
+		}
+		for {
+			n := gedcomSplit(text)
+			gw.write(lvl, xref, t, text[:n])
+			text = text[n:]
+			if text == "" {
+				break
+			}
+			lvl, t, xref = level+1, "CONC", ""
+		}
+	}
+}
+
+// gedcomSplit returns the length of the longest prefix of text that may be written as a single
+// value. Long text is split on a rune boundary away from any space since some readers trim the
+// spaces at either end of a value. An escaped @@ is never divided.
+func gedcomSplit(text string) int {
+	if len(text) <= gedcomMaxValue {
+		return len(text)
+	}
+	n := gedcomMaxValue
+	for n > 1 && (!utf8.RuneStart(text[n]) || text[n] == ' ' || text[n-1] == ' ') {
+		n--
+	}
+	at := 0
+	for at < n && text[n-1-at] == '@' {
+		at++
+	}
+	if at%2 == 1 && n > 1 {
+		n--
+	}
+	return n
+}
+
+// write writes a single GEDCOM line with the value verbatim.
+func (gw *gedcomWriter) write(level int, xref string, tag string, value string) {
+	if gw.err != nil {
+		return
+	}
+	s := strconv.Itoa(level)
+	if xref != "" {
+		s += " " + xref
+	}
+	s += " " + tag
+	if value != "" {
+		s += " " + value
+	}
+	_, gw.err = fmt.Fprintln(gw.w, s)
+}
+
+// details writes the details of a person or family as events where they describe one and as notes
+// otherwise. It reports whether a death event was written.
+func (gw *gedcomWriter) details(details []string) bool {
+	died := false
+	for _, d := range details {
+		if tag, date, place, ok := gedcomEvent(d); ok {
+			if tag == "DEAT" {
+				died = true
+			}
+			if date == "" && place == "" {
+				// the event is known to have happened but nothing more
+				gw.line(1, "", tag, "Y")
+				continue
+			}
+			gw.line(1, "", tag, "")
+			if date != "" {
+				gw.line(2, "", "DATE", date)
+			}
+			if place != "" {
+				gw.line(2, "", "PLAC", place)
+			}
+			continue
+		}
+		if strings.TrimSpace(d) != "" {
+			gw.line(1, "", "NOTE", d)
+		}
+	}
+	return died
+}
+
+// gedcomEventPrefixes maps the abbreviations that introduce an event in a detail line to the
+// corresponding GEDCOM tag.
+var gedcomEventPrefixes = []struct {
+	prefix string
+	tag    string
+}{
+	{prefix: "b.", tag: "BIRT"},
+	{prefix: "bap.", tag: "BAPM"},
+	{prefix: "chr.", tag: "CHR"},
+	{prefix: "d.", tag: "DEAT"},
+	{prefix: "bur.", tag: "BURI"},
+	{prefix: "m.", tag: "MARR"},
+	{prefix: "div.", tag: "DIV"},
+}
+
+// gedcomEvent parses a detail line such as "b. 24 May 1819, London, England." into the tag,
+// date and place of an event. It reports false if the line does not describe an event.
+func gedcomEvent(detail string) (string, string, string, bool) {
+	detail = strings.TrimSpace(detail)
+	for _, ep := range gedcomEventPrefixes {
+		if len(detail) <= len(ep.prefix) || !strings.EqualFold(detail[:len(ep.prefix)], ep.prefix) || detail[len(ep.prefix)] != ' ' {
+			continue
+		}
+		rest := strings.TrimSpace(detail[len(ep.prefix):])
+		if ep.tag == "DEAT" {
+			switch strings.ToLower(strings.TrimSuffix(rest, ".")) {
+			case "-", "deceased":
+				// a placeholder recording only that the person has died
+				return ep.tag, "", "", true
+			case "living", "still living":
+				return "", "", "", false
+			}
+		}
+
+		datePart, place, _ := strings.Cut(rest, ",")
+		date, ok := gedcomDate(datePart)
+		if !ok {
+			// the place may instead follow a dash, as in "estimated 1839 - Limerick, Ireland"
+			datePart, place, _ = strings.Cut(rest, " - ")
+			date, ok = gedcomDate(datePart)
+		}
+		if !ok {
+			date, place = "", rest
+		}
+		place = strings.TrimSuffix(strings.TrimSpace(place), ".")
+		if date == "" && place == "" {
+			return "", "", "", false
+		}
+		return ep.tag, date, place, true
+	}
+	return "", "", "", false
+}
+
+// gedcomDate converts a date such as "24 May 1819", "1867-05-11", "abt. 1819" or
+// "between 1916-10-01 and 1916-12-31" into a GEDCOM date. It reports false if the text is not a
+// date in a form understood by ParseDate.
+func gedcomDate(s string) (string, bool) {
+	d, ok := ParseDate(s)
+	if !ok {
+		return "", false
+	}
+	return d.GEDCOM(), true
+}
@@ -0,0 +1,323 @@
+package gtree
+
+import (
+	"bufio"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteGEDCOM(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Anne Brown"},
+			Details:  []string{"b. 24 May 1819, London, England.", "carpenter"},
+			Sex:      Female,
+			Families: []*DescendantFamily{
+				{
+					Other:   &DescendantPerson{ID: 2, Headings: []string{"Bert Green"}},
+					Details: []string{"m. 1840"},
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"Cora Green"}, Details: []string{"d. abt. 1901"}},
+						{ID: 4, Headings: []string{"Dan"}, Relationship: AdoptedRelationship},
+					},
+				},
+			},
+		},
+	}
+
+	buf := new(strings.Builder)
+	if err := WriteGEDCOM(buf, ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := lines(
+		"0 HEAD",
+		"1 SOUR gtree",
+		"1 GEDC",
+		"2 VERS 5.5.1",
+		"2 FORM LINEAGE-LINKED",
+		"1 CHAR UTF-8",
+		"0 @I1@ INDI",
+		"1 NAME Anne /Brown/",
+		"1 SEX F",
+		"1 BIRT",
+		"2 DATE 24 MAY 1819",
+		"2 PLAC London, England",
+		"1 NOTE carpenter",
+		"1 FAMS @F1@",
+		"0 @I2@ INDI",
+		"1 NAME Bert /Green/",
+		"1 FAMS @F1@",
+		"0 @I3@ INDI",
+		"1 NAME Cora /Green/",
+		"1 DEAT",
+		"2 DATE ABT 1901",
+		"1 FAMC @F1@",
+		"0 @I4@ INDI",
+		"1 NAME Dan",
+		"1 FAMC @F1@",
+		"2 PEDI adopted",
+		"0 @F1@ FAM",
+		"1 HUSB @I2@",
+		"1 WIFE @I1@",
+		"1 CHIL @I3@",
+		"1 CHIL @I4@",
+		"1 MARR",
+		"2 DATE 1840",
+		"0 TRLR",
+		"",
+	)
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("GEDCOM mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteGEDCOMParsed(t *testing.T) {
+	in := lines(
+		"1. A. Brown (b. 1819; d. 1901)",
+		"  sp. B. Green (b. 1820)",
+		"   2. C. Brown",
+		"   2. D. Brown",
+		"     sp. E. White",
+		"       3. F. Brown",
+		"  sp. G. Black",
+		"   2. H. Brown",
+	)
+
+	p := &Parser{}
+	ch, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	buf := new(strings.Builder)
+	if err := WriteGEDCOM(buf, ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// collect the links in each record
+	names := map[string]string{}
+	links := map[string][]string{}
+	var xref string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		switch {
+		case fields[0] == "0" && len(fields) == 3:
+			xref = fields[1]
+		case fields[0] == "0":
+			xref = ""
+		case fields[0] == "1" && fields[1] == "NAME":
+			names[xref] = strings.ReplaceAll(fields[2], "/", "")
+		case fields[0] == "1" && len(fields) == 3 && strings.HasPrefix(fields[2], "@"):
+			links[xref] = append(links[xref], fields[1]+" "+fields[2])
+		}
+	}
+
+	// every family in the chart should be preserved
+	var walk func(p *DescendantPerson)
+	walk = func(p *DescendantPerson) {
+		for _, f := range p.Families {
+			var fam string
+			for _, l := range links[gedcomIndiXref(p.ID)] {
+				if strings.HasPrefix(l, "FAMS ") && cmp.Equal(partners(links, l[5:]), []int{p.ID, f.Other.ID}) {
+					fam = l[5:]
+				}
+			}
+			if fam == "" {
+				t.Errorf("no family found for %q and %q", names[gedcomIndiXref(p.ID)], names[gedcomIndiXref(f.Other.ID)])
+				continue
+			}
+			var want []string
+			for _, c := range f.Children {
+				want = append(want, "CHIL "+gedcomIndiXref(c.ID))
+				if !strings.Contains(strings.Join(links[gedcomIndiXref(c.ID)], ","), "FAMC "+fam) {
+					t.Errorf("%q: missing FAMC %s", names[gedcomIndiXref(c.ID)], fam)
+				}
+				walk(c)
+			}
+			var got []string
+			for _, l := range links[fam] {
+				if strings.HasPrefix(l, "CHIL ") {
+					got = append(got, l)
+				}
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("family %s children mismatch (-want +got):\n%s", fam, diff)
+			}
+		}
+	}
+	walk(ch.Root)
+
+	if got, want := len(names), 8; got != want {
+		t.Errorf("got %d named people, wanted %d", got, want)
+	}
+}
+
+// partners returns the ids of the partners in the family with the given xref, husband first.
+func partners(links map[string][]string, fam string) []int {
+	var ids []int
+	for _, l := range links[fam] {
+		if strings.HasPrefix(l, "HUSB ") || strings.HasPrefix(l, "WIFE ") {
+			id, _ := strconv.Atoi(strings.Trim(l[5:], "@I"))
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestWriteGEDCOMDeathMarkers(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Anne Brown"},
+			Details:  []string{"d. -"},
+			Families: []*DescendantFamily{
+				{
+					Children: []*DescendantPerson{
+						{ID: 2, Headings: []string{"Cora Brown"}, Details: []string{"Deceased"}, LifeStatus: LifeStatusDeceased},
+						{ID: 3, Headings: []string{"Dan Brown"}, Details: []string{"d. Living"}, LifeStatus: LifeStatusLiving},
+					},
+				},
+			},
+		},
+	}
+
+	buf := new(strings.Builder)
+	if err := WriteGEDCOM(buf, ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "0 @I") || strings.HasPrefix(line, "1 DEAT") || strings.HasPrefix(line, "2 ") && !strings.HasPrefix(line, "2 VERS") && !strings.HasPrefix(line, "2 FORM") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"0 @I1@ INDI",
+		"1 DEAT Y",
+		"0 @I2@ INDI",
+		"1 DEAT Y",
+		"0 @I3@ INDI",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("death events mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGEDCOMDate(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{in: "1819", want: "1819", ok: true},
+		{in: "May 1819", want: "MAY 1819", ok: true},
+		{in: "24 May 1819.", want: "24 MAY 1819", ok: true},
+		{in: "3 September 1901", want: "3 SEP 1901", ok: true},
+		{in: "abt. 1819", want: "ABT 1819", ok: true},
+		{in: "c 1819", want: "ABT 1819", ok: true},
+		{in: "bef 1 Jan 1900", want: "BEF 1 JAN 1900", ok: true},
+		{in: "estimated 1839", want: "EST 1839", ok: true},
+		{in: "1867-05-11", want: "11 MAY 1867", ok: true},
+		{in: "between 1916-10-01 and 1916-12-31", want: "BET 1 OCT 1916 AND 31 DEC 1916", ok: true},
+		{in: "London", ok: false},
+		{in: "1819-1901", ok: false},
+		{in: "32 May 1819", ok: false},
+		{in: "", ok: false},
+	}
+
+	for _, tc := range testCases {
+		got, ok := gedcomDate(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("gedcomDate(%q): got %q, %v, wanted %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestGEDCOMEventDashPlace(t *testing.T) {
+	tag, date, place, ok := gedcomEvent("b. estimated 1839 - Limerick, Ireland")
+	if !ok || tag != "BIRT" || date != "EST 1839" || place != "Limerick, Ireland" {
+		t.Errorf("got %q, %q, %q, %v, wanted BIRT, EST 1839, Limerick, Ireland, true", tag, date, place, ok)
+	}
+}
+
+func TestGEDCOMLongValue(t *testing.T) {
+	note := strings.Repeat("word ", 100) + "\nsecond line"
+	buf := new(strings.Builder)
+	gw := &gedcomWriter{w: bufio.NewWriter(buf)}
+	gw.line(1, "", "NOTE", note)
+	gw.w.Flush()
+
+	var got strings.Builder
+	for i, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields[2]) > gedcomMaxValue {
+			t.Errorf("line %d: got value of length %d, wanted at most %d", i, len(fields[2]), gedcomMaxValue)
+		}
+		switch {
+		case i == 0 && fields[0]+fields[1] == "1NOTE":
+		case fields[0]+fields[1] == "2CONC":
+		case fields[0]+fields[1] == "2CONT":
+			got.WriteString("\n")
+		default:
+			t.Errorf("line %d: unexpected %q", i, line)
+		}
+		got.WriteString(fields[2])
+	}
+	if got.String() != note {
+		t.Errorf("got reassembled note %q, wanted %q", got.String(), note)
+	}
+}
+
+func TestGEDCOMEscapeAt(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Notes:    []string{"email a.brown@example.com"},
+			Sex:      Male,
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{{ID: 3, Headings: []string{"C. Brown"}}},
+				},
+			},
+		},
+	}
+
+	buf := new(strings.Builder)
+	if err := WriteGEDCOM(buf, ch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := buf.String()
+
+	if !strings.Contains(got, "1 NOTE email a.brown@@example.com\n") {
+		t.Errorf("got %q, wanted @ in note escaped as @@", got)
+	}
+	for _, want := range []string{"0 @I1@ INDI\n", "1 FAMS @F1@\n", "1 HUSB @I1@\n", "1 CHIL @I3@\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got %q, wanted pointer line %q unescaped", got, want)
+		}
+	}
+}
+
+func TestGEDCOMLongValueAt(t *testing.T) {
+	note := strings.Repeat("@", 3*gedcomMaxValue)
+	buf := new(strings.Builder)
+	gw := &gedcomWriter{w: bufio.NewWriter(buf)}
+	gw.line(1, "", "NOTE", note)
+	gw.w.Flush()
+
+	for i, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields[2])%2 != 0 {
+			t.Errorf("line %d: got value of length %d, wanted escaped @@ not to be divided", i, len(fields[2]))
+		}
+	}
+}
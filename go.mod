@@ -2,4 +2,7 @@ module github.com/iand/gtree
 
 go 1.22
 
-require github.com/google/go-cmp v0.6.0
+require (
+	github.com/google/go-cmp v0.6.0
+	golang.org/x/text v0.22.0
+)
@@ -0,0 +1,105 @@
+package gtree
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// jsonLayout is the JSON representation of a layout.
+type jsonLayout struct {
+	Width      Pixel           `json:"width"`
+	Height     Pixel           `json:"height"`
+	Margin     Pixel           `json:"margin"`
+	Title      string          `json:"title,omitempty"`
+	TitleLines []string        `json:"title_lines,omitempty"` // the title as wrapped to fit the chart
+	Notes      []string        `json:"notes,omitempty"`
+	Blurbs     []jsonBlurb     `json:"blurbs"`
+	Connectors []jsonConnector `json:"connectors"`
+}
+
+// jsonBlurb is the JSON representation of a blurb.
+type jsonBlurb struct {
+	ID        int      `json:"id"`
+	Left      Pixel    `json:"left"`
+	Top       Pixel    `json:"top"`
+	Width     Pixel    `json:"width"`
+	Height    Pixel    `json:"height"`
+	Headings  []string `json:"headings"`
+	Details   []string `json:"details"`
+	Tags      []string `json:"tags,omitempty"`
+	Centred   bool     `json:"centred,omitempty"`
+	Highlight bool     `json:"highlight,omitempty"`
+}
+
+// jsonConnector is the JSON representation of a connector.
+type jsonConnector struct {
+	Points       []jsonPoint `json:"points"`
+	CornerRadius Pixel       `json:"corner_radius,omitempty"`
+	Highlight    bool        `json:"highlight,omitempty"`
+	Dashed       bool        `json:"dashed,omitempty"`
+	Color        string      `json:"color,omitempty"`
+}
+
+// jsonPoint is the JSON representation of a point.
+type jsonPoint struct {
+	X Pixel `json:"x"`
+	Y Pixel `json:"y"`
+}
+
+// LayoutJSON returns a JSON representation of the geometry of a layout: its dimensions, the
+// position, size and text of every blurb, ordered by id, and the points of every connector. It
+// provides the same data used by SVG for use by other renderers or for testing. The title is
+// given both in full and, for layouts that wrap it, as the lines drawn; notes are given as the
+// lines drawn.
+func LayoutJSON(lay Layout) ([]byte, error) {
+	jl := jsonLayout{
+		Width:      lay.Width(),
+		Height:     lay.Height(),
+		Margin:     lay.Margin(),
+		Title:      lay.Title().Text,
+		Blurbs:     []jsonBlurb{},
+		Connectors: []jsonConnector{},
+	}
+
+	if tl, ok := lay.(titleLiner); ok {
+		for _, t := range tl.TitleLines() {
+			jl.TitleLines = append(jl.TitleLines, t.Text)
+		}
+	}
+
+	for _, n := range lay.Notes() {
+		jl.Notes = append(jl.Notes, n.Text)
+	}
+
+	for _, b := range lay.Blurbs() {
+		jl.Blurbs = append(jl.Blurbs, jsonBlurb{
+			ID:        b.ID,
+			Left:      b.Left(),
+			Top:       b.TopPos,
+			Width:     b.Width,
+			Height:    b.Height,
+			Headings:  b.HeadingTexts.Lines,
+			Details:   b.DetailTexts.Lines,
+			Tags:      b.Tags,
+			Centred:   b.CentreText,
+			Highlight: b.Highlight,
+		})
+	}
+	sort.Slice(jl.Blurbs, func(i, j int) bool { return jl.Blurbs[i].ID < jl.Blurbs[j].ID })
+
+	for _, c := range lay.Connectors() {
+		jc := jsonConnector{
+			Points:       make([]jsonPoint, len(c.Points)),
+			CornerRadius: c.CornerRadius,
+			Highlight:    c.Highlight,
+			Dashed:       c.Dashed,
+			Color:        c.Color,
+		}
+		for i, p := range c.Points {
+			jc.Points[i] = jsonPoint{X: p.X, Y: p.Y}
+		}
+		jl.Connectors = append(jl.Connectors, jc)
+	}
+
+	return json.MarshalIndent(jl, "", "  ")
+}
@@ -0,0 +1,95 @@
+package gtree
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLayoutJSON(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+
+	data, err := LayoutJSON(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got jsonLayout
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if got.Width != lay.Width() || got.Height != lay.Height() {
+		t.Errorf("got dimensions %dx%d, wanted %dx%d", got.Width, got.Height, lay.Width(), lay.Height())
+	}
+
+	if len(got.Blurbs) != len(lay.blurbs) {
+		t.Fatalf("got %d blurbs, wanted %d", len(got.Blurbs), len(lay.blurbs))
+	}
+	for i, jb := range got.Blurbs {
+		if i > 0 && got.Blurbs[i-1].ID >= jb.ID {
+			t.Errorf("blurbs not ordered by id: %d before %d", got.Blurbs[i-1].ID, jb.ID)
+		}
+		b := lay.blurbs[jb.ID]
+		if jb.Left != b.Left() || jb.Top != b.TopPos || jb.Width != b.Width || jb.Height != b.Height {
+			t.Errorf("blurb %d: got geometry (%d,%d %dx%d), wanted (%d,%d %dx%d)", jb.ID, jb.Left, jb.Top, jb.Width, jb.Height, b.Left(), b.TopPos, b.Width, b.Height)
+		}
+		if len(jb.Headings) == 0 || jb.Headings[0] != b.HeadingTexts.Lines[0] {
+			t.Errorf("blurb %d: got headings %q, wanted %q", jb.ID, jb.Headings, b.HeadingTexts.Lines)
+		}
+	}
+
+	if len(got.Connectors) != len(lay.connectors) {
+		t.Fatalf("got %d connectors, wanted %d", len(got.Connectors), len(lay.connectors))
+	}
+	for i, jc := range got.Connectors {
+		c := lay.connectors[i]
+		if len(jc.Points) != len(c.Points) {
+			t.Errorf("connector %d: got %d points, wanted %d", i, len(jc.Points), len(c.Points))
+			continue
+		}
+		for j := range jc.Points {
+			if jc.Points[j].X != c.Points[j].X || jc.Points[j].Y != c.Points[j].Y {
+				t.Errorf("connector %d point %d: got (%d,%d), wanted (%d,%d)", i, j, jc.Points[j].X, jc.Points[j].Y, c.Points[j].X, c.Points[j].Y)
+			}
+		}
+	}
+}
+
+func TestLayoutJSONWrappedTitle(t *testing.T) {
+	ch := *onePersonWithSpouseAndChildren
+	ch.Title = "A title that is far too long to fit within the width of such a small chart"
+	ch.Notes = []string{"A note that is also far too long to fit within the width of such a small chart"}
+	lay := ch.Layout(nil)
+
+	data, err := LayoutJSON(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got jsonLayout
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	if got.Title != ch.Title {
+		t.Errorf("got title %q, wanted %q", got.Title, ch.Title)
+	}
+
+	titles := lay.TitleLines()
+	if len(titles) < 2 {
+		t.Fatalf("got %d title lines, wanted title to be wrapped", len(titles))
+	}
+	if len(got.TitleLines) != len(titles) {
+		t.Fatalf("got %d exported title lines, wanted %d", len(got.TitleLines), len(titles))
+	}
+	for i, te := range titles {
+		if got.TitleLines[i] != te.Text {
+			t.Errorf("title line %d: got %q, wanted %q", i, got.TitleLines[i], te.Text)
+		}
+	}
+
+	notes := lay.Notes()
+	if len(got.Notes) != len(notes) || len(notes) < 2 {
+		t.Fatalf("got %d exported note lines, wanted %d wrapped lines", len(got.Notes), len(notes))
+	}
+}
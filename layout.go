@@ -1,10 +1,51 @@
 package gtree
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/bidi"
+)
 
 // Pixel represents a unit of measurement used for layout dimensions, such as font sizes, margins, and positions.
 type Pixel int
 
+// Resolution is a number of pixels per inch, used to convert physical units such as points and
+// inches to pixels.
+type Resolution float64
+
+// CSSResolution is the resolution assumed by SVG and CSS, where one inch is 96 pixels.
+const CSSResolution Resolution = 96
+
+// Points returns the number of pixels equivalent to pt points at the resolution r.
+func (r Resolution) Points(pt float64) Pixel {
+	return Pixel(pt*float64(r)/72 + 0.5)
+}
+
+// Inches returns the number of pixels equivalent to in inches at the resolution r.
+func (r Resolution) Inches(in float64) Pixel {
+	return Pixel(in*float64(r) + 0.5)
+}
+
+// Millimetres returns the number of pixels equivalent to mm millimetres at the resolution r.
+func (r Resolution) Millimetres(mm float64) Pixel {
+	return Pixel(mm*float64(r)/25.4 + 0.5)
+}
+
+// Points returns the number of pixels equivalent to pt points at the CSS resolution.
+func Points(pt float64) Pixel { return CSSResolution.Points(pt) }
+
+// Inches returns the number of pixels equivalent to in inches at the CSS resolution.
+func Inches(in float64) Pixel { return CSSResolution.Inches(in) }
+
+// Millimetres returns the number of pixels equivalent to mm millimetres at the CSS resolution.
+func Millimetres(mm float64) Pixel { return CSSResolution.Millimetres(mm) }
+
+// Ems returns the number of pixels equivalent to em ems for text with the given font size.
+func Ems(em float64, fontSize Pixel) Pixel {
+	return Pixel(em*float64(fontSize) + 0.5)
+}
+
 // TextElement represents a piece of text with a specified font size and line height, used in various layout elements.
 type TextElement struct {
 	Text  string
@@ -24,6 +65,77 @@ type Layout interface {
 	Debug() bool
 }
 
+// Alignment specifies the horizontal alignment of an element within the space available to it.
+type Alignment int
+
+const (
+	AlignLeft   Alignment = iota // AlignLeft aligns an element with the left edge of the available space.
+	AlignCentre                  // AlignCentre centres an element within the available space.
+	AlignRight                   // AlignRight aligns an element with the right edge of the available space.
+)
+
+// UnionMarker is the mark placed between a person and their spouse to show their union.
+type UnionMarker int
+
+const (
+	UnionEquals  UnionMarker = iota // UnionEquals marks a union with an equals sign drawn as text.
+	UnionDot                        // UnionDot marks a union with a small filled circle.
+	UnionDiamond                    // UnionDiamond marks a union with a small filled diamond.
+)
+
+// Direction is the direction in which text is written.
+type Direction int
+
+const (
+	DirectionDefault Direction = iota // DirectionDefault leaves the direction of text unspecified so that it is left to right.
+	DirectionLTR                      // DirectionLTR indicates that text is written left to right.
+	DirectionRTL                      // DirectionRTL indicates that text is written right to left, such as Hebrew or Arabic.
+	DirectionAuto                     // DirectionAuto determines the direction of each line from the first strongly directional character it contains.
+)
+
+// resolve returns the direction to use for the given line of text, resolving DirectionAuto to either
+// DirectionLTR or DirectionRTL.
+func (d Direction) resolve(text string) Direction {
+	if d != DirectionAuto {
+		return d
+	}
+	for len(text) > 0 {
+		p, size := bidi.LookupString(text)
+		if size == 0 {
+			break
+		}
+		switch p.Class() {
+		case bidi.L:
+			return DirectionLTR
+		case bidi.R, bidi.AL:
+			return DirectionRTL
+		}
+		text = text[size:]
+	}
+	return DirectionLTR
+}
+
+// Sex is the sex of a person, used to render a symbol alongside their name.
+type Sex int
+
+const (
+	UnknownSex Sex = iota // UnknownSex indicates that the sex of the person is not known or not shown.
+	Male                  // Male indicates that the person is male.
+	Female                // Female indicates that the person is female.
+)
+
+// Symbol returns the conventional symbol for the sex, or an empty string if the sex is unknown.
+func (s Sex) Symbol() string {
+	switch s {
+	case Male:
+		return "\u2642"
+	case Female:
+		return "\u2640"
+	default:
+		return ""
+	}
+}
+
 // Point represents a coordinate in the layout, defined by its X (horizontal) and Y (vertical) position.
 type Point struct {
 	X Pixel
@@ -31,8 +143,35 @@ type Point struct {
 }
 
 // Connector represents a connection between two points in the layout, typically used to draw lines between blurbs.
+// The ends of a connector that meet a blurb are placed exactly on the hook coordinates of the blurb, such as
+// TopHookX and SideHookY, offset by the line gap. See the SnapConnectors layout option for straightening
+// connectors that would otherwise jog by a few pixels to meet a hook.
 type Connector struct {
-	Points []Point
+	Points       []Point
+	CornerRadius Pixel // CornerRadius is the radius of the arc used to round each corner of the connector. Zero gives square corners.
+	Highlight    bool  // Highlight indicates that the connector should be rendered with emphasis
+	Dashed       bool  // Dashed indicates that the connector should be rendered as a dashed line, such as for an adoptive or uncertain relationship
+	Children     []int // Children holds the ids of the child blurbs whose path to their parents includes the connector
+
+	Color string // Color is the color of the line. Empty uses black. Highlighted connectors are always drawn in the highlight color.
+	Link  string // Link is the URL the line links to when rendered as SVG. Empty adds no link.
+}
+
+// Label is a single line of text drawn at a fixed position in a layout, outside of any blurb.
+type Label struct {
+	Text  string
+	Style TextStyle
+	Left  Pixel // Left is the horizontal position of the start of the text
+	Y     Pixel // Y is the vertical position of the middle of the text
+}
+
+// Region represents the rectangular area occupied by a blurb in the final layout.
+type Region struct {
+	ID     int   // ID is the id of the blurb occupying the region
+	Left   Pixel // Left is the horizontal position of the left edge of the region
+	Top    Pixel // Top is the vertical position of the top edge of the region
+	Width  Pixel // Width is the horizontal extent of the region
+	Height Pixel // Height is the vertical extent of the region
 }
 
 // Blurb represents a visual element in the layout, typically used to display information about a person in a chart.
@@ -42,6 +181,17 @@ type Blurb struct {
 	HeadingTexts TextSection
 	DetailTexts  TextSection
 	Tags         []string
+	Highlight    bool  // Highlight indicates that the blurb should be rendered with emphasis
+	Sex          Sex   // Sex is the sex of the person represented by the blurb
+	NoteRefs     []int // NoteRefs are the numbers of any notes attached to the person represented by the blurb
+	Collapsed    bool  // Collapsed indicates that the blurb summarises a person whose descendants are not shown
+	Focus        bool  // Focus indicates that the blurb represents the person the chart is about and should be rendered with a border
+
+	Marker    UnionMarker // Marker is the shape drawn in place of the first heading line of a relationship marker. UnionEquals draws the heading as text.
+	DNATested bool        // DNATested indicates that the person represented by the blurb has DNA test results and should be marked with a dot after their name
+
+	Meta map[string]string // Meta is arbitrary data about the person represented by the blurb, written by SVG as data-* attributes on the group containing the blurb
+	Link string            // Link is the URL the blurb links to when rendered as SVG, such as for the relationship marker of a family. Empty adds no link.
 
 	// Text          []string
 	CentreText          bool   // true if the text for this blurb is better presented as centred
@@ -64,6 +214,88 @@ type Blurb struct {
 	LastChild  *Blurb
 }
 
+// setSex records the sex of the person represented by the blurb and, if it is known, widens the
+// blurb if needed to reserve space for a symbol after the first heading line.
+func (b *Blurb) setSex(sex Sex) {
+	b.Sex = sex
+	if sex == UnknownSex || len(b.HeadingTexts.Lines) == 0 {
+		return
+	}
+	w := b.SexSymbolOffset() + b.HeadingTexts.Style.FontSize
+	if w > b.Width {
+		b.Width = w
+	}
+}
+
+// SexSymbolOffset returns the offset from the left of the blurb at which a symbol denoting the
+// sex of the person may be drawn without overlapping the first heading line.
+func (b *Blurb) SexSymbolOffset() Pixel {
+	if len(b.HeadingTexts.Lines) == 0 {
+		return 0
+	}
+	return b.HeadingTexts.Style.width(b.HeadingTexts.Lines[0]) + b.HeadingTexts.Style.FontSize/4
+}
+
+// setNoteRefs records the numbers of the notes attached to the person represented by the blurb
+// and widens the blurb if needed to reserve space for them after the first heading line.
+func (b *Blurb) setNoteRefs(refs []int) {
+	b.NoteRefs = refs
+	if len(refs) == 0 || len(b.HeadingTexts.Lines) == 0 {
+		return
+	}
+	w := b.NoteRefOffset() + textWidth([]rune(b.NoteRefText()), b.NoteRefFontSize())
+	if w > b.Width {
+		b.Width = w
+	}
+}
+
+// NoteRefOffset returns the offset from the left of the blurb at which the note numbers may be
+// drawn without overlapping the first heading line or any symbol denoting the sex of the person.
+func (b *Blurb) NoteRefOffset() Pixel {
+	offset := b.SexSymbolOffset()
+	if b.Sex != UnknownSex {
+		offset += b.HeadingTexts.Style.FontSize
+	}
+	return offset
+}
+
+// NoteRefText returns the note numbers of the blurb as text suitable for display as a superscript.
+func (b *Blurb) NoteRefText() string {
+	refs := make([]string, len(b.NoteRefs))
+	for i, n := range b.NoteRefs {
+		refs[i] = strconv.Itoa(n)
+	}
+	return strings.Join(refs, ",")
+}
+
+// NoteRefFontSize returns the size of the font used to display the note numbers of the blurb.
+func (b *Blurb) NoteRefFontSize() Pixel {
+	return b.HeadingTexts.Style.FontSize * 2 / 3
+}
+
+// setDNATested marks the blurb as representing a person with DNA test results and widens the
+// blurb if needed to reserve space for a dot after the first heading line.
+func (b *Blurb) setDNATested() {
+	b.DNATested = true
+	if len(b.HeadingTexts.Lines) == 0 {
+		return
+	}
+	w := b.DNAMarkerOffset() + b.HeadingTexts.Style.FontSize/2
+	if w > b.Width {
+		b.Width = w
+	}
+}
+
+// DNAMarkerOffset returns the offset from the left of the blurb at which the dot marking DNA test
+// results may be drawn without overlapping the first heading line, any sex symbol or note numbers.
+func (b *Blurb) DNAMarkerOffset() Pixel {
+	offset := b.NoteRefOffset()
+	if len(b.NoteRefs) > 0 {
+		offset += textWidth([]rune(b.NoteRefText()), b.NoteRefFontSize()) + b.HeadingTexts.Style.FontSize/4
+	}
+	return offset
+}
+
 // X returns the horizontal position of the centre of the Blurb
 func (b *Blurb) X() Pixel {
 	if b.AbsolutePositioning {
@@ -111,6 +343,19 @@ func (b *Blurb) SideHookY() Pixel {
 	return b.TopPos + b.SideHookOffset
 }
 
+// MeasureText returns the width of s when rendered in the given style, using the same
+// measurement as the layouts in this package.
+func MeasureText(s string, style TextStyle) Pixel {
+	return style.width(s)
+}
+
+// WrapLines wraps each of lines at word boundaries so that, where possible, no line is
+// wider than maxWidth when rendered in the given style. It makes the same wrapping
+// decisions as the layouts in this package.
+func WrapLines(lines []string, maxWidth Pixel, style TextStyle) []string {
+	return wrapText(lines, maxWidth, style)
+}
+
 func textWidth(t []rune, fontSize Pixel) Pixel {
 	w := Pixel(0)
 	for _, r := range t {
@@ -130,7 +375,8 @@ func textWidth(t []rune, fontSize Pixel) Pixel {
 /*
 ** Table of scale-factor estimates for variable-width characters.
 ** Actual character widths vary by font.  These numbers are only
-** guesses.  And this table only provides data for ASCII.
+** guesses.  And this table only provides data for ASCII and a few
+** common punctuation marks.
 **
 ** 100 means normal width.
  */
@@ -230,32 +476,202 @@ var runeWidths = map[rune]Pixel{
 	'|':  5,
 	'}':  10,
 	'~':  13,
+
+	// common typographic punctuation, with curly quotes measured as their straight equivalents
+	'‘': 4,  // left single quotation mark
+	'’': 4,  // right single quotation mark, also used as an apostrophe
+	'“': 7,  // left double quotation mark
+	'”': 7,  // right double quotation mark
+	'–': 8,  // en dash
+	'—': 16, // em dash
+	'…': 15, // horizontal ellipsis
 }
 
 type TextStyle struct {
-	FontSize   Pixel  // FontSize is the size of the font to use for the text of each blurb.
-	LineHeight Pixel  // LineHeight is the vertical distance between lines of text of the same style.
-	Color      string // Color is the color of the text. The default is black #000000.
+	FontSize   Pixel     // FontSize is the size of the font to use for the text of each blurb.
+	LineHeight Pixel     // LineHeight is the vertical distance between lines of text of the same style.
+	Color      string    // Color is the color of the text. The default is black #000000.
+	Halo       string    // Halo is the color of an outline drawn around the text to improve legibility over images. No outline is drawn if empty.
+	Direction  Direction // Direction is the direction in which the text is written. Right to left text keeps its alignment within the blurb.
+	Bold       bool      // Bold indicates that the text should be rendered in a bold weight.
+	Italic     bool      // Italic indicates that the text should be rendered in an italic style.
+	Opacity    float64   // Opacity is the opacity of the text from 0 to 1. Zero is treated as 1, fully opaque.
+
+	BoldWidth float64 // BoldWidth is the width of bold text relative to the same text in a regular weight, such as 1.05, used when measuring bold text for layout. Zero measures bold text as if it were regular.
+}
+
+// width returns the width of text when rendered in the style.
+func (s TextStyle) width(text string) Pixel {
+	w := textWidth([]rune(text), s.FontSize)
+	if s.Bold && s.BoldWidth > 0 {
+		w = Pixel(float64(w)*s.BoldWidth + 0.5)
+	}
+	return w
+}
+
+// scaled returns a copy of the style with its font size and line height multiplied by f.
+func (s TextStyle) scaled(f float64) TextStyle {
+	s.FontSize = Pixel(float64(s.FontSize) * f)
+	s.LineHeight = Pixel(float64(s.LineHeight) * f)
+	return s
+}
+
+// inherit returns a copy of the style with its font size, line height, colour, halo, opacity
+// and bold width taken from base wherever they are unset.
+func (s TextStyle) inherit(base TextStyle) TextStyle {
+	if s.FontSize == 0 {
+		s.FontSize = base.FontSize
+	}
+	if s.LineHeight == 0 {
+		s.LineHeight = base.LineHeight
+	}
+	if s.Color == "" {
+		s.Color = base.Color
+	}
+	if s.Halo == "" {
+		s.Halo = base.Halo
+	}
+	if s.Opacity == 0 {
+		s.Opacity = base.Opacity
+	}
+	if s.BoldWidth == 0 {
+		s.BoldWidth = base.BoldWidth
+	}
+	return s
 }
 
 type TextSection struct {
 	Lines []string
 	Style TextStyle
+	Align Alignment // Align is the horizontal alignment of the lines within the width of the blurb. It is ignored for blurbs with centred text.
+
+	Columns     int   // Columns is the number of columns the lines are arranged in, filling each column before starting the next. Zero or one means a single column.
+	ColumnWidth Pixel // ColumnWidth is the width of each column when there is more than one.
+	ColumnGap   Pixel // ColumnGap is the horizontal space between adjacent columns.
+
+	LineStyles []TextStyle // LineStyles is the style of each line when the lines are not all alike, in the same order as Lines. Nil renders every line in Style.
 }
 
-func wrapText(texts []string, maxWidth Pixel, fontSize Pixel) []string {
+// LineStyle returns the style of the line with the given index.
+func (t *TextSection) LineStyle(i int) TextStyle {
+	if i < len(t.LineStyles) {
+		return t.LineStyles[i]
+	}
+	return t.Style
+}
+
+// LineHeight returns the vertical distance between successive rows of the section, which is the
+// tallest line height of any of its lines so that rows stay evenly spaced.
+func (t *TextSection) LineHeight() Pixel {
+	h := t.Style.LineHeight
+	for _, s := range t.LineStyles {
+		h = max(h, s.LineHeight)
+	}
+	return h
+}
+
+// Rows returns the number of rows occupied by the lines once arranged into columns.
+func (t *TextSection) Rows() int {
+	if t.Columns <= 1 {
+		return len(t.Lines)
+	}
+	return (len(t.Lines) + t.Columns - 1) / t.Columns
+}
+
+// Cell returns the column and row occupied by the line with the given index.
+func (t *TextSection) Cell(i int) (int, int) {
+	rows := t.Rows()
+	if rows == 0 {
+		return 0, i
+	}
+	return i / rows, i % rows
+}
+
+// ColumnsWidth returns the total width of the columns, including the gaps between them.
+func (t *TextSection) ColumnsWidth() Pixel {
+	if t.Columns <= 1 {
+		return t.ColumnWidth
+	}
+	return Pixel(t.Columns)*t.ColumnWidth + Pixel(t.Columns-1)*t.ColumnGap
+}
+
+// arrangeColumns arranges the lines into the given number of columns, each as wide as the
+// widest line, provided the columns fit within maxWidth. A maxWidth of zero places no limit on
+// the width. It leaves the section as a single column when there are too few lines to fill more
+// than one column or the columns would not fit.
+func (t *TextSection) arrangeColumns(cols int, gap Pixel, maxWidth Pixel) {
+	cols = min(cols, len(t.Lines))
+	if cols <= 1 {
+		return
+	}
+
+	colWidth := Pixel(0)
+	for i, line := range t.Lines {
+		colWidth = max(colWidth, t.LineStyle(i).width(line))
+	}
+
+	if maxWidth > 0 && Pixel(cols)*colWidth+Pixel(cols-1)*gap > maxWidth {
+		return
+	}
+
+	t.Columns = cols
+	t.ColumnWidth = colWidth
+	t.ColumnGap = gap
+}
+
+// dropEmptyLines returns the lines that contain more than whitespace.
+func dropEmptyLines(lines []string) []string {
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// wrapWord is a piece of text that may start a new line when wrapping, with the separator that
+// joins it to the preceding piece when they are on the same line.
+type wrapWord struct {
+	text string
+	sep  string
+}
+
+// wrapWords splits text into the pieces that lines may be broken between. Lines may break at
+// spaces or after a hyphen or slash within a word, which is kept at the end of the preceding piece.
+func wrapWords(text string) []wrapWord {
+	var words []wrapWord
+	for _, field := range strings.Fields(text) {
+		sep := " "
+		for {
+			i := strings.IndexAny(field, "-/")
+			if i < 0 || i == len(field)-1 {
+				words = append(words, wrapWord{text: field, sep: sep})
+				break
+			}
+			words = append(words, wrapWord{text: field[:i+1], sep: sep})
+			field = field[i+1:]
+			sep = ""
+		}
+	}
+	return words
+}
+
+// wrapText wraps each of texts at word boundaries so that, where possible, no line is wider than
+// maxWidth when measured in the given style, including the extra width of any bold text.
+func wrapText(texts []string, maxWidth Pixel, style TextStyle) []string {
 	if len(texts) == 0 {
 		return []string{}
 	}
 	wrapped := make([]string, 0, len(texts))
 	for i := 0; i < len(texts); i++ {
-		wl := textWidth([]rune(texts[i]), fontSize)
+		wl := style.width(texts[i])
 		if wl <= maxWidth {
 			wrapped = append(wrapped, texts[i])
 			continue
 		}
 
-		words := strings.Fields(texts[i])
+		words := wrapWords(texts[i])
 		if len(words) == 0 {
 			wrapped = append(wrapped, "")
 			continue
@@ -265,17 +681,17 @@ func wrapText(texts []string, maxWidth Pixel, fontSize Pixel) []string {
 		for w := 0; w < len(words); w++ {
 			candidate := line
 			if len(line) != 0 {
-				candidate += " "
+				candidate += words[w].sep
 			}
-			candidate += words[w]
-			wl := textWidth([]rune(candidate), fontSize)
+			candidate += words[w].text
+			wl := style.width(candidate)
 			if wl >= maxWidth {
 				if len(line) == 0 {
 					wrapped = append(wrapped, candidate)
 					line = ""
 				} else {
 					wrapped = append(wrapped, line)
-					line = words[w]
+					line = words[w].text
 				}
 				continue
 			}
@@ -286,22 +702,68 @@ func wrapText(texts []string, maxWidth Pixel, fontSize Pixel) []string {
 	return wrapped
 }
 
-func titleDimensions(title string, notes []string, titleStyle TextStyle, noteStyle TextStyle) (Pixel, Pixel) {
-	if title == "" && len(notes) == 0 {
+// wrapTitle wraps the title and notes of a chart so that no line is wider than maxWidth, where
+// possible, returning the lines of each. Nothing is wrapped if maxWidth is not positive.
+func wrapTitle(title string, notes []string, maxWidth Pixel, titleStyle TextStyle, noteStyle TextStyle) ([]string, []string) {
+	var titleLines []string
+	if title != "" {
+		titleLines = []string{title}
+	}
+	if maxWidth <= 0 {
+		return titleLines, notes
+	}
+	return wrapText(titleLines, maxWidth, titleStyle), wrapText(notes, maxWidth, noteStyle)
+}
+
+// chartText holds the title and notes of a chart together with the lines they are wrapped to. It
+// is embedded by each layout that wraps its title and notes to the width of the chart.
+type chartText struct {
+	title      string
+	notes      []string
+	titleLines []string // the title wrapped to fit the width of the chart
+	noteLines  []string // the notes wrapped to fit the width of the chart
+}
+
+// wrapTitle wraps the title and notes to wrapWidth, or to contentWidth if no wrap width is set, and
+// returns the height and width of the space they need.
+func (c *chartText) wrapTitle(contentWidth Pixel, wrapWidth Pixel, titleStyle TextStyle, noteStyle TextStyle) (Pixel, Pixel) {
+	maxWidth := contentWidth
+	if wrapWidth > 0 {
+		maxWidth = wrapWidth
+	}
+	c.titleLines, c.noteLines = wrapTitle(c.title, c.notes, maxWidth, titleStyle, noteStyle)
+	return titleDimensions(c.titleLines, c.noteLines, titleStyle, noteStyle)
+}
+
+// textElements returns a text element in the given style for each of lines.
+func textElements(lines []string, style TextStyle) []TextElement {
+	tes := make([]TextElement, len(lines))
+	for i := range lines {
+		tes[i] = TextElement{Text: lines[i], Style: style}
+	}
+	return tes
+}
+
+// titleDimensions returns the height and width of the space needed for the lines of the title and
+// notes of a chart.
+func titleDimensions(title []string, notes []string, titleStyle TextStyle, noteStyle TextStyle) (Pixel, Pixel) {
+	if len(title) == 0 && len(notes) == 0 {
 		return 0, 0
 	}
 
 	var h, w Pixel
 
-	if title != "" {
-		h += titleStyle.LineHeight
-		w = textWidth([]rune(title), titleStyle.FontSize)
+	if len(title) != 0 {
+		h += titleStyle.LineHeight * Pixel(len(title))
+		for i := 0; i < len(title); i++ {
+			w = max(w, titleStyle.width(title[i]))
+		}
 	}
 
 	if len(notes) != 0 {
 		h += noteStyle.LineHeight * Pixel(len(notes))
 		for i := 0; i < len(notes); i++ {
-			wl := textWidth([]rune(notes[i]), noteStyle.FontSize)
+			wl := noteStyle.width(notes[i])
 			if wl > w {
 				w = wl
 			}
@@ -310,3 +772,14 @@ func titleDimensions(title string, notes []string, titleStyle TextStyle, noteSty
 
 	return h, w
 }
+
+// ScaleToWidth returns a uniform scale factor that may be applied to the layout so that its
+// width does not exceed target. It never enlarges a layout, so a layout that already fits
+// within target will return a scale factor of 1.
+func ScaleToWidth(lay Layout, target Pixel) float64 {
+	w := lay.Width()
+	if w <= 0 || w <= target || target <= 0 {
+		return 1
+	}
+	return float64(target) / float64(w)
+}
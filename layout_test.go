@@ -1,6 +1,15 @@
 package gtree
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
 
 var (
 	onePerson = &DescendantChart{
@@ -10,6 +19,31 @@ var (
 		},
 	}
 
+	onePersonNoText = &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{},
+			Details:  []string{},
+		},
+	}
+
+	onePersonWithOneChild = &DescendantChart{
+		Root: &DescendantPerson{
+			ID:      1,
+			Details: []string{"Person One"},
+			Families: []*DescendantFamily{
+				{
+					Children: []*DescendantPerson{
+						{
+							ID:      2,
+							Details: []string{"Person Two With A Much Longer Name"},
+						},
+					},
+				},
+			},
+		},
+	}
+
 	onePersonWithSpouse = &DescendantChart{
 		Root: &DescendantPerson{
 			ID:      1,
@@ -52,6 +86,98 @@ var (
 		},
 	}
 
+	onePersonWithMarriageDetails = &DescendantChart{
+		Root: &DescendantPerson{
+			ID:      1,
+			Details: []string{"Person One"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{
+						ID:      2,
+						Details: []string{"Person Two"},
+					},
+					Details: []string{"m. 14 Aug 1875"},
+					Children: []*DescendantPerson{
+						{
+							ID:      3,
+							Details: []string{"Person Three"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	onePersonWithTwoFamilies = &DescendantChart{
+		Root: &DescendantPerson{
+			ID:      1,
+			Details: []string{"Person One"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{
+						ID:      2,
+						Details: []string{"Person Two"},
+					},
+					Children: []*DescendantPerson{
+						{
+							ID:      3,
+							Details: []string{"Person Three"},
+						},
+						{
+							ID:      4,
+							Details: []string{"Person Four"},
+						},
+					},
+				},
+				{
+					Other: &DescendantPerson{
+						ID:      5,
+						Details: []string{"Person Five"},
+					},
+					Children: []*DescendantPerson{
+						{
+							ID:      6,
+							Details: []string{"Person Six"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	onePersonWithChildlessFamilies = &DescendantChart{
+		Root: &DescendantPerson{
+			ID:      1,
+			Details: []string{"Person One"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{
+						ID:      2,
+						Details: []string{"Person Two"},
+					},
+				},
+				{
+					Other: &DescendantPerson{
+						ID:      3,
+						Details: []string{"Person Three"},
+					},
+					Children: []*DescendantPerson{
+						{
+							ID:      4,
+							Details: []string{"Person Four"},
+						},
+					},
+				},
+				{
+					Other: &DescendantPerson{
+						ID:      5,
+						Details: []string{"Person Five"},
+					},
+				},
+			},
+		},
+	}
+
 	onePersonWithSpouseAndChildren = &DescendantChart{
 		Root: &DescendantPerson{
 			ID:      1,
@@ -82,6 +208,7 @@ func TestVerticalLayout(t *testing.T) {
 	testCases := []struct {
 		name       string
 		in         *DescendantChart
+		opts       *LayoutOptions
 		assertions []layoutAssertion
 	}{
 		{
@@ -95,6 +222,27 @@ func TestVerticalLayout(t *testing.T) {
 					inRow(0),
 			},
 		},
+		{
+			name: "one person no text",
+			in:   onePersonNoText,
+			assertions: []layoutAssertion{
+				blurb(1).
+					hasText("").
+					hasNoParent().
+					hasNoLeftNeighbour().
+					inRow(0),
+			},
+		},
+		{
+			name: "one person with one child",
+			in:   onePersonWithOneChild,
+			assertions: []layoutAssertion{
+				blurb(2).
+					hasParent(1).
+					isCentredUnder(1).
+					inRow(1),
+			},
+		},
 		{
 			name: "one person with spouse",
 			in:   onePersonWithSpouse,
@@ -195,12 +343,137 @@ func TestVerticalLayout(t *testing.T) {
 					hasLeftNeighbour(3),
 			},
 		},
+		{
+			name: "marriage details",
+			in:   onePersonWithMarriageDetails,
+			assertions: []layoutAssertion{
+				blurb(-2).
+					hasText("=", "m. 14 Aug 1875").
+					hasDetailStyle(DefaultLayoutOptions().MarriageDetailStyle).
+					inRow(0),
+				blurb(3).
+					hasParent(-2).
+					inRow(1),
+			},
+		},
+		{
+			name: "generation window",
+			in:   onePersonWithTwoFamilies,
+			opts: func() *LayoutOptions {
+				opts := DefaultLayoutOptions()
+				opts.MinGeneration = 2
+				opts.MaxGeneration = 2
+				return opts
+			}(),
+			assertions: []layoutAssertion{
+				noBlurb(1),
+				noBlurb(2),
+				noBlurb(-2),
+				blurb(3).
+					hasText("Person Three", "descendant of Person One").
+					hasNoParent().
+					inRow(0),
+				blurb(4).
+					hasText("Person Four", "descendant of Person One").
+					inRow(0),
+				blurb(6).
+					hasText("Person Six", "descendant of Person One").
+					inRow(0),
+			},
+		},
+		{
+			name: "generation limit",
+			in:   onePersonWithTwoFamilies,
+			opts: func() *LayoutOptions {
+				opts := DefaultLayoutOptions()
+				opts.MaxGeneration = 1
+				return opts
+			}(),
+			assertions: []layoutAssertion{
+				blurb(1).
+					hasText("Person One").
+					inRow(0),
+				blurb(-2).
+					inRow(0),
+				noBlurb(3),
+				noBlurb(4),
+				noBlurb(6),
+			},
+		},
+		{
+			name: "hide childless families",
+			in:   onePersonWithChildlessFamilies,
+			opts: func() *LayoutOptions {
+				opts := DefaultLayoutOptions()
+				opts.HideChildlessFamilies = true
+				return opts
+			}(),
+			assertions: []layoutAssertion{
+				blurb(1).
+					hasText("Person One").
+					hasNoLeftNeighbour().
+					hasKeepTightRight(-3).
+					inRow(0),
+				blurb(-3).
+					hasText("=").
+					hasLeftNeighbour(1).
+					inRow(0),
+				blurb(3).
+					hasText("Person Three").
+					hasLeftNeighbour(-3).
+					inRow(0),
+				blurb(4).
+					hasText("Person Four").
+					hasParent(-3).
+					inRow(1),
+				noBlurb(2),
+				noBlurb(-2),
+				noBlurb(5),
+				noBlurb(-5),
+			},
+		},
+		{
+			name: "hide childless first family",
+			in: &DescendantChart{
+				Root: &DescendantPerson{
+					ID:      1,
+					Details: []string{"Person One"},
+					Families: []*DescendantFamily{
+						{Other: &DescendantPerson{ID: 2, Details: []string{"Person Two"}}},
+						{
+							Other:    &DescendantPerson{ID: 3, Details: []string{"Person Three"}},
+							Children: []*DescendantPerson{{ID: 4, Details: []string{"Person Four"}}},
+						},
+						{
+							Other:    &DescendantPerson{ID: 5, Details: []string{"Person Five"}},
+							Children: []*DescendantPerson{{ID: 6, Details: []string{"Person Six"}}},
+						},
+					},
+				},
+			},
+			opts: func() *LayoutOptions {
+				opts := DefaultLayoutOptions()
+				opts.HideChildlessFamilies = true
+				return opts
+			}(),
+			assertions: []layoutAssertion{
+				// only the visible families are numbered
+				blurb(-3).
+					hasText("= (1)").
+					inRow(0),
+				blurb(-5).
+					hasText("= (2)").
+					inRow(0),
+				noBlurb(2),
+				noBlurb(-2),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			l := tc.in.Layout(nil)
+			l := tc.in.Layout(tc.opts)
 
 			for _, a := range tc.assertions {
 				a.assert(t, l)
@@ -213,6 +486,20 @@ type layoutAssertion interface {
 	assert(*testing.T, *DescendantLayout)
 }
 
+func noBlurb(id int) layoutAssertion {
+	return &noBlurbAsserter{id: id}
+}
+
+type noBlurbAsserter struct {
+	id int
+}
+
+func (a *noBlurbAsserter) assert(t *testing.T, l *DescendantLayout) {
+	if _, ok := l.blurbs[a.id]; ok {
+		t.Errorf("blurb %d is present, wanted it to be missing", a.id)
+	}
+}
+
 func blurb(id int) *blurbAsserter {
 	return &blurbAsserter{id: id}
 }
@@ -255,6 +542,15 @@ func (ba *blurbAsserter) hasText(texts ...string) *blurbAsserter {
 	return ba
 }
 
+func (ba *blurbAsserter) hasDetailStyle(style TextStyle) *blurbAsserter {
+	ba.fns = append(ba.fns, func(t *testing.T, b *Blurb, l *DescendantLayout) {
+		if b.DetailTexts.Style != style {
+			t.Errorf("blurb %d: got detail style %+v, wanted %+v", ba.id, b.DetailTexts.Style, style)
+		}
+	})
+	return ba
+}
+
 func (ba *blurbAsserter) inRow(row int) *blurbAsserter {
 	ba.fns = append(ba.fns, func(t *testing.T, b *Blurb, l *DescendantLayout) {
 		if b.Row != row {
@@ -326,6 +622,20 @@ func (ba *blurbAsserter) hasNoParent() *blurbAsserter {
 	return ba
 }
 
+func (ba *blurbAsserter) isCentredUnder(id int) *blurbAsserter {
+	ba.fns = append(ba.fns, func(t *testing.T, b *Blurb, l *DescendantLayout) {
+		other, ok := l.blurbs[id]
+		if !ok {
+			t.Errorf("blurb %d: blurb %d is missing", ba.id, id)
+			return
+		}
+		if b.X() != other.X() {
+			t.Errorf("blurb %d: got centre %d, wanted %d to match blurb %d", ba.id, b.X(), other.X(), id)
+		}
+	})
+	return ba
+}
+
 func (ba *blurbAsserter) hasNoShift() *blurbAsserter {
 	ba.fns = append(ba.fns, func(t *testing.T, b *Blurb, l *DescendantLayout) {
 		if !b.NoShift {
@@ -373,3 +683,2096 @@ func (ba *blurbAsserter) hasKeepTightRight(id int) *blurbAsserter {
 	})
 	return ba
 }
+
+func TestScaleToWidth(t *testing.T) {
+	l := onePersonWithSpouseAndChildren.Layout(nil)
+
+	if s := ScaleToWidth(l, l.Width()*2); s != 1 {
+		t.Errorf("got scale %v for layout narrower than target, wanted 1", s)
+	}
+
+	target := l.Width() / 3
+	s := ScaleToWidth(l, target)
+	if w := scalePixel(l.Width(), s); w > target {
+		t.Errorf("got scaled width %d, wanted no more than %d", w, target)
+	}
+}
+
+func TestHighlightPath(t *testing.T) {
+	l := onePersonWithSpouseAndChildren.Layout(nil)
+	l.HighlightPath(3, 4)
+
+	for id, want := range map[int]bool{1: true, 2: true, 3: true, 4: true, -2: false} {
+		if got := l.blurbs[id].Highlight; got != want {
+			t.Errorf("blurb %d: got highlight %v, wanted %v", id, got, want)
+		}
+	}
+
+	for _, id := range []int{3, 4} {
+		if !l.parentConnectors[id][0].Highlight {
+			t.Errorf("connector to blurb %d: not highlighted", id)
+		}
+	}
+
+	l = onePersonWithSpouseAndChildren.Layout(nil)
+	l.HighlightPath(2, 3)
+
+	for id, want := range map[int]bool{1: false, 2: true, 3: true, 4: false} {
+		if got := l.blurbs[id].Highlight; got != want {
+			t.Errorf("blurb %d: got highlight %v, wanted %v", id, got, want)
+		}
+	}
+	if l.parentConnectors[4][0].Highlight {
+		t.Errorf("connector to blurb 4: got highlighted, wanted not highlighted")
+	}
+}
+
+// straightRouter joins each child directly to its parent with a single diagonal line.
+type straightRouter struct{}
+
+func (r straightRouter) Route(l *DescendantLayout) []*Connector {
+	var cs []*Connector
+	for _, b := range l.Blurbs() {
+		if b.Parent == nil {
+			continue
+		}
+		cs = append(cs, &Connector{
+			Points:   []Point{{X: b.TopHookX(), Y: b.TopPos}, {X: b.Parent.X(), Y: b.Parent.Bottom()}},
+			Children: []int{b.ID},
+		})
+	}
+	return cs
+}
+
+func TestConnectorRouter(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	opts.ConnectorRouter = straightRouter{}
+	l := onePersonWithSpouseAndChildren.Layout(opts)
+
+	natural := onePersonWithSpouseAndChildren.Layout(nil)
+	if got, want := len(l.Connectors()), len(natural.Connectors()); got != want {
+		t.Fatalf("got %d connectors, wanted %d", got, want)
+	}
+	for _, c := range l.Connectors() {
+		if len(c.Points) != 2 {
+			t.Errorf("got connector with %d points, wanted 2", len(c.Points))
+		}
+	}
+
+	// blurbs are positioned the same whatever the routing
+	for id, b := range natural.blurbs {
+		if got, want := l.blurbs[id].Left(), b.Left(); got != want {
+			t.Errorf("blurb %d: got left %d, wanted %d", id, got, want)
+		}
+	}
+
+	// routed connectors can still be highlighted
+	l.HighlightPath(3, 4)
+	for _, id := range []int{3, 4} {
+		cs := l.parentConnectors[id]
+		if len(cs) != 1 || !cs[0].Highlight {
+			t.Errorf("connector to blurb %d: not highlighted", id)
+		}
+	}
+}
+
+func TestSiblingBar(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	opts.SiblingBar = true
+	l := onePersonWithSpouseAndChildren.Layout(opts)
+
+	rel := l.blurbs[-2]
+	c3, c4 := l.blurbs[3], l.blurbs[4]
+	barY := c3.TopPos - opts.LineGap - opts.ChildDrop
+
+	var barLeft, barRight Pixel = l.Width(), 0
+	var verticals int
+	for _, c := range l.connectors {
+		if len(c.Points) != 2 {
+			t.Fatalf("got connector with %d points, wanted straight lines", len(c.Points))
+		}
+		p0, p1 := c.Points[0], c.Points[1]
+		switch {
+		case p0.Y == barY && p1.Y == barY:
+			barLeft = min(barLeft, p0.X, p1.X)
+			barRight = max(barRight, p0.X, p1.X)
+		case p0.X == p1.X:
+			verticals++
+		default:
+			t.Errorf("got connector %v, wanted horizontal bar or vertical stub", c.Points)
+		}
+	}
+
+	if barLeft != c3.TopHookX() || barRight != c4.TopHookX() {
+		t.Errorf("got bar from %d to %d, wanted %d to %d", barLeft, barRight, c3.TopHookX(), c4.TopHookX())
+	}
+
+	// one stub for each child and one for the parent
+	if verticals != 3 {
+		t.Errorf("got %d vertical stubs, wanted 3", verticals)
+	}
+
+	l.HighlightPath(3, 1)
+	for _, c := range l.connectors {
+		p0, p1 := c.Points[0], c.Points[1]
+		onPath := p0.X == c3.TopHookX() && p1.X == c3.TopHookX() || // stub to child
+			p0.X == rel.X() && p1.X == rel.X() || // stub to parent
+			p0.Y == barY && p1.Y == barY && max(p0.X, p1.X) <= rel.X() // bar between child and parent
+		if c.Highlight != onPath {
+			t.Errorf("connector %v: got highlight %v, wanted %v", c.Points, c.Highlight, onPath)
+		}
+	}
+}
+
+func TestLeftMargin(t *testing.T) {
+	leftmost := func(l *DescendantLayout) Pixel {
+		left := l.Width()
+		for _, b := range l.blurbs {
+			left = min(left, b.Left())
+		}
+		return left
+	}
+	rightmost := func(l *DescendantLayout) Pixel {
+		right := Pixel(0)
+		for _, b := range l.blurbs {
+			right = max(right, b.Right())
+		}
+		return right
+	}
+
+	// the leftmost blurb starts at the margin and the rightmost ends at the opposite margin
+	l := onePersonWithSpouseAndChildren.Layout(nil)
+	if got, want := leftmost(l), l.Margin(); got != want {
+		t.Errorf("got left edge %d, wanted %d", got, want)
+	}
+	if got, want := l.Width(), rightmost(l)+l.Margin(); got != want {
+		t.Errorf("got width %d, wanted %d", got, want)
+	}
+
+	// generation labels take a gutter between the margin and the leftmost blurb
+	opts := DefaultLayoutOptions()
+	opts.ShowGenerationLabels = true
+	l = onePersonWithSpouseAndChildren.Layout(opts)
+	gutter := Pixel(0)
+	for row := range l.rows {
+		gutter = max(gutter, textWidth([]rune(l.generationLabel(row)), opts.GenerationLabelStyle.FontSize))
+	}
+	if got, want := leftmost(l), l.Margin()+gutter+opts.Hspace; got != want {
+		t.Errorf("got left edge %d with generation labels, wanted %d", got, want)
+	}
+}
+
+func TestSingleRowLayout(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Details:  []string{"b. 1820"},
+			Families: []*DescendantFamily{
+				{Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}}},
+				{Other: &DescendantPerson{ID: 3, Headings: []string{"C. White"}}},
+			},
+		},
+	}
+
+	l := ch.Layout(nil)
+	if len(l.rows) != 1 {
+		t.Fatalf("got %d rows, wanted a single row", len(l.rows))
+	}
+
+	// the blurbs are placed from left to right within the margins and the layout is sized to them
+	row := l.rows[0]
+	var right, bottom Pixel
+	for i, b := range row {
+		if b.Left() < l.Margin() || b.TopPos < l.Margin() {
+			t.Errorf("blurb %d: got position (%d,%d), wanted within the margin %d", b.ID, b.Left(), b.TopPos, l.Margin())
+		}
+		if i > 0 && b.Left() < row[i-1].Right() {
+			t.Errorf("blurb %d: starts at %d, overlapping blurb %d ending at %d", b.ID, b.Left(), row[i-1].ID, row[i-1].Right())
+		}
+		right, bottom = max(right, b.Right()), max(bottom, b.Bottom())
+	}
+	if got, want := l.Width(), right+l.Margin(); got != want {
+		t.Errorf("got width %d, wanted %d", got, want)
+	}
+	if l.Height() < bottom+l.Margin() {
+		t.Errorf("got height %d, wanted at least %d", l.Height(), bottom+l.Margin())
+	}
+}
+
+func TestRowGap(t *testing.T) {
+	natural := onePersonWithSpouseAndChildren.Layout(nil)
+
+	opts := DefaultLayoutOptions()
+	opts.RowGap = 40
+	l := onePersonWithSpouseAndChildren.Layout(opts)
+
+	rowDistance := func(l *DescendantLayout) Pixel { return l.blurbs[3].TopPos - l.blurbs[1].Bottom() }
+	if got, want := rowDistance(l), rowDistance(natural)+40; got != want {
+		t.Errorf("got distance between rows %d, wanted %d", got, want)
+	}
+	if got, want := l.Height(), natural.Height()+40; got != want {
+		t.Errorf("got height %d, wanted %d", got, want)
+	}
+
+	// connectors still join each child to the relationship marker
+	rel := l.blurbs[-2]
+	for _, id := range []int{3, 4} {
+		pts := l.parentConnectors[id][0].Points
+		if got, want := pts[0].Y, l.blurbs[id].TopPos-opts.LineGap; got != want {
+			t.Errorf("connector to blurb %d: got start at %d, wanted %d", id, got, want)
+		}
+		if got, want := pts[len(pts)-1].Y, rel.Bottom()+opts.LineGap; got != want {
+			t.Errorf("connector to blurb %d: got end at %d, wanted %d", id, got, want)
+		}
+	}
+}
+
+func TestUniformRowHeight(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Children: []*DescendantPerson{
+						{
+							ID:       2,
+							Headings: []string{"B. Brown"},
+							Details:  []string{"b. 1850", "d. 1901", "farmer", "res. Leeds", "res. York"},
+							Families: []*DescendantFamily{
+								{
+									Children: []*DescendantPerson{
+										{ID: 3, Headings: []string{"C. Brown"}, Details: []string{"b. 1875"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rowTops := func(l *DescendantLayout) []Pixel {
+		var tops []Pixel
+		for row := range l.rows {
+			tops = append(tops, l.rowTop(row))
+		}
+		return tops
+	}
+
+	opts := DefaultLayoutOptions()
+	tops := rowTops(ch.Layout(opts))
+	if tops[1]-tops[0] == tops[2]-tops[1] {
+		t.Fatalf("rows unexpectedly evenly spaced without UniformRowHeight: %v", tops)
+	}
+
+	opts.UniformRowHeight = true
+	l := ch.Layout(opts)
+	tops = rowTops(l)
+	if tops[1]-tops[0] != tops[2]-tops[1] {
+		t.Errorf("got row tops %v, wanted them evenly spaced", tops)
+	}
+	if got, want := tops[2]-tops[1], l.blurbs[2].Height+l.rowDrop(1)+opts.RowGap; got != want {
+		t.Errorf("got row spacing %d, wanted %d to fit the tallest blurb", got, want)
+	}
+}
+
+func TestCentreMarkers(t *testing.T) {
+	// the first child is pulled across towards its sibling, leaving the children off centre
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"C. Brown"}},
+						{
+							ID:       4,
+							Headings: []string{"D. Brown"},
+							Families: []*DescendantFamily{
+								{
+									Other: &DescendantPerson{ID: 5, Headings: []string{"E. White"}},
+									Children: []*DescendantPerson{
+										{ID: 6, Headings: []string{"F. Brown"}},
+										{ID: 7, Headings: []string{"G. Brown"}},
+										{ID: 8, Headings: []string{"H. Brown"}},
+										{ID: 9, Headings: []string{"I. Brown"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	childrenMid := func(b *Blurb) Pixel { return (b.FirstChild.Left() + b.LastChild.Right()) / 2 }
+
+	opts := DefaultLayoutOptions()
+	l := ch.Layout(opts)
+	if rel := l.blurbs[-2]; rel.X() == childrenMid(rel) {
+		t.Fatalf("marker unexpectedly centred over its children without CentreMarkers")
+	}
+
+	opts.CentreMarkers = true
+	l = ch.Layout(opts)
+	for _, id := range []int{-2, -5} {
+		rel := l.blurbs[id]
+		if got, want := rel.X(), childrenMid(rel); got != want {
+			t.Errorf("marker %d: got x %d, wanted %d at the middle of its children", id, got, want)
+		}
+	}
+	for row, bs := range l.rows {
+		for i := 1; i < len(bs); i++ {
+			if bs[i].Left()-bs[i-1].Right() < opts.Hspace {
+				t.Errorf("row %d: blurbs %d and %d are too close", row, bs[i-1].ID, bs[i].ID)
+			}
+		}
+	}
+}
+
+func TestAlignChildrenTop(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Children: []*DescendantPerson{
+						{ID: 2, Headings: []string{"B. Brown"}, Details: []string{"b. 1850", "d. 1901", "farmer"}},
+						{ID: 3, Headings: []string{"C. Brown"}},
+						{ID: 4, Headings: []string{"D. Brown"}, Details: []string{"b. 1855"}},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.FocusID = 3
+	baseline := func(b *Blurb) Pixel { return b.TopPos + b.HeadingTexts.Style.LineHeight }
+
+	l := ch.Layout(opts)
+	if baseline(l.blurbs[2]) == baseline(l.blurbs[3]) {
+		t.Fatalf("headings of different styles unexpectedly aligned without AlignChildrenTop")
+	}
+
+	opts.AlignChildrenTop = true
+	l = ch.Layout(opts)
+	for _, id := range []int{2, 4} {
+		if got, want := baseline(l.blurbs[id]), baseline(l.blurbs[3]); got != want {
+			t.Errorf("blurb %d: got heading baseline %d, wanted %d", id, got, want)
+		}
+	}
+	if l.blurbs[2].TopPos != l.blurbs[4].TopPos {
+		t.Errorf("got siblings with the same heading style at tops %d and %d", l.blurbs[2].TopPos, l.blurbs[4].TopPos)
+	}
+
+	// the connectors still meet each child and share a single horizontal run
+	var runY []Pixel
+	for _, id := range []int{2, 3, 4} {
+		pts := l.parentConnectors[id][0].Points
+		if got, want := pts[0].Y, l.blurbs[id].TopPos-opts.LineGap; got != want {
+			t.Errorf("blurb %d: got connector start at %d, wanted %d", id, got, want)
+		}
+		runY = append(runY, pts[1].Y)
+	}
+	if runY[0] != runY[1] || runY[1] != runY[2] {
+		t.Errorf("got horizontal connector runs at %v, wanted all the same", runY)
+	}
+}
+
+func TestFamilyDropOverride(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:      1,
+			Details: []string{"Person One"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{
+						ID:      2,
+						Details: []string{"Person Two"},
+					},
+					Children: []*DescendantPerson{
+						{ID: 3, Details: []string{"Person Three"}},
+						{ID: 4, Details: []string{"Person Four"}},
+					},
+					FamilyDrop: 20,
+					ChildDrop:  8,
+				},
+			},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	natural := onePersonWithSpouseAndChildren.Layout(opts)
+	l := ch.Layout(opts)
+
+	wantShrink := (opts.FamilyDrop - 20) + (opts.ChildDrop - 8)
+	if got := natural.Height() - l.Height(); got != wantShrink {
+		t.Errorf("got height reduced by %d, wanted %d", got, wantShrink)
+	}
+
+	for _, id := range []int{3, 4} {
+		c := l.parentConnectors[id][0]
+		if got := c.Points[0].Y - c.Points[1].Y; got != 8 {
+			t.Errorf("connector to blurb %d: got child drop %d, wanted 8", id, got)
+		}
+		if got, want := c.Points[2].Y-c.Points[3].Y, 20+opts.LineWidth; got != want {
+			t.Errorf("connector to blurb %d: got family drop %d, wanted %d", id, got, want)
+		}
+	}
+}
+
+func TestCollapsed(t *testing.T) {
+	ch := syntheticChart(3, 2)
+	collapsed := *ch.Root.Families[0].Children[0]
+	collapsed.Collapsed = true
+	ch.Root.Families[0].Children[0] = &collapsed
+
+	l := ch.Layout(nil)
+
+	b, ok := l.blurbs[collapsed.ID]
+	if !ok {
+		t.Fatalf("collapsed person not found")
+	}
+	if !b.Collapsed {
+		t.Errorf("blurb not marked as collapsed")
+	}
+	if got, want := b.DetailTexts.Lines[len(b.DetailTexts.Lines)-1], "2 descendants"; got != want {
+		t.Errorf("got summary %q, wanted %q", got, want)
+	}
+
+	// the spouse and children of the collapsed person are hidden
+	f := collapsed.Families[0]
+	for _, id := range []int{f.Other.ID, f.Children[0].ID, f.Children[1].ID} {
+		if _, ok := l.blurbs[id]; ok {
+			t.Errorf("blurb %d: got shown, wanted hidden", id)
+		}
+		if _, ok := l.parentConnectors[id]; ok {
+			t.Errorf("connector to blurb %d: got shown, wanted hidden", id)
+		}
+	}
+
+	// the sibling of the collapsed person is unaffected
+	sibling := ch.Root.Families[0].Children[1]
+	for _, id := range []int{sibling.Families[0].Children[0].ID, sibling.Families[0].Children[1].ID} {
+		if _, ok := l.blurbs[id]; !ok {
+			t.Errorf("blurb %d: got hidden, wanted shown", id)
+		}
+	}
+}
+
+func TestEmptyDetails(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Root"},
+			Details:  []string{"b. 1900", "", "  ", "d. 1980"},
+		},
+	}
+
+	b := ch.Layout(nil).blurbs[1]
+	if diff := cmp.Diff([]string{"b. 1900", "d. 1980"}, b.DetailTexts.Lines); diff != "" {
+		t.Errorf("dropped details mismatch (-want +got):\n%s", diff)
+	}
+	dropped := b.Height
+
+	opts := DefaultLayoutOptions()
+	opts.KeepEmptyDetails = true
+	b = ch.Layout(opts).blurbs[1]
+	if got, want := len(b.DetailTexts.Lines), 4; got != want {
+		t.Errorf("got %d kept detail lines, wanted %d", got, want)
+	}
+	if got, want := b.Height, dropped+2*opts.DetailStyle.LineHeight; got != want {
+		t.Errorf("got height %d with kept details, wanted %d", got, want)
+	}
+}
+
+func TestDetailColumns(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Root"},
+			Details:  []string{"Farmer", "Baker", "Leeds", "York", "Hull", "Ripon"},
+		},
+	}
+
+	single := ch.Layout(nil).blurbs[1]
+
+	opts := DefaultLayoutOptions()
+	opts.DetailColumns = 2
+	double := ch.Layout(opts).blurbs[1]
+
+	if got, want := double.DetailTexts.Columns, 2; got != want {
+		t.Fatalf("got %d columns, wanted %d", got, want)
+	}
+	if got, want := double.DetailTexts.Rows(), 3; got != want {
+		t.Errorf("got %d rows, wanted %d", got, want)
+	}
+
+	detailHeight := func(b *Blurb) Pixel { return b.Height - b.HeadingTexts.Style.LineHeight }
+	if got, want := detailHeight(double), detailHeight(single)/2; got != want {
+		t.Errorf("got detail height %d in two columns, wanted %d", got, want)
+	}
+	if double.Width <= single.Width {
+		t.Errorf("got width %d in two columns, wanted wider than %d", double.Width, single.Width)
+	}
+
+	// columns that would exceed the wrap width are not used
+	opts.DetailWrapWidth = single.Width
+	narrow := ch.Layout(opts).blurbs[1]
+	if narrow.DetailTexts.Columns > 1 {
+		t.Errorf("got %d columns, wanted a single column when too narrow", narrow.DetailTexts.Columns)
+	}
+}
+
+func TestTitleWrap(t *testing.T) {
+	ch := *onePersonWithSpouseAndChildren
+	natural := ch.Layout(nil)
+
+	ch.Title = strings.Repeat("The descendants of a family with a remarkably long title ", 4)
+	ch.Notes = []string{"A note that is also far too long to fit within the width of such a small chart"}
+	opts := DefaultLayoutOptions()
+	l := ch.Layout(opts)
+
+	if got, want := l.Width(), natural.Width(); got != want {
+		t.Errorf("got width %d, wanted %d", got, want)
+	}
+
+	titles := l.TitleLines()
+	if len(titles) < 2 {
+		t.Fatalf("got %d title lines, wanted title to be wrapped", len(titles))
+	}
+	notes := l.Notes()
+	if len(notes) < 2 {
+		t.Fatalf("got %d note lines, wanted notes to be wrapped", len(notes))
+	}
+	for _, te := range append(titles, notes...) {
+		if w := MeasureText(te.Text, te.Style); w > l.Width()-2*opts.Margin {
+			t.Errorf("got line %q with width %d, wanted at most %d", te.Text, w, l.Width()-2*opts.Margin)
+		}
+	}
+
+	wantHeight := natural.Height() + opts.TitleStyle.LineHeight*Pixel(len(titles)) + opts.NoteStyle.LineHeight*Pixel(len(notes))
+	if got := l.Height(); got != wantHeight {
+		t.Errorf("got height %d, wanted %d", got, wantHeight)
+	}
+
+	// a wrap width wider than the chart widens it to fit the title
+	opts.TitleWrapWidth = natural.Width() * 2
+	l = ch.Layout(opts)
+	if got := l.Width(); got <= natural.Width() {
+		t.Errorf("got width %d, wanted wider than %d", got, natural.Width())
+	}
+}
+
+func TestAnonymousFamily(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"C. Brown"}},
+						{ID: 4, Headings: []string{"D. Brown"}},
+					},
+				},
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{
+						{ID: 5, Headings: []string{"E. Brown"}},
+						{ID: 6, Headings: []string{"F. Brown"}},
+					},
+				},
+			},
+		},
+	}
+
+	l := ch.Layout(nil)
+	person, marker := l.blurbs[1], l.blurbs[-2]
+
+	// barY returns the height of the line joining a child to its siblings
+	barY := func(id int) Pixel { return l.parentConnectors[id][0].Points[1].Y }
+
+	for _, id := range []int{3, 4} {
+		if l.blurbs[id].Parent != person {
+			t.Errorf("blurb %d: got parent %d, wanted %d", id, l.blurbs[id].Parent.ID, person.ID)
+		}
+		pts := l.parentConnectors[id][0].Points
+		if got, want := pts[len(pts)-1], (Point{X: person.X(), Y: person.Bottom() + l.opts.LineGap}); got != want {
+			t.Errorf("connector to blurb %d: got end %v, wanted %v", id, got, want)
+		}
+		if barY(id) >= barY(5) {
+			t.Errorf("connector to blurb %d: got line at %d, wanted above the spouse family line at %d", id, barY(id), barY(5))
+		}
+	}
+	for _, id := range []int{5, 6} {
+		if l.blurbs[id].Parent != marker {
+			t.Errorf("blurb %d: got parent %d, wanted %d", id, l.blurbs[id].Parent.ID, marker.ID)
+		}
+	}
+
+	// separating the families needs no extra height
+	natural := onePersonWithSpouseAndChildren.Layout(nil)
+	if got, want := l.Height(), natural.Height(); got != want {
+		t.Errorf("got height %d, wanted %d", got, want)
+	}
+}
+
+func TestFontScale(t *testing.T) {
+	natural := onePersonWithSpouseAndChildren.Layout(nil)
+
+	opts := DefaultLayoutOptions()
+	opts.FontScale = 2
+	l := onePersonWithSpouseAndChildren.Layout(opts)
+
+	for id, b := range natural.blurbs {
+		if got, want := l.blurbs[id].Height, 2*b.Height; got != want {
+			t.Errorf("blurb %d: got height %d, wanted %d", id, got, want)
+		}
+		if got, want := l.blurbs[id].HeadingTexts.Style.FontSize, 2*b.HeadingTexts.Style.FontSize; got != want {
+			t.Errorf("blurb %d: got heading font size %d, wanted %d", id, got, want)
+		}
+	}
+
+	// the options passed in are not changed
+	if got, want := opts.HeadingStyle.FontSize, DefaultLayoutOptions().HeadingStyle.FontSize; got != want {
+		t.Errorf("got option heading font size %d, wanted %d", got, want)
+	}
+}
+
+func TestFamilyGap(t *testing.T) {
+	testCases := []struct {
+		gap  Pixel
+		want Pixel
+	}{
+		{gap: 0, want: 16},  // no family gap leaves the families the default hspace apart
+		{gap: 12, want: 16}, // nor does a gap narrower than hspace bring them closer
+		{gap: 48, want: 48}, // the default
+		{gap: 100, want: 100},
+	}
+
+	for _, tc := range testCases {
+		opts := DefaultLayoutOptions()
+		opts.FamilyGap = tc.gap
+		l := onePersonWithTwoFamilies.Layout(opts)
+
+		// the last child of the first family and the first child of the second are the closest blurbs
+		// of different families
+		if got := l.blurbs[6].Left() - l.blurbs[4].Right(); got != tc.want {
+			t.Errorf("family gap %d: got gap of %d between families, wanted %d", tc.gap, got, tc.want)
+		}
+	}
+}
+
+func TestChildSpacing(t *testing.T) {
+	for _, spacing := range []Pixel{0, 4, 40} {
+		opts := DefaultLayoutOptions()
+		opts.ChildSpacing = spacing
+		l := onePersonWithSpouseAndChildren.Layout(opts)
+
+		want := spacing
+		if want == 0 {
+			want = opts.Hspace
+		}
+		if got := l.blurbs[4].Left() - l.blurbs[3].Right(); got != want {
+			t.Errorf("child spacing %d: got sibling gap %d, wanted %d", spacing, got, want)
+		}
+
+		// spacing between the person and their spouse is unaffected
+		if got := l.blurbs[-2].Left() - l.blurbs[1].Right(); got != opts.Hspace {
+			t.Errorf("child spacing %d: got spouse gap %d, wanted %d", spacing, got, opts.Hspace)
+		}
+	}
+}
+
+// TestSpreadingPositions pins the position of every blurb in a chart with uneven families, several
+// spouses and subtrees of differing depths so that changes to the spreading passes of the arranger
+// that are meant to leave the result unchanged can be checked.
+func TestSpreadingPositions(t *testing.T) {
+	in := lines(
+		"1. Albert Brown (b. 1820)",
+		"  sp. Beatrice Green",
+		"    2. Charles Brown (b. 1845)",
+		"      sp. Dora White",
+		"        3. Edward Brown",
+		"        3. Florence Brown",
+		"          sp. George Black",
+		"            4. Harold Black",
+		"            4. Irene Black",
+		"            4. Jack Black",
+		"        3. Kate Brown",
+		"    2. Lucy Brown",
+		"    2. Mabel Brown (b. 1850)",
+		"      sp. Norman Grey",
+		"        3. Oliver Grey",
+		"  sp. Patricia Stone",
+		"    2. Quentin Brown",
+		"      sp. Rose Hill",
+		"        3. Sarah Brown",
+		"          sp. Thomas Reed",
+		"            4. Ursula Reed",
+		"            4. Victor Reed",
+		"        3. Walter Brown",
+		"    2. Xavier Brown",
+	)
+	ch, err := (&Parser{}).Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	// left and top of each blurb, keyed by id
+	want := map[int][2]Pixel{
+		-20: {1098, 260},
+		-18: {1274, 138},
+		-16: {1303, 16},
+		-14: {853, 138},
+		-7:  {365, 260},
+		-4:  {335, 138},
+		-2:  {369, 16},
+		1:   {217, 16},
+		2:   {435, 16},
+		3:   {168, 138},
+		4:   {367, 138},
+		5:   {16, 260},
+		6:   {186, 260},
+		7:   {397, 260},
+		8:   {178, 364},
+		9:   {329, 364},
+		10:  {461, 364},
+		11:  {583, 260},
+		12:  {562, 138},
+		13:  {701, 138},
+		14:  {885, 138},
+		15:  {803, 260},
+		16:  {1369, 16},
+		17:  {1105, 138},
+		18:  {1306, 138},
+		19:  {947, 260},
+		20:  {1130, 260},
+		21:  {977, 364},
+		22:  {1117, 364},
+		23:  {1412, 260},
+		24:  {1445, 138},
+	}
+
+	l := ch.Layout(nil)
+	got := make(map[int][2]Pixel, len(l.blurbs))
+	for id, b := range l.blurbs {
+		got[id] = [2]Pixel{b.Left(), b.TopPos}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("positions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAlignLoneChildren(t *testing.T) {
+	l := onePersonWithOneChild.Layout(nil)
+	parent, child := l.blurbs[1], l.blurbs[2]
+
+	// simulate an earlier pass having moved the child away from its parent
+	child.LeftPos += 10
+
+	a := new(SpreadingDescendantArranger)
+	a.alignLoneChildren(l)
+
+	if child.X() != parent.X() {
+		t.Errorf("got child centre %d, wanted %d", child.X(), parent.X())
+	}
+}
+
+func TestUnitConversions(t *testing.T) {
+	testCases := []struct {
+		name string
+		got  Pixel
+		want Pixel
+	}{
+		{name: "points", got: Points(12), want: 16},
+		{name: "inches", got: Inches(0.5), want: 48},
+		{name: "millimetres", got: Millimetres(25.4), want: 96},
+		{name: "ems", got: Ems(1.5, 16), want: 24},
+		{name: "points at 300dpi", got: Resolution(300).Points(12), want: 50},
+	}
+
+	for _, tc := range testCases {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %d, wanted %d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestStackSpouses(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	opts.StackSpouses = true
+	l := onePersonWithTwoFamilies.Layout(opts)
+
+	// each relationship marker and spouse should follow the previous one as closely as possible
+	order := []int{1, -2, 2, -5, 5}
+	for i := 1; i < len(order); i++ {
+		left, right := l.blurbs[order[i-1]], l.blurbs[order[i]]
+		if gap := right.Left() - left.Right(); gap != opts.Hspace {
+			t.Errorf("got gap of %d between blurbs %d and %d, wanted %d", gap, left.ID, right.ID, opts.Hspace)
+		}
+	}
+
+	unstacked := onePersonWithTwoFamilies.Layout(nil)
+	if l.Width() > unstacked.Width() {
+		t.Errorf("got stacked width %d, wanted no more than unstacked width %d", l.Width(), unstacked.Width())
+	}
+}
+
+func TestClickRegions(t *testing.T) {
+	l := onePersonWithSpouseAndChildren.Layout(nil)
+	rs := l.ClickRegions()
+
+	if len(rs) != 4 {
+		t.Fatalf("got %d regions, wanted 4", len(rs))
+	}
+
+	for i, r := range rs {
+		if r.ID != i+1 {
+			t.Errorf("region %d: got id %d, wanted %d", i, r.ID, i+1)
+		}
+		b := l.blurbs[r.ID]
+		if r.Left != b.Left() || r.Top != b.TopPos || r.Width != b.Width || r.Height != b.Height {
+			t.Errorf("region %d: got %+v, does not match blurb bounds", i, r)
+		}
+		if r.Left < 0 || r.Top < 0 || r.Left+r.Width > l.Width() || r.Top+r.Height > l.Height() {
+			t.Errorf("region %d: got %+v, outside layout bounds %dx%d", i, r, l.Width(), l.Height())
+		}
+	}
+}
+
+func TestFixedWidth(t *testing.T) {
+	natural := onePersonWithTwoFamilies.Layout(nil)
+
+	testCases := []struct {
+		name         string
+		width        Pixel
+		wantWidth    Pixel
+		wantOverflow bool
+	}{
+		{
+			name:      "wider",
+			width:     natural.Width() + 200,
+			wantWidth: natural.Width() + 200,
+		},
+		{
+			name:      "constrained",
+			width:     natural.Width() - 40,
+			wantWidth: natural.Width() - 40,
+		},
+		{
+			name:         "too narrow",
+			width:        natural.Width() / 4,
+			wantWidth:    natural.Width(),
+			wantOverflow: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.FixedWidth = tc.width
+			l := onePersonWithTwoFamilies.Layout(opts)
+
+			if l.Width() != tc.wantWidth {
+				t.Errorf("got width %d, wanted %d", l.Width(), tc.wantWidth)
+			}
+			if l.Overflow() != tc.wantOverflow {
+				t.Errorf("got overflow %v, wanted %v", l.Overflow(), tc.wantOverflow)
+			}
+			for _, b := range l.blurbs {
+				if b.Left() < 0 || b.Right() > l.Width() {
+					t.Errorf("blurb %d: got horizontal extent %d to %d, outside layout width %d", b.ID, b.Left(), b.Right(), l.Width())
+				}
+			}
+		})
+	}
+}
+
+// syntheticChart builds a chart with the given number of generations in which every person
+// has a single family with the given number of children.
+func syntheticChart(gens int, children int) *DescendantChart {
+	id := 0
+	var person func(gen int) *DescendantPerson
+	person = func(gen int) *DescendantPerson {
+		id++
+		p := &DescendantPerson{
+			ID:       id,
+			Headings: []string{fmt.Sprintf("Person %d", id)},
+			Details:  []string{"b. 1900"},
+		}
+		if gen >= gens {
+			return p
+		}
+		id++
+		f := &DescendantFamily{
+			Other: &DescendantPerson{
+				ID:       id,
+				Headings: []string{fmt.Sprintf("Spouse %d", id)},
+			},
+		}
+		for i := 0; i < children; i++ {
+			f.Children = append(f.Children, person(gen+1))
+		}
+		p.Families = []*DescendantFamily{f}
+		return p
+	}
+
+	return &DescendantChart{Root: person(1)}
+}
+
+func BenchmarkDescendantLayout(b *testing.B) {
+	sizes := []struct {
+		gens     int
+		children int
+	}{
+		{gens: 3, children: 3},
+		{gens: 4, children: 3},
+		{gens: 5, children: 3},
+		{gens: 6, children: 3},
+		{gens: 6, children: 4},
+	}
+
+	for _, sz := range sizes {
+		ch := syntheticChart(sz.gens, sz.children)
+		b.Run(fmt.Sprintf("gens=%d/children=%d", sz.gens, sz.children), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ch.Layout(nil)
+			}
+		})
+	}
+}
+
+func TestWrapLines(t *testing.T) {
+	style := DefaultLayoutOptions().DetailStyle
+	line := "born in the parish of St Mary Magdalene"
+	width := MeasureText(line, style)
+
+	got := WrapLines([]string{line}, width, style)
+	if len(got) != 1 || got[0] != line {
+		t.Errorf("got %q, wanted line to fit unwrapped", got)
+	}
+
+	got = WrapLines([]string{line}, width/2, style)
+	if len(got) < 2 {
+		t.Fatalf("got %q, wanted line to be wrapped", got)
+	}
+	for _, l := range got {
+		if w := MeasureText(l, style); w > width/2 {
+			t.Errorf("got line %q with width %d, wanted at most %d", l, w, width/2)
+		}
+	}
+}
+
+func TestWrapLinesBold(t *testing.T) {
+	regular := DefaultLayoutOptions().DetailStyle
+	bold := regular
+	bold.Bold = true
+	bold.BoldWidth = 1.5
+	line := "born in the parish of St Mary Magdalene"
+	width := MeasureText(line, regular)
+
+	// the line fits in a regular weight but bold text is measured at its wider width
+	if got := WrapLines([]string{line}, width, regular); len(got) != 1 {
+		t.Errorf("got %q, wanted regular line to fit unwrapped", got)
+	}
+	got := WrapLines([]string{line}, width, bold)
+	if len(got) < 2 {
+		t.Fatalf("got %q, wanted bold line to be wrapped", got)
+	}
+	for _, l := range got {
+		if w := MeasureText(l, bold); w > width {
+			t.Errorf("got bold line %q with width %d, wanted at most %d", l, w, width)
+		}
+	}
+}
+
+func TestMeasureTypographicPunctuation(t *testing.T) {
+	style := DefaultLayoutOptions().HeadingStyle
+
+	testCases := []struct {
+		typographic string
+		plain       string
+	}{
+		{typographic: "Alice O’Connor", plain: "Alice O'Connor"},
+		{typographic: "St. Luke‘s", plain: "St. Luke's"},
+		{typographic: "“Nan”", plain: `"Nan"`},
+	}
+	for _, tc := range testCases {
+		if got, want := MeasureText(tc.typographic, style), MeasureText(tc.plain, style); got != want {
+			t.Errorf("%q: got width %d, wanted %d as for %q", tc.typographic, got, want, tc.plain)
+		}
+	}
+
+	// dashes and ellipses are wider than a hyphen or full stop but narrower than an unknown character
+	for _, r := range []string{"–", "—", "…"} {
+		got := MeasureText(r, style)
+		if got <= MeasureText("-", style) || got > style.FontSize {
+			t.Errorf("%q: got width %d, wanted between %d and %d", r, got, MeasureText("-", style), style.FontSize)
+		}
+	}
+}
+
+func TestWrapLinesHyphens(t *testing.T) {
+	style := DefaultLayoutOptions().DetailStyle
+
+	testCases := []struct {
+		line  string
+		width string // text that sets the maximum width
+		want  []string
+	}{
+		{
+			line:  "b. Stratford-upon-Avon",
+			width: "b. Stratford-upon-",
+			want:  []string{"b. Stratford-upon-", "Avon"},
+		},
+		{
+			line:  "r. Newcastle/Gateshead",
+			width: "r. Newcastle/",
+			want:  []string{"r. Newcastle/", "Gateshead"},
+		},
+		{
+			line:  "b. Stratford-upon-Avon",
+			width: "b. Stratford-",
+			want:  []string{"b. Stratford-", "upon-Avon"},
+		},
+	}
+
+	for _, tc := range testCases {
+		width := MeasureText(tc.width, style) + 1
+		got := WrapLines([]string{tc.line}, width, style)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%q: wrapped lines mismatch (-want +got):\n%s", tc.line, diff)
+		}
+		for _, l := range got {
+			if w := MeasureText(l, style); w > width {
+				t.Errorf("%q: got line %q with width %d, wanted at most %d", tc.line, l, w, width)
+			}
+		}
+	}
+}
+
+func TestSectionWrapWidths(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Alexander Bartholomew Brown"},
+			Details:  []string{"b. 1819, London, England"},
+			Families: []*DescendantFamily{
+				{
+					Other:   &DescendantPerson{ID: 2, Headings: []string{"Beatrice Green"}},
+					Details: []string{"m. 1840, St Mary, Lambeth"},
+				},
+			},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.HeadingWrapWidth = MeasureText("Alexander Bartholomew", opts.HeadingStyle) + 1
+	opts.DetailWrapWidth = 1000
+	opts.MarriageDetailWrapWidth = MeasureText("m. 1840, St Mary,", opts.MarriageDetailStyle) + 1
+	l := ch.Layout(opts)
+
+	if diff := cmp.Diff([]string{"Alexander Bartholomew", "Brown"}, l.blurbs[1].HeadingTexts.Lines); diff != "" {
+		t.Errorf("heading lines mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"b. 1819, London, England"}, l.blurbs[1].DetailTexts.Lines); diff != "" {
+		t.Errorf("detail lines mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"m. 1840, St Mary,", "Lambeth"}, l.blurbs[-2].DetailTexts.Lines); diff != "" {
+		t.Errorf("marriage detail lines mismatch (-want +got):\n%s", diff)
+	}
+
+	// by default headings are not wrapped and family details follow DetailWrapWidth
+	opts = DefaultLayoutOptions()
+	opts.DetailWrapWidth = MeasureText("b. 1819, London,", opts.DetailStyle) + 1
+	l = ch.Layout(opts)
+	if got, want := len(l.blurbs[1].HeadingTexts.Lines), 1; got != want {
+		t.Errorf("got %d heading lines, wanted %d", got, want)
+	}
+	if got, want := len(l.blurbs[-2].DetailTexts.Lines), 2; got != want {
+		t.Errorf("got %d marriage detail lines, wanted %d", got, want)
+	}
+}
+
+func TestConnectorsMeetHooks(t *testing.T) {
+	ch := syntheticChart(3, 3)
+
+	bar := DefaultLayoutOptions()
+	bar.SiblingBar = true
+	fixed := DefaultLayoutOptions()
+	fixed.FixedWidth = ch.Layout(nil).Width() + 101
+
+	for name, opts := range map[string]*LayoutOptions{"default": nil, "sibling bar": bar, "fixed width": fixed} {
+		t.Run(name, func(t *testing.T) {
+			l := ch.Layout(opts)
+			for id, cs := range l.parentConnectors {
+				b := l.blurbs[id]
+				start := cs[0].Points[0]
+				if start.X != b.TopHookX() || start.Y != b.TopPos-l.opts.LineGap {
+					t.Errorf("connector to blurb %d: got start %v, wanted (%d,%d)", id, start, b.TopHookX(), b.TopPos-l.opts.LineGap)
+				}
+			}
+		})
+	}
+
+	l := exampleAncestorChart.Layout(nil)
+	for _, c := range l.connectors {
+		start, end := c.Points[0], c.Points[len(c.Points)-1]
+		found := false
+		for _, b := range l.blurbs {
+			if start.X == b.LeftPos-l.opts.LineGap && start.Y == b.SideHookY() {
+				found = true
+				if end.Y != b.LeftNeighbour.SideHookY() {
+					t.Errorf("connector from blurb %d: got end y %d, wanted %d", b.ID, end.Y, b.LeftNeighbour.SideHookY())
+				}
+			}
+		}
+		if !found {
+			t.Errorf("connector starting at %v does not meet a blurb hook", start)
+		}
+	}
+}
+
+func TestConnectorAvoidsBlurbs(t *testing.T) {
+	// With spouses on the left the children of a family without a known other parent that follows
+	// a spouse family are arranged so that a child of the spouse family lies across the line from
+	// E. Brown down to their only child.
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Details:  []string{"b. 1820", "d. 1890"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"C. Brown"}, Details: []string{"b. 1845, St Mary le Bow, London", "d. 1900, London"}},
+						{ID: 4, Headings: []string{"D. Brown"}, Details: []string{"b. 1847, London", "m. 1870, London", "d. 1910, London"}},
+					},
+				},
+				{
+					Children: []*DescendantPerson{
+						{
+							ID:       5,
+							Headings: []string{"E. Brown"},
+							Details:  []string{"b. 1850, London"},
+							Families: []*DescendantFamily{
+								{Children: []*DescendantPerson{{ID: 6, Headings: []string{"F. Brown"}}}},
+							},
+						},
+						{ID: 7, Headings: []string{"G. Brown"}, Details: []string{"b. 1852, London", "d. 1920, London"}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, siblingBar := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sibling_bar_%v", siblingBar), func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.SpouseSide = SpouseLeft
+			opts.SiblingBar = siblingBar
+			l := ch.Layout(opts)
+
+			parent, child := l.blurbs[5], l.blurbs[6]
+
+			var path []Point
+			for _, c := range l.Connectors() {
+				if slices.Contains(c.Children, child.ID) {
+					path = c.Points
+				}
+				for i := 1; i < len(c.Points); i++ {
+					p0, p1 := c.Points[i-1], c.Points[i]
+					for _, b := range l.blurbs {
+						if max(p0.X, p1.X) >= b.Left() && min(p0.X, p1.X) <= b.Right() &&
+							max(p0.Y, p1.Y) >= b.TopPos && min(p0.Y, p1.Y) <= b.Bottom() {
+							t.Errorf("connector segment %v to %v crosses blurb %d", p0, p1, b.ID)
+						}
+					}
+				}
+			}
+
+			if len(path) <= 2 {
+				t.Fatalf("got straight connector %v, wanted it to step around the blurb in its way", path)
+			}
+			if end := path[len(path)-1]; end.Y != parent.Bottom()+l.opts.LineGap || end.X < parent.Left() || end.X > parent.Right() {
+				t.Errorf("connector does not reach the parent, ends at %v", end)
+			}
+		})
+	}
+}
+
+func TestSpouseSideLeft(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{
+						{ID: 4, Headings: []string{"D. Brown"}},
+						{ID: 5, Headings: []string{"E. Brown"}},
+					},
+				},
+				{
+					Other: &DescendantPerson{ID: 3, Headings: []string{"C. White"}},
+					Children: []*DescendantPerson{
+						{ID: 6, Headings: []string{"F. Brown"}},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.SpouseSide = SpouseLeft
+	l := ch.Layout(opts)
+
+	// the second family is outermost, then the first family, then the person
+	order := []int{3, -3, 2, -2, 1}
+	for i := 1; i < len(order); i++ {
+		left, right := l.blurbs[order[i-1]], l.blurbs[order[i]]
+		if left.Right() >= right.Left() {
+			t.Errorf("blurb %d (right edge %d) is not to the left of blurb %d (left edge %d)", left.ID, left.Right(), right.ID, right.Left())
+		}
+	}
+
+	// each spouse is kept close to their relationship marker
+	for _, id := range []int{2, 3} {
+		sp, rel := l.blurbs[id], l.blurbs[-id]
+		if got, want := rel.Left()-sp.Right(), opts.Hspace; got != want {
+			t.Errorf("spouse %d: got gap of %d to marker, wanted %d", id, got, want)
+		}
+	}
+
+	// children remain beneath the marker of their family in the same left to right order
+	children := []int{6, 4, 5}
+	for i := 1; i < len(children); i++ {
+		if l.blurbs[children[i-1]].Right() >= l.blurbs[children[i]].Left() {
+			t.Errorf("child %d is not to the left of child %d", children[i-1], children[i])
+		}
+	}
+	for id, parent := range map[int]int{4: -2, 5: -2, 6: -3} {
+		if got := l.blurbs[id].Parent.ID; got != parent {
+			t.Errorf("child %d: got parent %d, wanted %d", id, got, parent)
+		}
+	}
+}
+
+func TestSpouseSideLeftKeepsMarkerWithPerson(t *testing.T) {
+	// with spouses on the left each person follows the relationship marker of their family, which
+	// the arranger would otherwise separate from them by the wider gap left between families
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{
+						{
+							ID:       3,
+							Headings: []string{"C. Brown"},
+							Families: []*DescendantFamily{
+								{
+									Other:    &DescendantPerson{ID: 4, Headings: []string{"D. White"}},
+									Children: []*DescendantPerson{{ID: 7, Headings: []string{"G. Brown"}}},
+								},
+							},
+						},
+						{
+							ID:       5,
+							Headings: []string{"E. Brown"},
+							Families: []*DescendantFamily{
+								{
+									Other: &DescendantPerson{ID: 6, Headings: []string{"F. Black"}},
+									Children: []*DescendantPerson{
+										{ID: 8, Headings: []string{"H. Brown"}},
+										{ID: 9, Headings: []string{"I. Brown"}},
+										{ID: 10, Headings: []string{"J. Brown"}},
+										{ID: 11, Headings: []string{"K. Brown"}},
+										{ID: 12, Headings: []string{"L. Brown"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.SpouseSide = SpouseLeft
+	l := ch.Layout(opts)
+
+	for _, couple := range [][2]int{{1, 2}, {3, 4}, {5, 6}} {
+		person, sp, rel := l.blurbs[couple[0]], l.blurbs[couple[1]], l.blurbs[-couple[1]]
+		if got := rel.Left() - sp.Right(); got != opts.Hspace {
+			t.Errorf("spouse %d: got gap of %d to marker, wanted %d", sp.ID, got, opts.Hspace)
+		}
+		if got := person.Left() - rel.Right(); got != opts.Hspace {
+			t.Errorf("person %d: got gap of %d to marker, wanted %d", person.ID, got, opts.Hspace)
+		}
+	}
+}
+
+func TestSpouseSideBelow(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"Beatrice Green"}},
+					Children: []*DescendantPerson{
+						{
+							ID:       4,
+							Headings: []string{"D. Brown"},
+							Families: []*DescendantFamily{
+								{
+									Other:    &DescendantPerson{ID: 5, Headings: []string{"E. White"}},
+									Children: []*DescendantPerson{{ID: 6, Headings: []string{"F. Brown"}}},
+								},
+							},
+						},
+						{ID: 7, Headings: []string{"G. Brown"}},
+					},
+				},
+			},
+		},
+	}
+
+	beside := ch.Layout(nil)
+
+	opts := DefaultLayoutOptions()
+	opts.SpouseSide = SpouseBelow
+	l := ch.Layout(opts)
+
+	if l.Width() >= beside.Width() {
+		t.Errorf("got width %d, wanted less than %d when spouses are placed beside", l.Width(), beside.Width())
+	}
+
+	// the marker and spouse are stacked in turn beneath the person, centred on them
+	for _, stack := range [][]int{{1, -2, 2}, {4, -5, 5}} {
+		for i := 1; i < len(stack); i++ {
+			upper, lower := l.blurbs[stack[i-1]], l.blurbs[stack[i]]
+			if lower.TopPos == l.blurbs[stack[0]].TopPos {
+				t.Errorf("blurb %d: got same top as blurb %d, wanted it stacked below", lower.ID, stack[0])
+			}
+			if got, want := lower.TopPos, upper.Bottom()+opts.LineGap; got != want {
+				t.Errorf("blurb %d: got top %d, wanted %d", lower.ID, got, want)
+			}
+			if diff := lower.X() - upper.X(); diff < -1 || diff > 1 {
+				t.Errorf("blurb %d: got centre %d, wanted %d", lower.ID, lower.X(), upper.X())
+			}
+		}
+	}
+
+	// children are placed below the whole stack and joined to its foot
+	for id, spouse := range map[int]int{4: 2, 7: 2, 6: 5} {
+		b := l.blurbs[id]
+		if b.TopPos <= l.blurbs[spouse].Bottom() {
+			t.Errorf("child %d: got top %d, wanted below spouse bottom %d", id, b.TopPos, l.blurbs[spouse].Bottom())
+		}
+		pts := l.parentConnectors[id][0].Points
+		if got, want := pts[len(pts)-1], (Point{X: b.Parent.X(), Y: l.blurbs[spouse].Bottom() + opts.LineGap}); got != want {
+			t.Errorf("child %d: got connector ending at %v, wanted %v", id, got, want)
+		}
+	}
+
+	// the children do not overlap
+	if l.blurbs[4].Right() >= l.blurbs[7].Left() {
+		t.Errorf("child 4 (right edge %d) overlaps child 7 (left edge %d)", l.blurbs[4].Right(), l.blurbs[7].Left())
+	}
+}
+
+func TestSpouseSideBelowFamilies(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{{ID: 4, Headings: []string{"D. Brown"}}},
+				},
+				{
+					Other: &DescendantPerson{ID: 3, Headings: []string{"C. White"}},
+					Children: []*DescendantPerson{
+						{ID: 5, Headings: []string{"E. Brown"}},
+						{ID: 6, Headings: []string{"F. Brown"}},
+						{ID: 7, Headings: []string{"G. Brown"}},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.SpouseSide = SpouseBelow
+	l := ch.Layout(opts)
+
+	left, right := l.blurbs[1].Left(), l.blurbs[1].Right()
+	for _, id := range []int{-2, 2, -3, 3} {
+		left, right = min(left, l.blurbs[id].Left()), max(right, l.blurbs[id].Right())
+	}
+	foot := l.blurbs[3].Bottom() + opts.LineGap
+	rowTop := l.rowTop(l.blurbs[4].Row)
+
+	// each family descends from its own point, a third and two thirds of the way across the stack,
+	// and joins its children at its own height, the first family highest
+	families := []struct {
+		marker   int
+		children []int
+		descentX Pixel
+		barY     Pixel
+	}{
+		{marker: -2, children: []int{4}, descentX: left + (right-left)/3, barY: rowTop - opts.LineGap - opts.ChildDrop - opts.FamilyDrop/2},
+		{marker: -3, children: []int{5, 6, 7}, descentX: left + (right-left)*2/3, barY: rowTop - opts.LineGap - opts.ChildDrop},
+	}
+	for _, f := range families {
+		for _, id := range f.children {
+			if got, want := l.blurbs[id].Parent.ID, f.marker; got != want {
+				t.Errorf("child %d: got parent %d, wanted %d", id, got, want)
+			}
+			pts := l.parentConnectors[id][0].Points
+			if got, want := pts[len(pts)-1], (Point{X: f.descentX, Y: foot}); got != want {
+				t.Errorf("child %d: got connector ending at %v, wanted %v", id, got, want)
+			}
+			if got, want := pts[len(pts)-2], (Point{X: f.descentX, Y: f.barY}); got != want {
+				t.Errorf("child %d: got connector leaving the foot at %v, wanted %v", id, got, want)
+			}
+		}
+	}
+	if families[0].descentX >= families[1].descentX {
+		t.Errorf("got descent points %d and %d, wanted them distinct and in family order", families[0].descentX, families[1].descentX)
+	}
+	if families[0].barY >= families[1].barY {
+		t.Errorf("got bars at %d and %d, wanted the first family's higher", families[0].barY, families[1].barY)
+	}
+
+	// a stack with only one family with children still descends from its middle
+	ch.Root.Families[0].Children = nil
+	l = ch.Layout(opts)
+	for _, id := range []int{5, 6, 7} {
+		pts := l.parentConnectors[id][0].Points
+		if got, want := pts[len(pts)-1].X, l.blurbs[-3].X(); got != want {
+			t.Errorf("single family child %d: got connector ending at x=%d, wanted %d", id, got, want)
+		}
+	}
+}
+
+func TestFamilyOrderText(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}}, Details: []string{"m. 1875"}},
+				{Other: &DescendantPerson{ID: 3, Headings: []string{"C. White"}}, Details: []string{"m. 1890"}},
+			},
+		},
+	}
+
+	ordinals := []string{"1st", "2nd", "3rd"}
+	testCases := []struct {
+		name   string
+		marker UnionMarker
+		fn     func(int, *DescendantFamily) string
+		want   map[int][]string // the heading and details of each relationship marker
+	}{
+		{
+			name: "default",
+			want: map[int][]string{-2: {"= (1)", "m. 1875"}, -3: {"= (2)", "m. 1890"}},
+		},
+		{
+			name: "ordinal",
+			fn:   func(n int, f *DescendantFamily) string { return ordinals[n-1] },
+			want: map[int][]string{-2: {"= 1st", "m. 1875"}, -3: {"= 2nd", "m. 1890"}},
+		},
+		{
+			name: "details",
+			fn:   func(n int, f *DescendantFamily) string { return f.Details[0] },
+			want: map[int][]string{-2: {"= m. 1875", "m. 1875"}, -3: {"= m. 1890", "m. 1890"}},
+		},
+		{
+			name: "empty",
+			fn:   func(n int, f *DescendantFamily) string { return "" },
+			want: map[int][]string{-2: {"=", "m. 1875"}, -3: {"=", "m. 1890"}},
+		},
+		{
+			name:   "shape",
+			marker: UnionDot,
+			fn:     func(n int, f *DescendantFamily) string { return ordinals[n-1] },
+			want:   map[int][]string{-2: {"", "1st", "m. 1875"}, -3: {"", "2nd", "m. 1890"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.UnionMarker = tc.marker
+			opts.FamilyOrderText = tc.fn
+			l := ch.Layout(opts)
+
+			got := make(map[int][]string)
+			for id := range tc.want {
+				b := l.blurbs[id]
+				got[id] = append(slices.Clone(b.HeadingTexts.Lines), b.DetailTexts.Lines...)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("marker text mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// hidden families are not counted
+	hidden := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}}},
+				{Other: &DescendantPerson{ID: 3, Headings: []string{"C. White"}}, Children: []*DescendantPerson{{ID: 4, Headings: []string{"D. Brown"}}}},
+				{Other: &DescendantPerson{ID: 5, Headings: []string{"E. Black"}}, Children: []*DescendantPerson{{ID: 6, Headings: []string{"F. Brown"}}}},
+			},
+		},
+	}
+	opts := DefaultLayoutOptions()
+	opts.HideChildlessFamilies = true
+	got := make(map[string]int) // maps the spouse of each family to the number it was given
+	opts.FamilyOrderText = func(n int, f *DescendantFamily) string {
+		got[f.Other.Headings[0]] = n
+		return ordinals[n-1]
+	}
+	hidden.Layout(opts)
+	if diff := cmp.Diff(map[string]int{"C. White": 1, "E. Black": 2}, got); diff != "" {
+		t.Errorf("family numbers mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestOmitChildlessMarker(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{{ID: 4, Headings: []string{"D. Brown"}}},
+				},
+				{
+					Other: &DescendantPerson{ID: 3, Headings: []string{"C. White"}},
+				},
+				{
+					Other:   &DescendantPerson{ID: 5, Headings: []string{"E. Black"}},
+					Details: []string{"m. 1870"},
+				},
+			},
+		},
+	}
+
+	l := ch.Layout(nil)
+	if _, ok := l.blurbs[-3]; !ok {
+		t.Fatalf("missing marker for childless family without the option")
+	}
+
+	for _, side := range []SpouseSide{SpouseRight, SpouseLeft, SpouseBelow} {
+		opts := DefaultLayoutOptions()
+		opts.OmitChildlessMarker = true
+		opts.SpouseSide = side
+		l := ch.Layout(opts)
+
+		if _, ok := l.blurbs[-3]; ok {
+			t.Errorf("side %d: got marker for childless family", side)
+		}
+		if _, ok := l.blurbs[-2]; !ok {
+			t.Errorf("side %d: missing marker for family with children", side)
+		}
+		if _, ok := l.blurbs[3]; !ok {
+			t.Fatalf("side %d: missing spouse of childless family", side)
+		}
+		// a childless family with details keeps its marker so the details are shown
+		if m, ok := l.blurbs[-5]; !ok {
+			t.Errorf("side %d: missing marker for childless family with details", side)
+		} else if !slices.Contains(m.DetailTexts.Lines, "m. 1870") {
+			t.Errorf("side %d: got marker details %q, wanted them to include the family details", side, m.DetailTexts.Lines)
+		}
+
+		// the couple are joined by a line that lies between them
+		person, spouse := l.blurbs[1], l.blurbs[3]
+		var joined bool
+		for _, c := range l.Connectors() {
+			if len(c.Children) > 0 {
+				continue
+			}
+			joined = true
+			p0, p1 := c.Points[0], c.Points[len(c.Points)-1]
+			if side == SpouseBelow {
+				if p0.Y <= person.Bottom() || p1.Y >= spouse.TopPos || p1.Y <= p0.Y {
+					t.Errorf("side %d: got line from %v to %v, wanted between %d and %d", side, p0, p1, person.Bottom(), spouse.TopPos)
+				}
+				continue
+			}
+			left, right := person, spouse
+			if side == SpouseLeft {
+				left, right = spouse, person
+			}
+			if p0.X <= left.Right() || p1.X >= right.Left() || p1.X <= p0.X {
+				t.Errorf("side %d: got line from %v to %v, wanted between %d and %d", side, p0, p1, left.Right(), right.Left())
+			}
+		}
+		if !joined {
+			t.Errorf("side %d: no line joins the childless couple", side)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	in := lines(
+		"1. A. Brown",
+		"  sp. B. Green",
+		"   2. C. Brown",
+		"     sp. D. White",
+		"       3. E. Brown #emigrant",
+		"       3. F. Brown",
+		"   2. G. Brown",
+		"     sp. H. Black",
+		"       3. I. Brown",
+		"  sp. J. Grey",
+		"   2. K. Brown #emigrant",
+		"     sp. L. Jones",
+		"   2. M. Brown",
+	)
+	p := &Parser{}
+	ch, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	before := fmt.Sprint(describeTree(ch.Root))
+
+	pruned := ch.Prune(func(p *DescendantPerson) bool { return slices.Contains(p.Tags, "emigrant") })
+
+	want := []string{
+		"A. Brown",
+		"  = B. Green",
+		"    C. Brown",
+		"      = D. White",
+		"        E. Brown",
+		"  = J. Grey",
+		"    K. Brown",
+	}
+	if diff := cmp.Diff(want, describeTree(pruned.Root)); diff != "" {
+		t.Errorf("pruned chart mismatch (-want +got):\n%s", diff)
+	}
+
+	if after := fmt.Sprint(describeTree(ch.Root)); after != before {
+		t.Errorf("original chart was modified")
+	}
+
+	if none := ch.Prune(func(p *DescendantPerson) bool { return false }); none.Root != nil {
+		t.Errorf("got root %v, wanted none when no one is kept", none.Root.Headings)
+	}
+}
+
+// describeTree returns a line for each person and spouse in the tree beneath p, indented by generation.
+func describeTree(p *DescendantPerson) []string {
+	var out []string
+	var walk func(p *DescendantPerson, indent string)
+	walk = func(p *DescendantPerson, indent string) {
+		out = append(out, indent+strings.Join(p.Headings, " "))
+		for _, f := range p.Families {
+			if f.Other != nil {
+				out = append(out, indent+"  = "+strings.Join(f.Other.Headings, " "))
+			}
+			for _, c := range f.Children {
+				walk(c, indent+"    ")
+			}
+		}
+	}
+	walk(p, "")
+	return out
+}
+
+func TestGenerationStats(t *testing.T) {
+	in := lines(
+		"1. A. Brown (b. 1820, d. 1880)",
+		"  sp. B. Green",
+		"   2. C. Brown (b. 1845, d. 1901)",
+		"     sp. D. White",
+		"       3. E. Brown",
+		"       3. F. Brown",
+		"     sp. J. Grey",
+		"   2. G. Brown (b. 1848)",
+		"     sp. H. Black",
+		"       3. I. Brown",
+		"   2. K. Brown",
+	)
+	ch, err := new(Parser).Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []GenStat{
+		{Generation: 1, People: 1, Deceased: 1, Families: 1, Children: 3},
+		{Generation: 2, People: 3, Deceased: 1, Families: 3, Children: 3},
+		{Generation: 3, People: 3},
+	}
+	got := ch.GenerationStats()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("stats mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := got[1].ChildrenPerFamily(), 1.0; got != want {
+		t.Errorf("got %v children per family, wanted %v", got, want)
+	}
+	if got := got[2].ChildrenPerFamily(); got != 0 {
+		t.Errorf("got %v children per family for a generation without families, wanted 0", got)
+	}
+
+	if got := new(DescendantChart).GenerationStats(); len(got) != 0 {
+		t.Errorf("got %d generations for an empty chart, wanted none", len(got))
+	}
+}
+
+func TestFitToAspect(t *testing.T) {
+	in := lines(
+		"1. A. Brown (b. 1820, d. 1880)",
+		"  sp. B. Green (b. 1822, d. 1890)",
+		"   2. C. Brown (b. 1845, d. 1901; carpenter)",
+		"     sp. D. White (b. 1848)",
+		"       3. E. Brown (b. 1870)",
+		"       3. F. Brown (b. 1872)",
+		"       3. G. Brown (b. 1875; emigrated to Canada)",
+		"   2. H. Brown (b. 1848)",
+		"     sp. I. Black (b. 1850)",
+		"       3. J. Brown (b. 1877)",
+		"       3. K. Brown (b. 1879)",
+		"   2. L. Brown (b. 1851; d. 1852)",
+	)
+	ch, err := new(Parser).Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, ratio := range []float64{ASeriesPortrait, ASeriesLandscape, 3, 0.25} {
+		t.Run(fmt.Sprintf("%.2f", ratio), func(t *testing.T) {
+			opts := FitToAspect(ch, ratio)
+			l := ch.Layout(opts)
+			got := float64(l.Width()) / float64(l.Height())
+			if math.Abs(got-ratio)/ratio > 0.02 {
+				t.Errorf("got aspect ratio %.3f (%dx%d), wanted %.3f", got, l.Width(), l.Height(), ratio)
+			}
+			if l.Overflow() {
+				t.Errorf("layout overflowed its fixed width")
+			}
+		})
+	}
+
+	if diff := cmp.Diff(DefaultLayoutOptions(), FitToAspect(ch, 0)); diff != "" {
+		t.Errorf("options for zero ratio mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConnectorColors(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"C. Brown"}},
+						{ID: 4, Headings: []string{"D. Brown"}},
+						{ID: 5, Headings: []string{"E. Brown"}},
+					},
+				},
+			},
+		},
+	}
+	palette := []string{"#c00", "#0a0"}
+	want := map[int]string{3: "#c00", 4: "#0a0", 5: "#c00"}
+
+	for _, siblingBar := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sibling_bar_%v", siblingBar), func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.SiblingBar = siblingBar
+			opts.ConnectorColors = palette
+			l := ch.Layout(opts)
+
+			for id, color := range want {
+				// the connector meeting the child is first in its path
+				if got := l.parentConnectors[id][0].Color; got != color {
+					t.Errorf("connector to blurb %d: got color %q, wanted %q", id, got, color)
+				}
+			}
+			for _, c := range l.Connectors() {
+				if len(c.Children) > 1 && c.Color != "" {
+					t.Errorf("connector shared by %v: got color %q, wanted none", c.Children, c.Color)
+				}
+			}
+
+			s, err := SVG(l)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, color := range palette {
+				if !strings.Contains(s, "stroke:"+color+";") {
+					t.Errorf("no connector drawn in %s", color)
+				}
+			}
+		})
+	}
+
+	// without a palette every connector is drawn in the default color
+	for _, c := range ch.Layout(nil).Connectors() {
+		if c.Color != "" {
+			t.Errorf("got connector color %q, wanted none", c.Color)
+		}
+	}
+}
+
+func TestSubtreeBounds(t *testing.T) {
+	in := lines(
+		"1. A. Brown",
+		"  sp. B. Green",
+		"   2. C. Brown",
+		"     sp. D. White",
+		"       3. E. Brown",
+		"       3. F. Brown",
+		"   2. G. Brown",
+		"       3. H. Brown",
+	)
+	p := &Parser{}
+	ch, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	l := ch.Layout(nil)
+
+	inside := func(b *Blurb, tl, br Point) bool {
+		return b.Left() >= tl.X && b.Right() <= br.X && b.TopPos >= tl.Y && b.Bottom() <= br.Y
+	}
+
+	// the whole chart descends from the root person
+	tl, br, ok := l.SubtreeBounds(1)
+	if !ok {
+		t.Fatalf("root person not found")
+	}
+	for _, b := range l.Blurbs() {
+		if !inside(b, tl, br) {
+			t.Errorf("blurb %d is outside the bounds of the root person", b.ID)
+		}
+	}
+
+	// the subtree of C. Brown covers their spouse, marker and children but not their siblings
+	tl, br, ok = l.SubtreeBounds(3)
+	if !ok {
+		t.Fatalf("person 3 not found")
+	}
+	for _, id := range []int{3, 4, -4, 5, 6} {
+		if !inside(l.blurbs[id], tl, br) {
+			t.Errorf("blurb %d is outside the bounds of person 3", id)
+		}
+	}
+	for _, id := range []int{7, 8} {
+		if inside(l.blurbs[id], tl, br) {
+			t.Errorf("blurb %d is inside the bounds of person 3", id)
+		}
+	}
+	if got, want := tl.Y, l.blurbs[3].TopPos; got != want {
+		t.Errorf("got top %d, wanted %d", got, want)
+	}
+
+	// a person without descendants is bounded by their own blurb
+	tl, br, _ = l.SubtreeBounds(8)
+	if b := l.blurbs[8]; tl != (Point{X: b.Left(), Y: b.TopPos}) || br != (Point{X: b.Right(), Y: b.Bottom()}) {
+		t.Errorf("got bounds %v to %v, wanted the blurb itself", tl, br)
+	}
+
+	if _, _, ok := l.SubtreeBounds(99); ok {
+		t.Errorf("got bounds for unknown person")
+	}
+}
+
+func TestInfantDeathMarker(t *testing.T) {
+	in := lines(
+		"1. A. Brown (b: 1830. d: 1890; age: 60.)",
+		"  2. B. Brown (b: 24 Apr 1858. d: 1859; age: 0.)",
+		"  2. C. Brown (b: 1860)",
+	)
+	p := &Parser{}
+	ch, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.InfantDeathMarker = "†"
+	l := ch.Layout(opts)
+
+	want := map[int]string{1: "A. Brown", 2: "B. Brown †", 3: "C. Brown"}
+	for id, heading := range want {
+		if got := l.blurbs[id].HeadingTexts.Lines[0]; got != heading {
+			t.Errorf("blurb %d: got heading %q, wanted %q", id, got, heading)
+		}
+	}
+
+	s, err := SVG(l)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(s, ">B. Brown †</tspan>") {
+		t.Errorf("marker not drawn after name")
+	}
+
+	// without the option the name is unchanged
+	if got := ch.Layout(nil).blurbs[2].HeadingTexts.Lines[0]; got != "B. Brown" {
+		t.Errorf("got heading %q without the option, wanted %q", got, "B. Brown")
+	}
+	// with the surname on a line of its own the marker follows the surname
+	p = &Parser{SurnameSeparateLine: true}
+	ch, err = p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	got := ch.Layout(opts).blurbs[2].HeadingTexts.Lines
+	if diff := cmp.Diff([]string{"B.", "Brown †"}, got); diff != "" {
+		t.Errorf("headings with surname line mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSexWidth(t *testing.T) {
+	person := func(sex Sex, details ...string) *DescendantChart {
+		return &DescendantChart{Root: &DescendantPerson{ID: 1, Headings: []string{"A. Brown"}, Details: details, Sex: sex}}
+	}
+	width := func(ch *DescendantChart) Pixel { return ch.Layout(nil).blurbs[1].Width }
+
+	// a known sex reserves space for a symbol after the name
+	unknown := width(person(UnknownSex))
+	for _, sex := range []Sex{Male, Female} {
+		b := person(sex).Layout(nil).blurbs[1]
+		if b.Width <= unknown {
+			t.Errorf("sex %v: got width %d, wanted wider than %d", sex, b.Width, unknown)
+		}
+		if want := b.SexSymbolOffset() + b.HeadingTexts.Style.FontSize; b.Width != want {
+			t.Errorf("sex %v: got width %d, wanted %d", sex, b.Width, want)
+		}
+	}
+
+	// no width is added when a detail line already leaves room for the symbol
+	long := "b. 24 May 1819, St Mary le Bow, London, England"
+	if got, want := width(person(Male, long)), width(person(UnknownSex, long)); got != want {
+		t.Errorf("got width %d with a long detail, wanted %d", got, want)
+	}
+}
+
+func TestMultipleBirth(t *testing.T) {
+	in := lines(
+		"1. A. Brown",
+		"  sp. B. Green",
+		"   2. C. Brown #twin:A",
+		"   2. D. Brown",
+		"   2. E. Brown #twin:A",
+	)
+	p := &Parser{}
+	ch, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	children := ch.Root.Families[0].Children
+	if got := children[2].MultipleBirth; got != "A" || len(children[2].Tags) != 0 {
+		t.Fatalf("got multiple birth %q and tags %v, wanted %q and no tags", got, children[2].Tags, "A")
+	}
+
+	for _, siblingBar := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sibling_bar_%v", siblingBar), func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.SiblingBar = siblingBar
+			l := ch.Layout(opts)
+			twin1, twin2, single := l.blurbs[3], l.blurbs[5], l.blurbs[4]
+
+			// the twins are side by side, before their sibling
+			if got, want := twin2.Left()-twin1.Right(), opts.ChildSpacing; got != want {
+				t.Errorf("got gap of %d between twins, wanted %d", got, want)
+			}
+			if twin2.Right() >= single.Left() {
+				t.Errorf("got twin at %d, wanted it before the sibling at %d", twin2.Right(), single.Left())
+			}
+
+			// the connectors from the twins meet at a single point
+			junction := func(b *Blurb) Point { return l.parentConnectors[b.ID][0].Points[1] }
+			if junction(twin1) != junction(twin2) {
+				t.Errorf("got twin junctions %v and %v, wanted the same point", junction(twin1), junction(twin2))
+			}
+			if want := (twin1.TopHookX() + twin2.TopHookX()) / 2; junction(twin1).X != want {
+				t.Errorf("got junction at %d, wanted midway between the twins at %d", junction(twin1).X, want)
+			}
+			if got, want := junction(single).X, single.TopHookX(); got != want {
+				t.Errorf("got single birth junction at %d, wanted directly above the child at %d", got, want)
+			}
+		})
+	}
+}
+
+func TestSnapConnectors(t *testing.T) {
+	// the marker is centred on the child, whose top hook lies a few pixels to its right
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Children: []*DescendantPerson{{ID: 3, Headings: []string{"Cy Lo"}}},
+				},
+			},
+		},
+	}
+
+	for _, bar := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sibling_bar_%v", bar), func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.SiblingBar = bar
+
+			l := ch.Layout(opts)
+			child := l.blurbs[3]
+			if x := child.Parent.X(); x == child.TopHookX() {
+				t.Fatalf("marker is already above the top hook, the test chart needs changing")
+			}
+			if cs := l.parentConnectors[3]; len(cs) == 1 && len(cs[0].Points) == 2 {
+				t.Errorf("got a straight connector without snapping, wanted one that jogs to the marker")
+			}
+
+			opts.SnapConnectors = true
+			l = ch.Layout(opts)
+			child = l.blurbs[3]
+			cs := l.parentConnectors[3]
+			if len(cs) != 1 {
+				t.Fatalf("got %d connectors, wanted a single straight line", len(cs))
+			}
+			want := []Point{
+				{X: child.TopHookX(), Y: child.TopPos - l.opts.LineGap},
+				{X: child.TopHookX(), Y: l.stackBottom(child.Parent) + l.opts.LineGap},
+			}
+			if diff := cmp.Diff(want, cs[0].Points); diff != "" {
+				t.Errorf("connector points mismatch (-want +got):\n%s", diff)
+			}
+			if x := child.TopHookX(); x < child.Parent.Left() || x > child.Parent.Right() {
+				t.Errorf("connector at x %d misses the marker spanning %d to %d", x, child.Parent.Left(), child.Parent.Right())
+			}
+		})
+	}
+}
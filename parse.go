@@ -3,12 +3,17 @@ package gtree
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/language"
 )
 
 var reLine = regexp.MustCompile(`^(\s*)(\d+|sp|\+)(?:\.)?\s*(.+)$`)
@@ -20,26 +25,50 @@ var reLine = regexp.MustCompile(`^(\s*)(\d+|sp|\+)(?:\.)?\s*(.+)$`)
 // (for spouse disambiguation) but trailing is not. Lines consisting only of whitespace
 // are ignored.
 //
+// The list may be preceded by directive lines that describe the chart as a whole. A line
+// beginning with 'title:' gives the title of the chart and a line beginning with 'note:' adds
+// a note to the chart. Several title lines are joined with a space. A line beginning with
+// 'option:' sets a layout option, such as 'option: wrapwidth=200' or 'option: spouse-side=left',
+// in the Options of the chart. Option names ignore case, hyphens and underscores. Directives are
+// only recognised before the first person entry.
+//
 // The prefix of each entry denotes the relationship of the person to an earlier person.
 // A prefix text may be a generation number followed by a dot (.) which indicates
 // the position of the person in the family tree relative to the root ancestor. A
 // generation number of 1 indicates the root ancestor, 2 indicates their children, and
 // so on.
 //
-// Alternatively the prefix may be the two characters 'sp' or the single
-// character '+' which indicates that the person is the spouse of the preceding
-// numbered person with equal or lesser indentation.
+// Alternatively the prefix may be the two characters 'sp' which indicates that the person
+// is the spouse of the preceding numbered person with equal or lesser indentation, or the
+// single character '+' which indicates that the person is the spouse of the preceding
+// numbered person. Programs that use '+' often indent every spouse line by the same amount
+// regardless of generation, so the indentation of a '+' line is ignored and the spouse
+// belongs to the generation of the last numbered person.
 //
 // The entry text may wrap onto subsequent lines until a line with a generation number or spouse prefix is
-// encountered.
+// encountered. A wrapped line that would otherwise be mistaken for a new entry, such as one beginning
+// with a number, may be marked as a continuation by starting it with the continuation prefix, which
+// is three dots '...' by default. The prefix is removed from the text.
+//
+// Hand typed lists sometimes omit the generation number of a child and rely on indentation
+// alone. If the ImpliedChildren field is true then a line without a prefix that is indented
+// further than the most recent person is taken to be a new entry for a child of that person,
+// rather than wrapped text. This is ambiguous since wrapped text is often indented too, so in
+// this mode the entry text of any person may only wrap onto lines with less indentation or onto
+// lines marked with the continuation prefix.
 //
 // The text after the prefix is the person's name followed by optional tags and detail text
 // used for additional information such as birth, death, marriage, and other life events.
 //
 // If the SurnameSeparateLine field is true then the name will be parsed to detect
 // a surname, which will be placed on a seperate heading line. If the name ends in
-// one or more words delimted by slashes '/' then these will be used as the surname,
-// otherwise the surname will be taken to be the last whole word after a space.
+// one or more words delimted by slashes '/' then these will be used as the surname.
+// Otherwise, if the name contains a comma it is assumed to be in the form "Surname, Given"
+// and the text before the comma will be used as the surname, otherwise the surname will
+// be taken to be the last whole word after a space. The surname is always placed on the
+// line after the given names. If the
+// UppercaseSurname field is also true then the surname is converted to upper case using the
+// casing rules of the language given by the Language field.
 //
 // All text up to the first tag delimiter or detail delimiter is to be the name of the person.
 //
@@ -47,24 +76,79 @@ var reLine = regexp.MustCompile(`^(\s*)(\d+|sp|\+)(?:\.)?\s*(.+)$`)
 // Any tags must be occur between the name and the detail text delimiter.
 //
 // Detail text is delimited by parantheses '(' and ')'. All text between the parantheses is
-// assumed to be the detail text.
+// assumed to be the detail text. Further parenthetical groups that immediately follow the first,
+// such as "John Smith (b. 1850) (occupation: farmer)", are added as additional lines of detail text.
 //
-// Any text after the closing detail paranthesis is ignored.
+// Any text after the closing detail paranthesis is ignored unless the KeepTrailingDetail field
+// is true, in which case it is added as a final line of detail text with any leading comma or
+// semicolon removed. This preserves information such as the marriage details that some programs
+// write after the details of a spouse.
 //
 // The name and the detail text are trimmed to remove leading and trailing whitespace. Outer
 // matching parantheses are removed from the detail text before trimming.
 //
+// A detail parenthesis that is never closed is taken to run to the end of the line. The opening
+// parenthesis is removed and any parantheses within the detail text are kept as written, so
+// "A. Brown (1819-1901 (carpenter)" has the detail text "1819-1901 (carpenter)".
+//
 // Any semicolons ';' within the detail text are treated as line breaks, resulting in
-// multiple lines of text.
+// multiple lines of text. A different separator may be specified using the DetailSeparator
+// field. The escape sequence '\n' is also treated as a line break, as is a line break within
+// detail text that is written over several lines, such as "(b. 1850" followed by "d. 1900)".
+// A line marked with the continuation prefix, and any line outside the detail text, is joined
+// to the line before with a space so the wrapping does not itself produce a line break.
+//
+// Identifiers are assigned using the position of the person's entry in the list. An
+// explicit identifier may be given instead by including a tag of the form '#id:123'.
+// Explicit identifiers must be positive integers and the tag is not included in the
+// person's tags. It is an error for two people to have the same explicit identifier.
+// A person without one whose position has already been given explicitly to someone else
+// takes the next number not already used. People in a family group are placed in the order the lines are
+// read from the input.
 //
-// Identifiers are assigned using the line number of the person's entry. People in a family
-// group are placed in the order the lines are read from the input.
+// Children born at the same birth, such as twins, may be marked with a tag of the form
+// '#twin:A', where children of the same family with the same label belong to the same birth.
+// The tag is not included in the person's tags.
 type Parser struct {
-	SurnameSeparateLine bool // if true the parser puts the surname on a second header line
+	SurnameSeparateLine bool              // if true the parser puts the surname on a second header line
+	UppercaseSurname    bool              // if true, and SurnameSeparateLine is true, the surname line is converted to upper case
+	Language            language.Tag      // the language used for case conversion, such as language.Turkish, defaults to language.Und
+	ContinuationPrefix  string            // the prefix that marks a line as a continuation of the previous entry, DefaultContinuationPrefix is used if empty
+	DetailSeparator     string            // the text that separates lines within the detail text, DefaultDetailSeparator is used if empty
+	IDFunc              func() int        // generates the id of each person without an id tag, ids must be positive, sequential ids starting at 1 are used if nil
+	KeepTrailingDetail  bool              // if true any text after the closing detail parenthesis is kept as an additional detail line
+	MaxLineBytes        int               // the maximum length of a line of input, bufio.MaxScanTokenSize is used if zero
+	Encoding            encoding.Encoding // the character encoding of the input, such as charmap.Windows1252, which is transcoded to UTF-8 before parsing, the input is assumed to be UTF-8 if nil
+	ImpliedChildren     bool              // if true a line without a prefix that is indented further than the most recent person is taken to be a child of that person rather than wrapped text
+	DerivedDetail       DerivedDetailMode // how detail lines holding values derived from other details, such as "age: 55.", are handled, they are kept as separate lines by default
 }
 
+// DerivedDetailMode is the way a parser handles detail lines holding values that are derived from
+// other details, such as the age at death that some programs write after the death details.
+type DerivedDetailMode int
+
+const (
+	DerivedDetailKeep  DerivedDetailMode = iota // DerivedDetailKeep keeps a derived value as a detail line of its own.
+	DerivedDetailDrop                           // DerivedDetailDrop removes any detail line holding a derived value.
+	DerivedDetailMerge                          // DerivedDetailMerge appends a derived value to the end of the preceding detail line, such as "d: 1861 (age 55)".
+)
+
+// DefaultDetailSeparator is the text used to separate lines within the detail text when the parser does
+// not specify a separator.
+const DefaultDetailSeparator = ";"
+
+// DefaultContinuationPrefix is the prefix used to mark a line as a continuation of the previous entry
+// when the parser does not specify one.
+const DefaultContinuationPrefix = "..."
+
 func (p *Parser) Parse(ctx context.Context, r io.Reader) (*DescendantChart, error) {
+	if p.Encoding != nil {
+		r = p.Encoding.NewDecoder().Reader(r)
+	}
 	s := bufio.NewScanner(r)
+	if p.MaxLineBytes > 0 {
+		s.Buffer(make([]byte, 0, min(p.MaxLineBytes, bufio.MaxScanTokenSize)), p.MaxLineBytes)
+	}
 	lineno := 0
 
 	type entry struct {
@@ -72,44 +156,82 @@ func (p *Parser) Parse(ctx context.Context, r io.Reader) (*DescendantChart, erro
 		indent     int
 		generation int
 		isSpouse   bool
+		plus       bool // the spouse was marked with '+', whose indentation is not relied on
 		text       string
 		person     *DescendantPerson
 	}
 
 	entries := []*entry{}
 
+	continuation := p.ContinuationPrefix
+	if continuation == "" {
+		continuation = DefaultContinuationPrefix
+	}
+
+	var titles, notes []string
+	var opts *LayoutOptions
 	var cur *entry
+	var people []*entry // the most recent person of each generation, used to find the parent of an implied child
 	for s.Scan() {
 		lineno++
 		line := strings.TrimRightFunc(s.Text(), unicode.IsSpace)
 		if len(line) == 0 {
 			continue
 		}
+
+		if cur == nil {
+			if name, value, ok := parseDirective(line); ok {
+				switch name {
+				case "title":
+					titles = append(titles, value)
+				case "note":
+					notes = append(notes, value)
+				case "option":
+					if opts == nil {
+						opts = DefaultLayoutOptions()
+					}
+					if err := setLayoutOption(opts, value); err != nil {
+						return nil, fmt.Errorf("line %d: %w", lineno, err)
+					}
+				}
+				continue
+			}
+		}
+
+		// an explicit continuation is never treated as the start of a new entry
+		if text, found := strings.CutPrefix(strings.TrimLeftFunc(line, unicode.IsSpace), continuation); found {
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: continuation encountered before first person", lineno)
+			}
+			cur.text += " " + strings.TrimSpace(text)
+			continue
+		}
+
 		matches := reLine.FindStringSubmatch(line)
 		if len(matches) == 4 {
 			// start a new entry
-			headings, details, tags := p.parseDetails(ctx, strings.TrimSpace(matches[3]))
-
 			cur = &entry{
 				lineno: lineno,
 				indent: len(matches[1]),
 				text:   strings.TrimSpace(matches[3]),
-				person: &DescendantPerson{
-					ID:       len(entries) + 1,
-					Headings: headings,
-					Details:  details,
-					Tags:     tags,
-				},
 			}
 
 			if matches[2] == "sp" || matches[2] == "+" {
 				cur.isSpouse = true
+				cur.plus = matches[2] == "+"
 			} else {
 				gen, err := strconv.Atoi(matches[2])
 				if err != nil {
 					return nil, fmt.Errorf("line %d: malformed generation number: %w", lineno, err)
 				}
 				cur.generation = gen
+				if p.ImpliedChildren {
+					// forget any people of the same or later generations
+					for len(people) > 0 && people[len(people)-1].generation >= gen {
+						people = people[:len(people)-1]
+					}
+					people = append(people, cur)
+				}
 			}
 
 			entries = append(entries, cur)
@@ -117,14 +239,98 @@ func (p *Parser) Parse(ctx context.Context, r io.Reader) (*DescendantChart, erro
 			if cur == nil {
 				return nil, fmt.Errorf("line %d: malformed entry", lineno)
 			}
-			cur.text += " " + strings.TrimSpace(line)
+			if p.ImpliedChildren {
+				// the parent is the most recent person with less indentation
+				indent := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+				pi := len(people) - 1
+				for pi >= 0 && people[pi].indent >= indent {
+					pi--
+				}
+				if pi >= 0 {
+					cur = &entry{
+						lineno:     lineno,
+						indent:     indent,
+						generation: people[pi].generation + 1,
+						text:       strings.TrimSpace(line),
+					}
+					people = append(people[:pi+1], cur)
+					entries = append(entries, cur)
+					continue
+				}
+			}
+			cur.text = p.continueText(cur.text, strings.TrimSpace(line))
+		}
+	}
+	if err := s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("line %d: %w, the limit may be raised using MaxLineBytes", lineno+1, err)
+		}
+		return nil, err
+	}
+
+	// parse the complete text of each entry, including any continuation lines, collecting any
+	// explicit ids first so that the ids assigned to other entries can avoid them
+	ids := map[int]int{} // maps id to line number where it was first used
+	for _, e := range entries {
+		headings, details, tags := p.parseDetails(ctx, e.text)
+		infant := parseInfantDeath(details)
+		details = p.derivedDetails(details)
+
+		id, tags, err := p.parseID(tags)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", e.lineno, err)
+		}
+		if id != 0 {
+			if prevLineno, exists := ids[id]; exists {
+				return nil, fmt.Errorf("line %d: duplicate id %d, previously used on line %d", e.lineno, id, prevLineno)
+			}
+			ids[id] = e.lineno
+		}
+		birth, tags := parseMultipleBirth(tags)
+
+		e.person = &DescendantPerson{
+			ID:            id,
+			Headings:      headings,
+			Details:       details,
+			Tags:          tags,
+			LifeStatus:    parseLifeStatus(details),
+			DiedInInfancy: infant,
+			MultipleBirth: birth,
 		}
 	}
-	if s.Err() != nil {
-		return nil, s.Err()
+
+	for i, e := range entries {
+		if e.person.ID != 0 {
+			continue
+		}
+		id := i + 1
+		if p.IDFunc != nil {
+			id = p.IDFunc()
+			if id <= 0 {
+				return nil, fmt.Errorf("line %d: generated id %d must be a positive integer", e.lineno, id)
+			}
+			if prevLineno, exists := ids[id]; exists {
+				return nil, fmt.Errorf("line %d: duplicate id %d, previously used on line %d", e.lineno, id, prevLineno)
+			}
+		} else {
+			// the position of the entry is used unless it has been taken, in which case the next
+			// number not yet used is
+			for {
+				if _, exists := ids[id]; !exists {
+					break
+				}
+				id++
+			}
+		}
+		ids[id] = e.lineno
+		e.person.ID = id
 	}
 
-	lin := new(DescendantChart)
+	lin := &DescendantChart{
+		Title:   strings.Join(titles, " "),
+		Notes:   notes,
+		Options: opts,
+	}
 
 	ppl := []*entry{}
 	for _, e := range entries {
@@ -142,7 +348,9 @@ func (p *Parser) Parse(ctx context.Context, r io.Reader) (*DescendantChart, erro
 		} else {
 			prev := ppl[len(ppl)-1]
 			if e.isSpouse {
-				for e.indent < prev.indent && len(ppl) > 0 {
+				// a spouse marked with '+' belongs to the last numbered person, whatever its
+				// indentation, since some programs indent every such line by the same amount
+				for !e.plus && e.indent < prev.indent && len(ppl) > 0 {
 					ppl = ppl[:len(ppl)-1]
 					if len(ppl) == 0 {
 						return nil, fmt.Errorf("line %d: invalid person indent", e.lineno)
@@ -178,7 +386,8 @@ func (p *Parser) Parse(ctx context.Context, r io.Reader) (*DescendantChart, erro
 					// child is new current person entry
 					ppl = append(ppl, e)
 				} else {
-					return nil, fmt.Errorf("line %d: expected person with generation number %d, got %d", e.lineno, e.generation+1, e.generation)
+					// a person may return to any earlier generation but may only descend one at a time
+					return nil, fmt.Errorf("line %d: expected person with generation number of at most %d following generation %d, got %d", e.lineno, prev.generation+1, prev.generation, e.generation)
 				}
 			}
 		}
@@ -187,6 +396,253 @@ func (p *Parser) Parse(ctx context.Context, r io.Reader) (*DescendantChart, erro
 	return lin, nil
 }
 
+// parseID looks for an explicit identifier tag of the form 'id:123' and returns the identifier
+// and the remaining tags. The returned identifier is zero if no identifier tag was found.
+func (p *Parser) parseID(tags []string) (int, []string, error) {
+	id := 0
+	var remaining []string
+	for _, tag := range tags {
+		idtext, found := strings.CutPrefix(tag, "id:")
+		if !found {
+			remaining = append(remaining, tag)
+			continue
+		}
+		if id != 0 {
+			return 0, nil, fmt.Errorf("multiple id tags")
+		}
+		v, err := strconv.Atoi(idtext)
+		if err != nil || v <= 0 {
+			return 0, nil, fmt.Errorf("malformed id tag %q: must be a positive integer", tag)
+		}
+		id = v
+	}
+	return id, remaining, nil
+}
+
+// parseMultipleBirth returns the label of any twin tag, such as "#twin:A", along with the
+// remaining tags.
+func parseMultipleBirth(tags []string) (string, []string) {
+	birth := ""
+	var remaining []string
+	for _, tag := range tags {
+		label, found := strings.CutPrefix(tag, "twin:")
+		if !found || label == "" {
+			remaining = append(remaining, tag)
+			continue
+		}
+		birth = label
+	}
+	return birth, remaining
+}
+
+// deathRe matches a death event within detail text, such as "d. 1901" or "d: Deceased", capturing
+// the text of the event up to the end of the sentence or line.
+var deathRe = regexp.MustCompile(`(?i)(?:^|\s)d[.:]\s*([^.;]*)`)
+
+// derivedRe matches a detail line holding a derived value, such as "age: 55.", capturing the
+// label and the value. The value must be an age, a number optionally qualified such as "abt. 55",
+// so that lines such as "Aged care resident" are not mistaken for one.
+var derivedRe = regexp.MustCompile(`(?i)^(aged?)\s*[:.]?\s*((?:abt\.?|about|c\.|[<>])?\s*\d.*?)\.?$`)
+
+// derivedDetails removes the detail lines holding derived values or merges them with the
+// preceding line according to the DerivedDetail field.
+func (p *Parser) derivedDetails(details []string) []string {
+	if p.DerivedDetail == DerivedDetailKeep {
+		return details
+	}
+	kept := make([]string, 0, len(details))
+	for _, d := range details {
+		m := derivedRe.FindStringSubmatch(d)
+		if m == nil {
+			kept = append(kept, d)
+			continue
+		}
+		if p.DerivedDetail == DerivedDetailMerge {
+			if len(kept) == 0 {
+				// nothing to merge with
+				kept = append(kept, d)
+				continue
+			}
+			kept[len(kept)-1] += " (" + strings.ToLower(m[1]) + " " + m[2] + ")"
+		}
+	}
+	return kept
+}
+
+// parseInfantDeath reports whether the details of a person give their age at death as 0, such as
+// "age: 0.", showing that they died in infancy.
+func parseInfantDeath(details []string) bool {
+	for _, d := range details {
+		if m := derivedRe.FindStringSubmatch(d); m != nil && m[2] == "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDirective reports whether the line is a chart directive such as "title: The Browns" and
+// returns the lower case name of the directive and its trimmed value.
+func parseDirective(line string) (string, string, bool) {
+	name, value, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found {
+		return "", "", false
+	}
+	name = strings.ToLower(name)
+	switch name {
+	case "title", "note", "option":
+		return name, strings.TrimSpace(value), true
+	}
+	return "", "", false
+}
+
+// layoutOptionSetters maps the normalised name of each layout option that may be set by an option
+// directive to a function that parses its value and sets it.
+var layoutOptionSetters = map[string]func(o *LayoutOptions, v string) error{
+	"wrapwidth":             pixelOption(func(o *LayoutOptions) *Pixel { return &o.DetailWrapWidth }),
+	"detailwrapwidth":       pixelOption(func(o *LayoutOptions) *Pixel { return &o.DetailWrapWidth }),
+	"headingwrapwidth":      pixelOption(func(o *LayoutOptions) *Pixel { return &o.HeadingWrapWidth }),
+	"titlewrapwidth":        pixelOption(func(o *LayoutOptions) *Pixel { return &o.TitleWrapWidth }),
+	"hspace":                pixelOption(func(o *LayoutOptions) *Pixel { return &o.Hspace }),
+	"childspacing":          pixelOption(func(o *LayoutOptions) *Pixel { return &o.ChildSpacing }),
+	"familygap":             pixelOption(func(o *LayoutOptions) *Pixel { return &o.FamilyGap }),
+	"rowgap":                pixelOption(func(o *LayoutOptions) *Pixel { return &o.RowGap }),
+	"margin":                pixelOption(func(o *LayoutOptions) *Pixel { return &o.Margin }),
+	"linewidth":             pixelOption(func(o *LayoutOptions) *Pixel { return &o.LineWidth }),
+	"cornerradius":          pixelOption(func(o *LayoutOptions) *Pixel { return &o.CornerRadius }),
+	"fixedwidth":            pixelOption(func(o *LayoutOptions) *Pixel { return &o.FixedWidth }),
+	"detailcolumns":         intOption(func(o *LayoutOptions) *int { return &o.DetailColumns }),
+	"mingeneration":         intOption(func(o *LayoutOptions) *int { return &o.MinGeneration }),
+	"maxgeneration":         intOption(func(o *LayoutOptions) *int { return &o.MaxGeneration }),
+	"focus":                 intOption(func(o *LayoutOptions) *int { return &o.FocusID }),
+	"keepemptydetails":      boolOption(func(o *LayoutOptions) *bool { return &o.KeepEmptyDetails }),
+	"stackspouses":          boolOption(func(o *LayoutOptions) *bool { return &o.StackSpouses }),
+	"siblingbar":            boolOption(func(o *LayoutOptions) *bool { return &o.SiblingBar }),
+	"centremarkers":         boolOption(func(o *LayoutOptions) *bool { return &o.CentreMarkers }),
+	"uniformrowheight":      boolOption(func(o *LayoutOptions) *bool { return &o.UniformRowHeight }),
+	"dnamarkers":            boolOption(func(o *LayoutOptions) *bool { return &o.DNAMarkers }),
+	"hidechildlessfamilies": boolOption(func(o *LayoutOptions) *bool { return &o.HideChildlessFamilies }),
+	"generationlabels":      boolOption(func(o *LayoutOptions) *bool { return &o.ShowGenerationLabels }),
+	"fontscale": func(o *LayoutOptions, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			return fmt.Errorf("not a positive number")
+		}
+		o.FontScale = f
+		return nil
+	},
+	"watermark": func(o *LayoutOptions, v string) error {
+		o.Watermark = v
+		return nil
+	},
+	"spouseside": func(o *LayoutOptions, v string) error {
+		switch strings.ToLower(v) {
+		case "left":
+			o.SpouseSide = SpouseLeft
+		case "right":
+			o.SpouseSide = SpouseRight
+		case "below":
+			o.SpouseSide = SpouseBelow
+		default:
+			return fmt.Errorf("must be left, right or below")
+		}
+		return nil
+	},
+	"detailalign": func(o *LayoutOptions, v string) error {
+		switch strings.ToLower(v) {
+		case "left":
+			o.DetailAlign = AlignLeft
+		case "centre", "center":
+			o.DetailAlign = AlignCentre
+		case "right":
+			o.DetailAlign = AlignRight
+		default:
+			return fmt.Errorf("must be left, centre or right")
+		}
+		return nil
+	},
+}
+
+func pixelOption(field func(o *LayoutOptions) *Pixel) func(o *LayoutOptions, v string) error {
+	return func(o *LayoutOptions, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("not a whole number of pixels")
+		}
+		*field(o) = Pixel(n)
+		return nil
+	}
+}
+
+func intOption(field func(o *LayoutOptions) *int) func(o *LayoutOptions, v string) error {
+	return func(o *LayoutOptions, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("not a whole number")
+		}
+		*field(o) = n
+		return nil
+	}
+}
+
+func boolOption(field func(o *LayoutOptions) *bool) func(o *LayoutOptions, v string) error {
+	return func(o *LayoutOptions, v string) error {
+		switch strings.ToLower(v) {
+		case "true", "yes", "on", "1":
+			*field(o) = true
+		case "false", "no", "off", "0":
+			*field(o) = false
+		default:
+			return fmt.Errorf("must be true or false")
+		}
+		return nil
+	}
+}
+
+// setLayoutOption sets the layout option described by the value of an option directive, such as
+// "wrapwidth=200".
+func setLayoutOption(o *LayoutOptions, directive string) error {
+	name, value, found := strings.Cut(directive, "=")
+	name = strings.TrimSpace(name)
+	if !found {
+		return fmt.Errorf("option %q has no value, expected name=value", name)
+	}
+	key := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name))
+	set, ok := layoutOptionSetters[key]
+	if !ok {
+		return fmt.Errorf("unknown option %q", name)
+	}
+	if err := set(o, strings.TrimSpace(value)); err != nil {
+		return fmt.Errorf("option %q: invalid value %q: %w", name, strings.TrimSpace(value), err)
+	}
+	return nil
+}
+
+// parseLifeStatus returns whether a person is living or deceased according to their details. A
+// detail of "Living" or "Deceased", or a death event such as "d. 1901", "d: Deceased" or the
+// placeholder "d. -", is recognised.
+func parseLifeStatus(details []string) LifeStatus {
+	for _, d := range details {
+		switch strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d), ".")) {
+		case "living", "still living":
+			return LifeStatusLiving
+		case "deceased":
+			return LifeStatusDeceased
+		}
+
+		if m := deathRe.FindStringSubmatch(d); m != nil {
+			switch v := strings.ToLower(strings.TrimSpace(m[1])); v {
+			case "living", "still living":
+				return LifeStatusLiving
+			case "":
+				// the abbreviation alone is not enough to be sure this is a death
+			default:
+				return LifeStatusDeceased
+			}
+		}
+	}
+	return LifeStatusUnknown
+}
+
 // parseDetails parses a person's details from a line
 func (p *Parser) parseDetails(ctx context.Context, s string) ([]string, []string, []string) {
 	maybeSplitName := func(name string) []string {
@@ -195,18 +651,34 @@ func (p *Parser) parseDetails(ctx context.Context, s string) ([]string, []string
 			return []string{name}
 		}
 
+		surname := func(s string) string {
+			s = strings.TrimSpace(s)
+			if !p.UppercaseSurname {
+				return s
+			}
+			return cases.Upper(p.Language).String(s)
+		}
+
 		if strings.HasSuffix(name, "/") {
 			sl := strings.IndexByte(name, '/')
 			if sl != -1 {
-				return []string{strings.TrimSpace(name[:sl]), name[sl+1 : len(name)-1]}
+				return []string{strings.TrimSpace(name[:sl]), surname(name[sl+1 : len(name)-1])}
+			}
+		}
+
+		if sn, given, found := strings.Cut(name, ","); found {
+			sn, given = strings.TrimSpace(sn), strings.TrimSpace(given)
+			if given == "" {
+				return []string{surname(sn)}
 			}
+			return []string{given, surname(sn)}
 		}
 
 		sp := strings.LastIndexByte(name, ' ')
 		if sp == -1 {
 			return []string{name}
 		}
-		return []string{strings.TrimSpace(name[:sp]), name[sp:]}
+		return []string{strings.TrimSpace(name[:sp]), surname(name[sp+1:])}
 	}
 
 	cleanLines := func(name, detail string) ([]string, []string) {
@@ -220,11 +692,22 @@ func (p *Parser) parseDetails(ctx context.Context, s string) ([]string, []string
 			name = name[:br]
 		}
 
-		if strings.HasPrefix(detail, "(") && strings.HasSuffix(detail, ")") {
-			detail = detail[1 : len(detail)-1]
+		if strings.HasPrefix(detail, "(") {
+			if cl := closingParen(detail, 1); cl == -1 {
+				// an unclosed parenthesis runs to the end of the line
+				detail = detail[1:]
+			} else if cl == len(detail)-1 {
+				detail = detail[1:cl]
+			}
+		}
+
+		sep := p.DetailSeparator
+		if sep == "" {
+			sep = DefaultDetailSeparator
 		}
+		detail = strings.NewReplacer(`\n`, sep, "\n", sep).Replace(detail)
 
-		lines := strings.Split(detail, ";")
+		lines := strings.Split(detail, sep)
 		for i := range lines {
 			lines[i] = strings.TrimSpace(lines[i])
 		}
@@ -237,13 +720,47 @@ func (p *Parser) parseDetails(ctx context.Context, s string) ([]string, []string
 		return maybeSplitName(name), lines
 	}
 
+	// moreGroups appends the lines of any further parenthetical groups that immediately follow the
+	// detail text and returns the text remaining after them
+	moreGroups := func(details []string, trailing string) ([]string, string) {
+		for {
+			t := strings.TrimSpace(trailing)
+			if !strings.HasPrefix(t, "(") {
+				return details, trailing
+			}
+			group, rest := t, ""
+			if cl := closingParen(t, 1); cl != -1 {
+				group, rest = t[:cl+1], t[cl+1:]
+			}
+			_, lines := cleanLines("", group)
+			details, trailing = append(details, lines...), rest
+		}
+	}
+
+	// keepTrailing appends any text that follows the detail text as a final detail line
+	keepTrailing := func(details []string, trailing string) []string {
+		if !p.KeepTrailingDetail {
+			return details
+		}
+		trailing = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(trailing), ",;"))
+		if trailing == "" {
+			return details
+		}
+		return append(details, trailing)
+	}
+
 	var nametext, detailtext string
 	var headings, details, tags []string
 
 	s = strings.TrimSpace(s)
 	if strings.HasPrefix(s, "(") {
+		var trailing string
+		if cl := closingParen(s, 1); cl != -1 {
+			s, trailing = s[:cl+1], s[cl+1:]
+		}
 		headings, details = cleanLines("", s)
-		return headings, details, tags
+		details, trailing = moreGroups(details, trailing)
+		return headings, keepTrailing(details, trailing), tags
 	}
 
 	pos := 0
@@ -268,26 +785,17 @@ func (p *Parser) parseDetails(ctx context.Context, s string) ([]string, []string
 			if nametext == "" {
 				nametext = s[:pos-1]
 			}
-			open := 1
-			cl := pos + 1
-			for ; cl < len(s); cl++ {
-				if strings.HasPrefix(s[cl:], "(") {
-					open++
-					continue
-				}
-				if strings.HasPrefix(s[cl:], ")") {
-					open--
-					if open == 0 {
-						break
-					}
-				}
-			}
-
-			if open == 0 {
+			var trailing string
+			if cl := closingParen(s, pos+1); cl != -1 {
 				detailtext = s[pos+1 : cl]
+				trailing = s[cl+1:]
+			} else {
+				// an unclosed parenthesis runs to the end of the line
+				detailtext = s[pos:]
 			}
 			headings, details = cleanLines(nametext, detailtext)
-			return headings, details, tags
+			details, trailing = moreGroups(details, trailing)
+			return headings, keepTrailing(details, trailing), tags
 		}
 
 		sp = strings.IndexByte(s[pos:], ' ')
@@ -300,3 +808,45 @@ func (p *Parser) parseDetails(ctx context.Context, s string) ([]string, []string
 	headings, details = cleanLines(nametext, "")
 	return headings, details, tags
 }
+
+// closingParen returns the index of the parenthesis in s that closes one opened just before
+// start, or -1 if it is not closed.
+// continueText appends a line that an entry has wrapped onto to the text of the entry. The line
+// break is kept when it falls within a parenthesised detail group that is still open, so that it
+// separates detail lines, unless the text already ends with a detail separator. Otherwise the line
+// is joined with a space.
+func (p *Parser) continueText(text, line string) string {
+	open := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			open++
+		case ')':
+			open = max(0, open-1)
+		}
+	}
+	sep := p.DetailSeparator
+	if sep == "" {
+		sep = DefaultDetailSeparator
+	}
+	if open > 0 && !strings.HasSuffix(strings.TrimSpace(text), sep) {
+		return text + "\n" + line
+	}
+	return text + " " + line
+}
+
+func closingParen(s string, start int) int {
+	open := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			open++
+		case ')':
+			open--
+			if open == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -117,7 +117,13 @@ func main() {
 		return
 	}
 
-	svg, err := gtree.SVG(chart.Layout(gtree.DefaultLayoutOptions()))
+	// use any options given by option directives in the input
+	opts := chart.Options
+	if opts == nil {
+		opts = gtree.DefaultLayoutOptions()
+	}
+
+	svg, err := gtree.SVG(chart.Layout(opts))
 	if err != nil {
 		fmt.Println("Error generating SVG:", err)
 		return
@@ -1,11 +1,15 @@
 package gtree
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/language"
 )
 
 func lines(elems ...string) string { return strings.Join(elems, "\n") }
@@ -39,6 +43,7 @@ var testCases = []struct {
 					"b. 24 May 1819, London, England.",
 					"d. 22 Jan 1901, Isle of Wight, England.",
 				},
+				LifeStatus: LifeStatusDeceased,
 			},
 		},
 	},
@@ -108,7 +113,9 @@ var testCases = []struct {
 				Headings: []string{
 					"A. Brown",
 				},
-				Details: []string{},
+				Details: []string{
+					"1819-1901 carpenter",
+				},
 			},
 		},
 	},
@@ -121,7 +128,9 @@ var testCases = []struct {
 				Headings: []string{
 					"A. Brown",
 				},
-				Details: []string{},
+				Details: []string{
+					"1819-1901 (carpenter)",
+				},
 			},
 		},
 	},
@@ -578,6 +587,7 @@ var testCases = []struct {
 					string("b: Abt. 1806 in Kilford, Ireland. d: 17 Sep 1861 in Swindon, Wiltshire, England"),
 					string("age: 55."),
 				},
+				LifeStatus: LifeStatusDeceased,
 				Families: []*DescendantFamily{
 					{
 						Other: &DescendantPerson{
@@ -589,7 +599,8 @@ var testCases = []struct {
 								string("b: Abt. 1800 in Limerick, Ireland. d: 12 Oct 1896 in Trowbridge, Wiltshire, England"),
 								string("age: 96."),
 							},
-							Families: []*DescendantFamily(nil),
+							LifeStatus: LifeStatusDeceased,
+							Families:   []*DescendantFamily(nil),
 						},
 						Details: []string(nil),
 						Children: []*DescendantPerson{
@@ -602,6 +613,7 @@ var testCases = []struct {
 									string("b: 7 Dec 1838 in Chippenham, Wiltshire, England. d: Bef. 1928 in Swindon, Wiltshire, England"),
 									string("age: 89."),
 								},
+								LifeStatus: LifeStatusDeceased,
 								Families: []*DescendantFamily{
 									{
 										Other: &DescendantPerson{
@@ -613,7 +625,8 @@ var testCases = []struct {
 												string("b: abt 1835 in Ireland. m: 28 Jun 1857 in Swindon, Wiltshire, England. d: Mar 1883 in Swindon, Wiltshire, England"),
 												string("age: 48."),
 											},
-											Families: []*DescendantFamily(nil),
+											LifeStatus: LifeStatusDeceased,
+											Families:   []*DescendantFamily(nil),
 										},
 										Details: []string(nil),
 										Children: []*DescendantPerson{
@@ -626,7 +639,9 @@ var testCases = []struct {
 													string("b: 24 Apr 1858. d: 1859"),
 													string("age: 0."),
 												},
-												Families: []*DescendantFamily(nil),
+												LifeStatus:    LifeStatusDeceased,
+												DiedInInfancy: true,
+												Families:      []*DescendantFamily(nil),
 											},
 											{
 												ID: int(6),
@@ -636,7 +651,8 @@ var testCases = []struct {
 												Details: []string{
 													string("b: abt 1860 in Trowbridge, Wiltshire, England. d: Deceased."),
 												},
-												Families: []*DescendantFamily(nil),
+												LifeStatus: LifeStatusDeceased,
+												Families:   []*DescendantFamily(nil),
 											},
 										},
 									},
@@ -650,7 +666,8 @@ var testCases = []struct {
 								Details: []string{
 									string("b: 25 Apr 1840 in Swindon, Wiltshire, England. d: Deceased."),
 								},
-								Families: []*DescendantFamily(nil),
+								LifeStatus: LifeStatusDeceased,
+								Families:   []*DescendantFamily(nil),
 							},
 							{
 								ID: int(8),
@@ -661,6 +678,7 @@ var testCases = []struct {
 									string("b: 22 May 1842 in Chippenham, Wiltshire, England. d: 1 Oct 1898 in Bath, Somerset, England"),
 									string("age: 56."),
 								},
+								LifeStatus: LifeStatusDeceased,
 								Families: []*DescendantFamily{
 									{
 										Other: &DescendantPerson{
@@ -672,7 +690,8 @@ var testCases = []struct {
 												string("b: Abt. 1839 in Limerick, Ireland. m: 13 Nov 1864 in Swindon, Wiltshire, England. d: 11 May 1867 in St. Luke’s Infirmary, Bath, Somerset, England"),
 												string("age: 28."),
 											},
-											Families: []*DescendantFamily(nil),
+											LifeStatus: LifeStatusDeceased,
+											Families:   []*DescendantFamily(nil),
 										},
 										Details: []string(nil),
 										Children: []*DescendantPerson{
@@ -684,6 +703,7 @@ var testCases = []struct {
 												Details: []string{
 													string("b: 3 Nov 1865 in Trowbridge, Wiltshire, England. d: Deceased."),
 												},
+												LifeStatus: LifeStatusDeceased,
 												Families: []*DescendantFamily{
 													{
 														Other: &DescendantPerson{
@@ -695,7 +715,8 @@ var testCases = []struct {
 																string("b: 1 Nov 1843 in Bristol, Gloucestershire, England. m: 7 Dec 1867 in Swindon, Wiltshire, England. d: Bef. 1871 in Trowbridge, Wiltshire, England"),
 																string("age: 27."),
 															},
-															Families: []*DescendantFamily(nil),
+															LifeStatus: LifeStatusDeceased,
+															Families:   []*DescendantFamily(nil),
 														},
 														Details:  []string(nil),
 														Children: []*DescendantPerson(nil),
@@ -711,6 +732,7 @@ var testCases = []struct {
 													"b: 15 Oct 1868 in Swindon, Wiltshire, England. d: 8 Aug 1956 in Wiltshire, England",
 													"age: 87.",
 												},
+												LifeStatus: LifeStatusDeceased,
 												Families: []*DescendantFamily{
 													{
 														Other: &DescendantPerson{
@@ -722,7 +744,8 @@ var testCases = []struct {
 																string("b: 25 Feb 1864 in Norton, Somerset, England. m: 4 Sep 1888 in Swindon, Wiltshire, England. d: 28 Feb 1955 in Chippenham, Wiltshire, England"),
 																string("age: 91."),
 															},
-															Families: []*DescendantFamily(nil),
+															LifeStatus: LifeStatusDeceased,
+															Families:   []*DescendantFamily(nil),
 														},
 														Details:  []string(nil),
 														Children: []*DescendantPerson(nil),
@@ -737,7 +760,8 @@ var testCases = []struct {
 																string("b: 1840 in Bristol, Gloucestershire, England. m: 28 Oct 1872 in Swindon, Wiltshire, England. d: 15 July 1880 in Trowbridge, Wiltshire, England"),
 																string("age: 40."),
 															},
-															Families: []*DescendantFamily(nil),
+															LifeStatus: LifeStatusDeceased,
+															Families:   []*DescendantFamily(nil),
 														},
 														Details:  []string(nil),
 														Children: []*DescendantPerson(nil),
@@ -757,6 +781,7 @@ var testCases = []struct {
 									string("b: 15 Feb 1844 in Chippenham, Wiltshire, England. d: Oct 1916 in Swindon, Wiltshire, England"),
 									string("age: 72."),
 								},
+								LifeStatus: LifeStatusDeceased,
 								Families: []*DescendantFamily{
 									{
 										Other: &DescendantPerson{
@@ -768,7 +793,8 @@ var testCases = []struct {
 												string("b: abt 1846 in Fleur-de-Lys, Monmouthshire, Wales. m: 17 Sep 1873 in St. Luke's Church, Swindon, Wiltshire, England. d: Jul 1923 in Swindon, Wiltshire, England"),
 												string("age: 77."),
 											},
-											Families: []*DescendantFamily(nil),
+											LifeStatus: LifeStatusDeceased,
+											Families:   []*DescendantFamily(nil),
 										},
 										Details: []string(nil),
 										Children: []*DescendantPerson{
@@ -780,7 +806,8 @@ var testCases = []struct {
 												Details: []string{
 													string("b: abt 1875 in Swindon, Wiltshire, England. d: Deceased."),
 												},
-												Families: []*DescendantFamily(nil),
+												LifeStatus: LifeStatusDeceased,
+												Families:   []*DescendantFamily(nil),
 											},
 											{
 												ID: int(18),
@@ -790,7 +817,8 @@ var testCases = []struct {
 												Details: []string{
 													string("b: 1880 in Swindon, Wiltshire, England. d: Deceased."),
 												},
-												Families: []*DescendantFamily(nil),
+												LifeStatus: LifeStatusDeceased,
+												Families:   []*DescendantFamily(nil),
 											},
 										},
 									},
@@ -805,7 +833,9 @@ var testCases = []struct {
 									string("b: 30 Mar 1849 in Chippenham, Wiltshire, England. d: 6 Apr 1849 in Chippenham, Wiltshire, England"),
 									string("age: 0."),
 								},
-								Families: []*DescendantFamily(nil),
+								LifeStatus:    LifeStatusDeceased,
+								DiedInInfancy: true,
+								Families:      []*DescendantFamily(nil),
 							},
 							{
 								ID: int(20),
@@ -816,6 +846,7 @@ var testCases = []struct {
 									string("b: 2 Nov 1851 in Trowbridge, Wiltshire, England. d: Jun 1936 in Swindon, Wiltshire, England"),
 									string("age: 84."),
 								},
+								LifeStatus: LifeStatusDeceased,
 								Families: []*DescendantFamily{
 									{
 										Other: &DescendantPerson{
@@ -827,7 +858,8 @@ var testCases = []struct {
 												string("b: abt 1854 in Trowbridge, Wiltshire, England. m: 2 Dec 1872 in Christchurch, Wiltshire, England. d: 25 Jul 1935 in Swindon, Wiltshire, England"),
 												string("age: 81."),
 											},
-											Families: []*DescendantFamily(nil),
+											LifeStatus: LifeStatusDeceased,
+											Families:   []*DescendantFamily(nil),
 										},
 										Details: []string(nil),
 										Children: []*DescendantPerson{
@@ -840,6 +872,7 @@ var testCases = []struct {
 													string("b: abt 1874 in Trowbridge, Wiltshire, England. d: Dec 1948 in Chippenham, Wiltshire, England"),
 													string("age: 74."),
 												},
+												LifeStatus: LifeStatusDeceased,
 												Families: []*DescendantFamily{
 													{
 														Other: &DescendantPerson{
@@ -850,7 +883,8 @@ var testCases = []struct {
 															Details: []string{
 																string("b: abt 1875 in Nk, Wiltshire, England. m: Jul 1902 in Wiltshire, England. d: Deceased."),
 															},
-															Families: []*DescendantFamily(nil),
+															LifeStatus: LifeStatusDeceased,
+															Families:   []*DescendantFamily(nil),
 														},
 														Details:  []string(nil),
 														Children: []*DescendantPerson(nil),
@@ -866,7 +900,8 @@ var testCases = []struct {
 													string("b: abt 1882 in Devizes, Wiltshire, England. d: Abt 1961 in Salisbury, Wiltshire, England"),
 													string("age: 79."),
 												},
-												Families: []*DescendantFamily(nil),
+												LifeStatus: LifeStatusDeceased,
+												Families:   []*DescendantFamily(nil),
 											},
 										},
 									},
@@ -941,6 +976,77 @@ var testCases = []struct {
 			},
 		},
 	},
+	{
+		name: "explicit_id",
+		in: lines(
+			"1. A. Brown #id:100 #tag (1819-1901)",
+			"   sp. B. Green (1819-1861)",
+		),
+		want: &DescendantChart{
+			Root: &DescendantPerson{
+				ID: 100,
+				Headings: []string{
+					"A. Brown",
+				},
+				Details: []string{
+					"1819-1901",
+				},
+				Tags: []string{"tag"},
+				Families: []*DescendantFamily{
+					{
+						Other: &DescendantPerson{
+							ID: 2,
+							Headings: []string{
+								"B. Green",
+							},
+							Details: []string{
+								"1819-1861",
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		name: "wrapped_detail",
+		in: lines(
+			"1. A. Brown (b. 24 May 1819;",
+			"   d. 22 Jan 1901)",
+		),
+		want: &DescendantChart{
+			Root: &DescendantPerson{
+				ID: 1,
+				Headings: []string{
+					"A. Brown",
+				},
+				Details: []string{
+					"b. 24 May 1819",
+					"d. 22 Jan 1901",
+				},
+				LifeStatus: LifeStatusDeceased,
+			},
+		},
+	},
+	{
+		name: "continuation_marker",
+		in: lines(
+			"1. A. Brown (b. 1850; died in 1918.",
+			"   ... 2 children survived)",
+		),
+		want: &DescendantChart{
+			Root: &DescendantPerson{
+				ID: 1,
+				Headings: []string{
+					"A. Brown",
+				},
+				Details: []string{
+					"b. 1850",
+					"died in 1918. 2 children survived",
+				},
+			},
+		},
+	},
 }
 
 func TestParse(t *testing.T) {
@@ -958,3 +1064,953 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseErrors(t *testing.T) {
+	errorCases := []struct {
+		name string
+		in   string
+	}{
+		{
+			name: "duplicate_explicit_id",
+			in: lines(
+				"1. A. Brown #id:7",
+				"   2. C. Brown #id:7",
+			),
+		},
+		{
+			name: "continuation_before_first_person",
+			in: lines(
+				"... A. Brown",
+				"1. A. Brown",
+			),
+		},
+		{
+			name: "malformed_explicit_id",
+			in:   "1. A. Brown #id:abc",
+		},
+		{
+			name: "skipped_generation",
+			in: lines(
+				"1. A. Brown",
+				"   2. C. Brown",
+				"      4. D. Brown",
+			),
+		},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			p := new(Parser)
+			_, err := p.Parse(ctx, strings.NewReader(tc.in))
+			if err == nil {
+				t.Fatalf("got no error, wanted one")
+			}
+		})
+	}
+}
+
+func TestParseGenerationJumps(t *testing.T) {
+	in := lines(
+		"1. A. Brown",
+		"   2. B. Brown",
+		"      3. C. Brown",
+		"         4. D. Brown",
+		"   2. E. Brown",
+		"      3. F. Brown",
+		"         4. G. Brown",
+		"      3. H. Brown",
+	)
+
+	ch, err := new(Parser).Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"A. Brown",
+		"  B. Brown",
+		"    C. Brown",
+		"      D. Brown",
+		"  E. Brown",
+		"    F. Brown",
+		"      G. Brown",
+		"    H. Brown",
+	}
+	var got []string
+	var walk func(p *DescendantPerson, depth int)
+	walk = func(p *DescendantPerson, depth int) {
+		got = append(got, strings.Repeat("  ", depth)+strings.Join(p.Headings, " "))
+		for _, f := range p.Families {
+			for _, c := range f.Children {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(ch.Root, 0)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("tree mismatch (-want +got):\n%s", diff)
+	}
+
+	// descending more than one generation at a time is an error
+	in = lines(
+		"1. A. Brown",
+		"   2. B. Brown",
+		"      3. C. Brown",
+		"   2. E. Brown",
+		"         4. G. Brown",
+	)
+	_, err = new(Parser).Parse(context.Background(), strings.NewReader(in))
+	if err == nil {
+		t.Fatalf("got no error, wanted one")
+	}
+	if got, want := err.Error(), "line 5: expected person with generation number of at most 3 following generation 2, got 4"; got != want {
+		t.Errorf("got error %q, wanted %q", got, want)
+	}
+}
+
+func TestParseUppercaseSurname(t *testing.T) {
+	testCases := []struct {
+		name string
+		lang language.Tag
+		in   string
+		want []string
+	}{
+		{
+			name: "ascii",
+			in:   "1. John Smith",
+			want: []string{"John", "SMITH"},
+		},
+		{
+			name: "umlaut",
+			in:   "1. Anna Müller",
+			want: []string{"Anna", "MÜLLER"},
+		},
+		{
+			name: "sharp_s",
+			in:   "1. Johann Strauß",
+			want: []string{"Johann", "STRAUSS"},
+		},
+		{
+			name: "accented",
+			in:   "1. Hélène /de Léséleuc/",
+			want: []string{"Hélène", "DE LÉSÉLEUC"},
+		},
+		{
+			name: "surname_first",
+			in:   "1. Strauß, Johann",
+			want: []string{"Johann", "STRAUSS"},
+		},
+		{
+			name: "dotted_i_undetermined",
+			in:   "1. Ayşe Çelik",
+			want: []string{"Ayşe", "ÇELIK"},
+		},
+		{
+			name: "dotted_i_turkish",
+			lang: language.Turkish,
+			in:   "1. Ayşe Çelik",
+			want: []string{"Ayşe", "ÇELİK"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{
+				SurnameSeparateLine: true,
+				UppercaseSurname:    true,
+				Language:            tc.lang,
+			}
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Root.Headings); diff != "" {
+				t.Errorf("headings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseDetailSeparator(t *testing.T) {
+	testCases := []struct {
+		name string
+		sep  string
+		in   string
+		want []string
+	}{
+		{
+			name: "pipe",
+			sep:  "|",
+			in:   "1. A. Brown (b. 1850 | d. 1900)",
+			want: []string{"b. 1850", "d. 1900"},
+		},
+		{
+			name: "pipe_ignores_semicolon",
+			sep:  "|",
+			in:   "1. A. Brown (b. 1850; London | d. 1900)",
+			want: []string{"b. 1850; London", "d. 1900"},
+		},
+		{
+			name: "escaped_newline",
+			in:   `1. A. Brown (b. 1850\nd. 1900; carpenter)`,
+			want: []string{"b. 1850", "d. 1900", "carpenter"},
+		},
+		{
+			name: "escaped_newline_with_pipe",
+			sep:  "|",
+			in:   `1. A. Brown (b. 1850\nd. 1900 | carpenter)`,
+			want: []string{"b. 1850", "d. 1900", "carpenter"},
+		},
+		{
+			name: "multi_line_detail",
+			in:   lines("1. A. Brown (b. 1850", "   d. 1900", "   carpenter)", "   2. C. Brown"),
+			want: []string{"b. 1850", "d. 1900", "carpenter"},
+		},
+		{
+			name: "multi_line_detail_with_pipe",
+			sep:  "|",
+			in:   lines("1. A. Brown (b. 1850 |", "   d. 1900", "   carpenter)"),
+			want: []string{"b. 1850", "d. 1900", "carpenter"},
+		},
+		{
+			name: "wrapped_name",
+			in:   lines("1. A. Brown", "   Smith (b. 1850", "   d. 1900)"),
+			want: []string{"b. 1850", "d. 1900"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{DetailSeparator: tc.sep}
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Root.Details); diff != "" {
+				t.Errorf("details mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSurnameSeparateLine(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "given_surname",
+			in:   "1. Edward Bennett (b. 1843)",
+			want: []string{"Edward", "Bennett"},
+		},
+		{
+			name: "given_surname_extra_space",
+			in:   "1. John  Smith",
+			want: []string{"John", "Smith"},
+		},
+		{
+			name: "slashes",
+			in:   "1. Edward /Bennett Jones/ (b. 1843)",
+			want: []string{"Edward", "Bennett Jones"},
+		},
+		{
+			name: "slashes_with_spaces",
+			in:   "1. John / Smith / (b. 1843)",
+			want: []string{"John", "Smith"},
+		},
+		{
+			name: "surname_comma_given",
+			in:   "1. Bennett, Edward (b. 1843)",
+			want: []string{"Edward", "Bennett"},
+		},
+		{
+			name: "surname_comma_multiple_given",
+			in:   "1. Hudson, Charles Edward (b. 1906)",
+			want: []string{"Charles Edward", "Hudson"},
+		},
+		{
+			name: "surname_comma_only",
+			in:   "1. Bennett, (b. 1843)",
+			want: []string{"Bennett"},
+		},
+		{
+			name: "single_name",
+			in:   "1. Edward (b. 1843)",
+			want: []string{"Edward"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{SurnameSeparateLine: true}
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Root.Headings); diff != "" {
+				t.Errorf("headings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseExplicitIDs(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want []int
+	}{
+		{
+			name: "explicit_id_of_later_entry",
+			in:   "1. A #id:2\n  2. B",
+			want: []int{2, 3},
+		},
+		{
+			name: "explicit_id_of_earlier_entry",
+			in:   "1. A. Brown\n   2. C. Brown #id:1\n   2. D. Brown",
+			want: []int{2, 1, 3},
+		},
+		{
+			name: "no_explicit_ids",
+			in:   "1. A. Brown\n   2. C. Brown\n   2. D. Brown",
+			want: []int{1, 2, 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := new(Parser)
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ids := []int{got.Root.ID}
+			for _, c := range got.Root.Families[0].Children {
+				ids = append(ids, c.ID)
+			}
+			if diff := cmp.Diff(tc.want, ids); diff != "" {
+				t.Errorf("ids mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseIDFunc(t *testing.T) {
+	in := `1. A. Brown
+sp. B. Smith
+  2. C. Brown #id:5
+  2. D. Brown`
+
+	next := 1000
+	p := &Parser{
+		IDFunc: func() int {
+			next++
+			return next
+		},
+	}
+	got, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ids := []int{got.Root.ID, got.Root.Families[0].Other.ID}
+	for _, c := range got.Root.Families[0].Children {
+		ids = append(ids, c.ID)
+	}
+	if diff := cmp.Diff([]int{1001, 1002, 5, 1003}, ids); diff != "" {
+		t.Errorf("ids mismatch (-want +got):\n%s", diff)
+	}
+
+	p = &Parser{IDFunc: func() int { return 0 }}
+	if _, err := p.Parse(context.Background(), strings.NewReader(in)); err == nil {
+		t.Errorf("got no error for non-positive generated id")
+	}
+}
+
+func TestParseKeepTrailingDetail(t *testing.T) {
+	in := lines(
+		"1. Murphy, Fiona (b. 1842)",
+		"  sp. Murphy, Sean (b. estimated 1839 - Limerick, Ireland, d. 1867-05-11), m. 1864-11-11 - St. Andrew's, Swansea",
+		"  sp. (b. 1843), m. 1867-12-07",
+	)
+
+	testCases := []struct {
+		name string
+		keep bool
+		want [][]string
+	}{
+		{
+			name: "ignored",
+			want: [][]string{
+				{"b. estimated 1839 - Limerick, Ireland, d. 1867-05-11"},
+				{"b. 1843"},
+			},
+		},
+		{
+			name: "kept",
+			keep: true,
+			want: [][]string{
+				{"b. estimated 1839 - Limerick, Ireland, d. 1867-05-11", "m. 1864-11-11 - St. Andrew's, Swansea"},
+				{"b. 1843", "m. 1867-12-07"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{KeepTrailingDetail: tc.keep}
+			got, err := p.Parse(context.Background(), strings.NewReader(in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var details [][]string
+			for _, f := range got.Root.Families {
+				details = append(details, f.Other.Details)
+			}
+			if diff := cmp.Diff(tc.want, details); diff != "" {
+				t.Errorf("details mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMultipleDetailGroups(t *testing.T) {
+	testCases := []struct {
+		name string
+		keep bool
+		in   string
+		want []string
+	}{
+		{
+			name: "single",
+			in:   "1. John Smith (b. 1850; d. 1900)",
+			want: []string{"b. 1850", "d. 1900"},
+		},
+		{
+			name: "nested",
+			in:   "1. John Smith (b. 1850 (approx); d. 1900)",
+			want: []string{"b. 1850 (approx)", "d. 1900"},
+		},
+		{
+			name: "two",
+			in:   "1. John Smith (b. 1850) (occupation: farmer)",
+			want: []string{"b. 1850", "occupation: farmer"},
+		},
+		{
+			name: "three",
+			in:   "1. John Smith (b. 1850; d. 1900) (occupation: farmer) (res. Leeds (1881))",
+			want: []string{"b. 1850", "d. 1900", "occupation: farmer", "res. Leeds (1881)"},
+		},
+		{
+			name: "no_name",
+			in:   "1. (b. 1850)(occupation: farmer)",
+			want: []string{"b. 1850", "occupation: farmer"},
+		},
+		{
+			name: "trailing_ignored",
+			in:   "1. John Smith (b. 1850) (occupation: farmer), m. 1875",
+			want: []string{"b. 1850", "occupation: farmer"},
+		},
+		{
+			name: "trailing_kept",
+			keep: true,
+			in:   "1. John Smith (b. 1850) (occupation: farmer), m. 1875",
+			want: []string{"b. 1850", "occupation: farmer", "m. 1875"},
+		},
+		{
+			name: "trailing_before_group",
+			keep: true,
+			in:   "1. John Smith (b. 1850), m. 1875 (Leeds)",
+			want: []string{"b. 1850", "m. 1875 (Leeds)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{KeepTrailingDetail: tc.keep}
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Root.Details); diff != "" {
+				t.Errorf("details mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseUnbalancedDetail(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "unclosed",
+			in:   "1. A. Brown (1819-1901; carpenter",
+			want: []string{"1819-1901", "carpenter"},
+		},
+		{
+			name: "unclosed_inner_closed",
+			in:   "1. A. Brown (1819-1901 (carpenter)",
+			want: []string{"1819-1901 (carpenter)"},
+		},
+		{
+			name: "unclosed_inner_unclosed",
+			in:   "1. A. Brown (1819-1901 (carpenter",
+			want: []string{"1819-1901 (carpenter"},
+		},
+		{
+			name: "no_name_unclosed",
+			in:   "1. (1819-1901 carpenter",
+			want: []string{"1819-1901 carpenter"},
+		},
+		{
+			name: "no_name_unclosed_inner_closed",
+			in:   "1. (1819-1901 (carpenter)",
+			want: []string{"1819-1901 (carpenter)"},
+		},
+		{
+			name: "no_name_unclosed_inner_unclosed",
+			in:   "1. (1819-1901 (carpenter",
+			want: []string{"1819-1901 (carpenter"},
+		},
+		{
+			name: "no_whitespace_unclosed",
+			in:   "1. A. Brown(1819-1901; carpenter",
+			want: []string{"1819-1901", "carpenter"},
+		},
+		{
+			name: "inner_groups",
+			in:   "1. A. Brown ((b. 1819) (d. 1901))",
+			want: []string{"(b. 1819) (d. 1901)"},
+		},
+		{
+			name: "second_group_unclosed",
+			in:   "1. A. Brown (1819-1901) (carpenter",
+			want: []string{"1819-1901", "carpenter"},
+		},
+		{
+			name: "stray_closing",
+			in:   "1. A. Brown (1819-1901) carpenter)",
+			want: []string{"1819-1901"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := new(Parser).Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got.Root.Details); diff != "" {
+				t.Errorf("details mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMaxLineBytes(t *testing.T) {
+	detail := strings.Repeat("x", 70*1024)
+	in := lines(
+		"1. A. Brown",
+		"  2. C. Brown ("+detail+")",
+	)
+
+	p := &Parser{}
+	if _, err := p.Parse(context.Background(), strings.NewReader(in)); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("got error %v, wanted %v", err, bufio.ErrTooLong)
+	}
+
+	p = &Parser{MaxLineBytes: 128 * 1024}
+	got, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{detail}, got.Root.Families[0].Children[0].Details); diff != "" {
+		t.Errorf("details mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	// Windows-1252 encoded, with a right single quotation mark (0x92) and two Latin-1 letters
+	in := lines(
+		"1. Mary O\x92Connor (b. 1850)",
+		"  2. Anders \xc5ngstr\xf6m",
+	)
+
+	p := &Parser{Encoding: charmap.Windows1252}
+	got, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"Mary O\u2019Connor"}, got.Root.Headings); diff != "" {
+		t.Errorf("root headings mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Anders Ångström"}, got.Root.Families[0].Children[0].Headings); diff != "" {
+		t.Errorf("child headings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseSpouseIrregularIndent(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want map[string]string // maps spouse name to partner name
+	}{
+		{
+			name: "spouses always indented two spaces",
+			in: lines(
+				"1. A. Brown",
+				"  + S. Green",
+				"  2. B. Brown",
+				"  + T. White",
+				"      3. C. Brown",
+				"  + U. Black",
+				"      3. D. Brown",
+			),
+			want: map[string]string{"S. Green": "A. Brown", "T. White": "B. Brown", "U. Black": "C. Brown"},
+		},
+		{
+			name: "first spouse at lesser indentation",
+			in:   "1. A\n 2. B\n  3. C\n + U",
+			want: map[string]string{"U": "C"},
+		},
+		{
+			name: "second spouse after children",
+			in: lines(
+				"1. A. Brown",
+				"  sp. S. Green",
+				"    2. B. Brown",
+				"  sp. T. White",
+				"    2. C. Brown",
+			),
+			want: map[string]string{"S. Green": "A. Brown", "T. White": "A. Brown"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{}
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			partners := map[string]string{}
+			var walk func(p *DescendantPerson)
+			walk = func(p *DescendantPerson) {
+				for _, f := range p.Families {
+					if f.Other != nil {
+						partners[f.Other.Headings[0]] = p.Headings[0]
+					}
+					for _, c := range f.Children {
+						walk(c)
+					}
+				}
+			}
+			walk(got.Root)
+
+			if diff := cmp.Diff(tc.want, partners); diff != "" {
+				t.Errorf("spouses mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseLifeStatus(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		want LifeStatus
+	}{
+		{name: "death_date", in: "1. A. Brown (b. 1850; d. 22 Jan 1901)", want: LifeStatusDeceased},
+		{name: "deceased_marker", in: "1. A. Brown (b. 1850; d: Deceased)", want: LifeStatusDeceased},
+		{name: "dash_marker", in: "1. A. Brown (b. 1850; d. -)", want: LifeStatusDeceased},
+		{name: "deceased_detail", in: "1. A. Brown (b. 1850; Deceased)", want: LifeStatusDeceased},
+		{name: "living", in: "1. A. Brown (b. 1950; Living)", want: LifeStatusLiving},
+		{name: "death_living", in: "1. A. Brown (b. 1950; d. living)", want: LifeStatusLiving},
+		{name: "unknown", in: "1. A. Brown (b. 1950)", want: LifeStatusUnknown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Parser{}
+			got, err := p.Parse(context.Background(), strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Root.LifeStatus != tc.want {
+				t.Errorf("got life status %d, wanted %d", got.Root.LifeStatus, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	in := lines(
+		"title: The Brown Family",
+		"Title: of Kent",
+		"note: Compiled from parish registers.",
+		"",
+		"NOTE: Dates are of baptism where birth is unknown.",
+		"1. A. Brown (b. 1819)",
+		"  sp. B. Green",
+		"   2. C. Brown",
+	)
+
+	p := &Parser{}
+	got, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "The Brown Family of Kent"; got.Title != want {
+		t.Errorf("got title %q, wanted %q", got.Title, want)
+	}
+	wantNotes := []string{"Compiled from parish registers.", "Dates are of baptism where birth is unknown."}
+	if diff := cmp.Diff(wantNotes, got.Notes); diff != "" {
+		t.Errorf("notes mismatch (-want +got):\n%s", diff)
+	}
+
+	// the people are the same as without the directives
+	plain, err := p.Parse(context.Background(), strings.NewReader(lines(
+		"1. A. Brown (b. 1819)",
+		"  sp. B. Green",
+		"   2. C. Brown",
+	)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(plain.Root, got.Root); diff != "" {
+		t.Errorf("people mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseOptionDirectives(t *testing.T) {
+	in := lines(
+		"title: The Brown Family",
+		"option: wrapwidth=200",
+		"Option: Spouse-Side = left",
+		"option: stack_spouses=yes",
+		"option: fontscale=1.5",
+		"option: detail-align=centre",
+		"1. A. Brown (b. 1819)",
+		"  sp. B. Green",
+		"   2. C. Brown",
+	)
+
+	p := &Parser{}
+	got, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Options == nil {
+		t.Fatalf("got no options, wanted options set by the directives")
+	}
+
+	want := DefaultLayoutOptions()
+	want.DetailWrapWidth = 200
+	want.SpouseSide = SpouseLeft
+	want.StackSpouses = true
+	want.FontScale = 1.5
+	want.DetailAlign = AlignCentre
+	if diff := cmp.Diff(want, got.Options); diff != "" {
+		t.Errorf("options mismatch (-want +got):\n%s", diff)
+	}
+	if want := "The Brown Family"; got.Title != want {
+		t.Errorf("got title %q, wanted %q", got.Title, want)
+	}
+
+	plain, err := p.Parse(context.Background(), strings.NewReader(lines("1. A. Brown (b. 1819)")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain.Options != nil {
+		t.Errorf("got options, wanted nil without option directives")
+	}
+}
+
+func TestParseOptionDirectiveErrors(t *testing.T) {
+	testCases := []struct {
+		directive string
+		want      string
+	}{
+		{directive: "option: colour=red", want: `line 1: unknown option "colour"`},
+		{directive: "option: wrapwidth", want: `line 1: option "wrapwidth" has no value`},
+		{directive: "option: wrapwidth=wide", want: `line 1: option "wrapwidth": invalid value "wide"`},
+		{directive: "option: spouse-side=above", want: `line 1: option "spouse-side": invalid value "above": must be left, right or below`},
+		{directive: "option: siblingbar=maybe", want: `line 1: option "siblingbar": invalid value "maybe"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.directive, func(t *testing.T) {
+			p := &Parser{}
+			_, err := p.Parse(context.Background(), strings.NewReader(lines(tc.directive, "1. A. Brown")))
+			if err == nil {
+				t.Fatalf("got no error, wanted %q", tc.want)
+			}
+			if !strings.HasPrefix(err.Error(), tc.want) {
+				t.Errorf("got error %q, wanted it to begin %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDerivedDetail(t *testing.T) {
+	var in string
+	for _, tc := range testCases {
+		if tc.name == "long descendant chart" {
+			in = tc.in
+		}
+	}
+	if in == "" {
+		t.Fatalf("ancestry style fixture not found")
+	}
+
+	// details returns the details of everyone in the chart, in the order they appear
+	details := func(ch *DescendantChart) []string {
+		var out []string
+		var walk func(p *DescendantPerson)
+		walk = func(p *DescendantPerson) {
+			out = append(out, p.Details...)
+			for _, f := range p.Families {
+				if f.Other != nil {
+					walk(f.Other)
+				}
+				for _, c := range f.Children {
+					walk(c)
+				}
+			}
+		}
+		walk(ch.Root)
+		return out
+	}
+
+	countAge := func(lines []string) int {
+		n := 0
+		for _, l := range lines {
+			if strings.Contains(l, "age") {
+				n++
+			}
+		}
+		return n
+	}
+
+	parse := func(mode DerivedDetailMode) []string {
+		p := &Parser{DerivedDetail: mode}
+		ch, err := p.Parse(context.Background(), strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return details(ch)
+	}
+
+	kept := parse(DerivedDetailKeep)
+	ages := 0
+	for _, l := range kept {
+		if strings.HasPrefix(l, "age: ") {
+			ages++
+		}
+	}
+	if ages == 0 {
+		t.Fatalf("got no age lines by default, wanted them preserved")
+	}
+
+	dropped := parse(DerivedDetailDrop)
+	if got := countAge(dropped); got != 0 {
+		t.Errorf("got %d lines mentioning age, wanted none", got)
+	}
+	if got, want := len(dropped), len(kept)-ages; got != want {
+		t.Errorf("got %d detail lines, wanted %d", got, want)
+	}
+
+	merged := parse(DerivedDetailMerge)
+	if got, want := len(merged), len(kept)-ages; got != want {
+		t.Errorf("got %d detail lines, wanted %d", got, want)
+	}
+	if got := countAge(merged); got != ages {
+		t.Errorf("got %d lines mentioning age, wanted %d", got, ages)
+	}
+	if want := "b: Abt. 1806 in Kilford, Ireland. d: 17 Sep 1861 in Swindon, Wiltshire, England (age 55)"; merged[0] != want {
+		t.Errorf("got first detail %q, wanted %q", merged[0], want)
+	}
+}
+
+func TestParseDerivedDetailLines(t *testing.T) {
+	testCases := []struct {
+		line string
+		want []string
+	}{
+		{line: "age: 55.", want: []string{"d. 1861 (age 55)"}},
+		{line: "Aged 3 months", want: []string{"d. 1861 (aged 3 months)"}},
+		{line: "age abt. 40", want: []string{"d. 1861 (age abt. 40)"}},
+		{line: "Aged care resident", want: []string{"d. 1861", "Aged care resident"}},
+		{line: "Agent for the estate", want: []string{"d. 1861", "Agent for the estate"}},
+		{line: "age unknown", want: []string{"d. 1861", "age unknown"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.line, func(t *testing.T) {
+			p := &Parser{DerivedDetail: DerivedDetailMerge}
+			got := p.derivedDetails([]string{"d. 1861", tc.line})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("derivedDetails mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseImpliedChildren(t *testing.T) {
+	in := lines(
+		"1. A. Brown (b. 1819)",
+		"  sp. B. Green",
+		"    C. Brown (b. 1841)",
+		"    D. Brown",
+		"      E. Brown",
+		"    ... (b. 1870)",
+		"    F. Brown",
+		"   2. G. Brown",
+	)
+
+	t.Run("strict", func(t *testing.T) {
+		p := &Parser{}
+		got, err := p.Parse(context.Background(), strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// unnumbered lines are wrapped text of the spouse
+		if diff := cmp.Diff([]string{"B. Green C. Brown"}, got.Root.Families[0].Other.Headings); diff != "" {
+			t.Errorf("spouse headings mismatch (-want +got):\n%s", diff)
+		}
+		if got, want := len(got.Root.Families[0].Children), 1; got != want {
+			t.Errorf("got %d children, wanted %d", got, want)
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		p := &Parser{ImpliedChildren: true}
+		got, err := p.Parse(context.Background(), strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{
+			"A. Brown",
+			"  = B. Green",
+			"    C. Brown",
+			"    D. Brown",
+			"        E. Brown",
+			"    F. Brown",
+			"    G. Brown",
+		}
+		if diff := cmp.Diff(want, describeTree(got.Root)); diff != "" {
+			t.Errorf("tree mismatch (-want +got):\n%s", diff)
+		}
+
+		// an implied child may still be wrapped using the continuation prefix
+		e := got.Root.Families[0].Children[1].Families[0].Children[0]
+		if diff := cmp.Diff([]string{"b. 1870"}, e.Details); diff != "" {
+			t.Errorf("details mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
@@ -2,15 +2,62 @@ package gtree
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
+// SVGOptions defines various parameters for rendering a layout as SVG.
+type SVGOptions struct {
+	Scale      float64 // Scale is a uniform scale factor applied to the entire drawing. Zero is treated as 1 (no scaling).
+	Background string  // Background is the fill color of the background. Empty or "transparent" omits the background.
+
+	Unit       string     // Unit is the unit used for the width and height of the drawing: "px", "pt", "in" or "mm". Empty gives lengths without a unit, which are treated as pixels.
+	Resolution Resolution // Resolution is the number of pixels of the layout per inch used when converting to Unit, such as 90 for a chart intended for printing. Zero is treated as CSSResolution.
+	Responsive bool       // Responsive indicates whether the drawing should scale to fit its container. The root element is given a viewBox in place of a fixed width and height so that its size may be set with CSS.
+
+	SexSymbols bool // SexSymbols indicates whether a symbol denoting the sex of each person should be drawn after their name, when known.
+
+	DNAMarkerColor string // DNAMarkerColor is the color of the dot drawn after the name of people with DNA test results. Empty uses the color of the heading.
+
+	HighlightColor string // HighlightColor is the color used to draw the border of highlighted blurbs and highlighted connectors. Empty uses DefaultHighlightColor.
+
+	ConnectorLineCap  string // ConnectorLineCap is the shape of the ends of connecting lines: "butt" (the default), "round" or "square".
+	ConnectorLineJoin string // ConnectorLineJoin is the shape of the corners of connecting lines: "miter" (the default), "round" or "bevel".
+
+	FontFamily string // FontFamily is the name of the font used for all text. The viewer's default font is used if empty.
+	FontData   []byte // FontData is the content of a TrueType, OpenType, WOFF or WOFF2 font file that is embedded in the drawing as FontFamily so it renders the same on every viewer.
+}
+
+// DefaultHighlightColor is the color used to draw highlighted blurbs and connectors when no other
+// is given.
+const DefaultHighlightColor = "#c00000"
+
+// DefaultSVGOptions returns the default options for rendering a layout as SVG.
+func DefaultSVGOptions() *SVGOptions {
+	return &SVGOptions{
+		Scale:      1,
+		Background: "white",
+
+		HighlightColor: DefaultHighlightColor,
+		DNAMarkerColor: "#1f6fb2",
+	}
+}
+
 // SVG generates an SVG (Scalable Vector Graphics) representation of the provided layout.
 // It takes a Layout interface as input and returns a string containing the SVG markup, or an error if the generation fails.
 //
 // The SVG output includes:
 // - The XML declaration and SVG root element with specified width and height based on the layout dimensions.
-// - A white background covering the entire SVG canvas.
+// - A white background covering the entire SVG canvas, unless a different background is specified using SVGWithOptions.
 // - The title of the chart, if provided, rendered at the top of the SVG.
 // - Any notes, rendered below the title, with appropriate spacing.
 // - Blurbs representing individuals or family members, each with their associated text and optional background rectangle if debug mode is enabled.
@@ -19,68 +66,773 @@ import (
 // The function iterates over the layout elements (title, notes, blurbs, connectors), converts their properties to SVG-compatible attributes,
 // and appends them to an internal buffer. Finally, it returns the complete SVG as a string.
 func SVG(lay Layout) (string, error) {
+	return SVGWithOptions(lay, nil)
+}
+
+// SVGWithOptions generates an SVG representation of the provided layout using the supplied options.
+// If opts is nil then the default options are used.
+func SVGWithOptions(lay Layout, opts *SVGOptions) (string, error) {
 	buf := new(bytes.Buffer)
+	if err := SVGTo(buf, lay, opts); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderDescendantSVG parses the descendant list read from r using a Parser with default settings,
+// lays it out using the supplied options and returns the chart as SVG. If opts is nil then the
+// default layout options are used. Use Parse, Layout and SVGWithOptions directly for finer control.
+func RenderDescendantSVG(ctx context.Context, r io.Reader, opts *LayoutOptions) (string, error) {
+	ch, err := new(Parser).Parse(ctx, r)
+	if err != nil {
+		return "", fmt.Errorf("parse: %w", err)
+	}
+	return SVG(ch.Layout(opts))
+}
+
+// RenderAncestorSVG parses the descendant list read from r using a Parser with default settings
+// and returns an SVG chart of the ancestors of the person with the given id, laid out using the
+// supplied options. If opts is nil then the default ancestor layout options are used.
+func RenderAncestorSVG(ctx context.Context, r io.Reader, id int, opts *AncestorLayoutOptions) (string, error) {
+	ch, err := new(Parser).Parse(ctx, r)
+	if err != nil {
+		return "", fmt.Errorf("parse: %w", err)
+	}
+	ach, err := ch.AncestorsOf(id)
+	if err != nil {
+		return "", err
+	}
+	return SVG(ach.Layout(opts))
+}
+
+// SVGZ generates a gzip compressed SVG representation of the provided layout, suitable for
+// saving as an .svgz file.
+func SVGZ(lay Layout) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	if err := SVGTo(zw, lay, nil); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PlacedLayout is a layout positioned within a composite drawing.
+type PlacedLayout struct {
+	Layout  Layout
+	OffsetX Pixel // OffsetX is the distance of the left edge of the layout from the left edge of the drawing.
+	OffsetY Pixel // OffsetY is the distance of the top edge of the layout from the top edge of the drawing.
+}
+
+// SVGComposite generates a single SVG drawing containing each of the provided layouts at its own
+// offset, such as an ancestor chart beside a descendant chart. The drawing is sized to enclose all
+// of the layouts.
+func SVGComposite(items []PlacedLayout) (string, error) {
+	return SVGCompositeWithOptions(items, nil)
+}
+
+// SVGCompositeWithOptions generates a single SVG drawing containing each of the provided layouts
+// using the supplied options. If opts is nil then the default options are used.
+func SVGCompositeWithOptions(items []PlacedLayout, opts *SVGOptions) (string, error) {
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+	scale := opts.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	var width, height Pixel
+	for i, item := range items {
+		if item.OffsetX < 0 || item.OffsetY < 0 {
+			return "", fmt.Errorf("layout %d: negative offset (%d,%d)", i, item.OffsetX, item.OffsetY)
+		}
+		width = max(width, item.OffsetX+item.Layout.Width())
+		height = max(height, item.OffsetY+item.Layout.Height())
+	}
+
+	out := new(bytes.Buffer)
+	buf := &errWriter{w: out}
+	if err := svgStart(buf, max(scalePixel(width, scale), 1), max(scalePixel(height, scale), 1), opts); err != nil {
+		return "", err
+	}
+
+	if scale != 1 {
+		fmt.Fprintf(buf, "<g transform=\"scale(%s)\">\n", strconv.FormatFloat(scale, 'f', -1, 64))
+	}
+
+	for _, item := range items {
+		fmt.Fprintf(buf, "<g transform=\"translate(%s,%s)\">\n", length(item.OffsetX), length(item.OffsetY))
+		svgLayout(buf, item.Layout, opts)
+		fmt.Fprintln(buf, "</g>")
+	}
+
+	if scale != 1 {
+		fmt.Fprintln(buf, "</g>")
+	}
+
+	fmt.Fprintln(buf, "</svg>")
+
+	if buf.err != nil {
+		return "", buf.err
+	}
+	return out.String(), nil
+}
+
+// BlurbSVG generates a small standalone SVG drawing of a single blurb, such as a preview of one
+// person in a chart. The drawing is sized to the blurb, with room for any border, and the blurb is
+// drawn as it would be by SVG. If opts is nil then the default options are used.
+func BlurbSVG(b *Blurb, opts *SVGOptions) (string, error) {
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+	scale := opts.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	// room for a highlight, focus or collapsed border drawn around the blurb
+	const border = Pixel(5)
+	width, height := b.Width+2*border, b.Height+2*border
+
+	out := new(bytes.Buffer)
+	buf := &errWriter{w: out}
+	if err := svgStart(buf, max(scalePixel(width, scale), 1), max(scalePixel(height, scale), 1), opts); err != nil {
+		return "", err
+	}
+
+	transform := fmt.Sprintf("translate(%s,%s)", length(border-b.Left()), length(border-b.TopPos))
+	if scale != 1 {
+		transform = fmt.Sprintf("scale(%s) %s", strconv.FormatFloat(scale, 'f', -1, 64), transform)
+	}
+	fmt.Fprintf(buf, "<g transform=\"%s\">\n", transform)
+	svgBlurb(buf, b, opts)
+	fmt.Fprintln(buf, "</g>")
+	fmt.Fprintln(buf, "</svg>")
+
+	if buf.err != nil {
+		return "", buf.err
+	}
+	return out.String(), nil
+}
+
+// SVGTo writes an SVG representation of the provided layout to w using the supplied options.
+// If opts is nil then the default options are used.
+//
+// When a scale other than 1 is specified the drawing is wrapped in a group with a scale transform
+// and the width and height of the root element are adjusted to match.
+func SVGTo(w io.Writer, lay Layout, opts *SVGOptions) error {
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+	scale := opts.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	buf := &errWriter{w: w}
+
+	// some renderers reject an image without any area
+	width, height := max(scalePixel(lay.Width(), scale), 1), max(scalePixel(lay.Height(), scale), 1)
+	if err := svgStart(buf, width, height, opts); err != nil {
+		return err
+	}
+
+	if scale != 1 {
+		fmt.Fprintf(buf, "<g transform=\"scale(%s)\">\n", strconv.FormatFloat(scale, 'f', -1, 64))
+	}
+
+	svgLayout(buf, lay, opts)
+
+	if scale != 1 {
+		fmt.Fprintln(buf, "</g>")
+	}
+
+	fmt.Fprintln(buf, "</svg>")
+
+	return buf.err
+}
+
+// svgStart writes the start of an SVG document with the given dimensions, including any font
+// definitions and background required by the options.
+func svgStart(buf *errWriter, width, height Pixel, opts *SVGOptions) error {
+	var size string
+	res := opts.Resolution
+	if res == 0 {
+		res = CSSResolution
+	}
+	switch {
+	case opts.Unit == "":
+		size = fmt.Sprintf("width=\"%s\" height=\"%s\"", length(width), length(height))
+	case opts.Unit == "px" && res == CSSResolution:
+		size = fmt.Sprintf("width=\"%spx\" height=\"%spx\"", length(width), length(height))
+	case opts.Unit == "px", opts.Unit == "pt", opts.Unit == "in", opts.Unit == "mm":
+		size = fmt.Sprintf("width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\"", physicalLength(width, opts.Unit, res), physicalLength(height, opts.Unit, res), length(width), length(height))
+	default:
+		return fmt.Errorf("unsupported unit: %q", opts.Unit)
+	}
+	switch opts.ConnectorLineCap {
+	case "", "butt", "round", "square":
+	default:
+		return fmt.Errorf("unsupported connector line cap: %q", opts.ConnectorLineCap)
+	}
+	switch opts.ConnectorLineJoin {
+	case "", "miter", "round", "bevel":
+	default:
+		return fmt.Errorf("unsupported connector line join: %q", opts.ConnectorLineJoin)
+	}
+	if opts.Responsive {
+		// the container decides the size, preserving the aspect ratio of the drawing
+		size = fmt.Sprintf("viewBox=\"0 0 %s %s\"", length(width), length(height))
+	}
 
 	fmt.Fprintf(buf, "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
-	fmt.Fprintf(buf, "<svg width=\"%s\" height=\"%s\" xmlns=\"http://www.w3.org/2000/svg\">\n", length(lay.Width()), length(lay.Height()))
+	fmt.Fprintf(buf, "<svg %s xmlns=\"http://www.w3.org/2000/svg\">\n", size)
+
+	if opts.FontFamily != "" {
+		fmt.Fprintf(buf, "<style>\n")
+		if len(opts.FontData) > 0 {
+			mimeType, format := fontFormat(opts.FontData)
+			fmt.Fprintf(buf, "@font-face { font-family: %s; src: url(data:%s;base64,%s) format(%q); }\n", cssString(opts.FontFamily), mimeType, base64.StdEncoding.EncodeToString(opts.FontData), format)
+		}
+		fmt.Fprintf(buf, "text { font-family: %s; }\n", cssString(opts.FontFamily))
+		fmt.Fprintf(buf, "</style>\n")
+	}
+
+	if opts.Background != "" && opts.Background != "transparent" {
+		fmt.Fprintf(buf, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", opts.Background)
+	}
+
+	return nil
+}
 
-	// White background
-	fmt.Fprintln(buf, `<rect width="100%" height="100%" fill="white"/>`)
+// svgLayout writes the elements of a layout: its title and notes, blurbs, connectors and any
+// labels or footnotes.
+func svgLayout(buf *errWriter, lay Layout, opts *SVGOptions) {
+	if wl, ok := lay.(watermarker); ok {
+		if wm := wl.Watermark(); wm.Text != "" {
+			// centred beneath everything else, running along the diagonal
+			cx, cy := lay.Width()/2, lay.Height()/2
+			fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"middle\" text-anchor=\"middle\" transform=\"rotate(%s %s %s)\" font-size=\"%dpx\" fill=\"%s\"%s>%s</text>\n", length(cx), length(cy), strconv.FormatFloat(watermarkAngle(lay.Width(), lay.Height()), 'f', 2, 64), length(cx), length(cy), wm.Style.FontSize, wm.Style.Color, fontAttrs(wm.Style), wm.Text)
+		}
+	}
 
 	var y Pixel
-	title := lay.Title()
-	if title.Text != "" {
-		fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"alphabetic\" text-anchor=\"start\" font-size=\"%dpx\" letter-spacing=\"0\">%s</text>\n", length(lay.Margin()), length(lay.Margin()+title.Style.LineHeight), title.Style.FontSize, title.Text)
+	titles := []TextElement{lay.Title()}
+	if tl, ok := lay.(titleLiner); ok {
+		titles = tl.TitleLines()
+	}
+	for _, title := range titles {
+		if title.Text == "" {
+			continue
+		}
+		dir := title.Style.Direction.resolve(title.Text)
+		fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"alphabetic\" text-anchor=\"%s\"%s font-size=\"%dpx\" letter-spacing=\"0\"%s%s>%s</text>\n", length(lay.Margin()), length(lay.Margin()+title.Style.LineHeight+y), leftAnchor(dir), directionAttrs(dir), title.Style.FontSize, fontAttrs(title.Style), haloAttrs(title.Style), title.Text)
 		y += title.Style.LineHeight
 	}
 
 	notes := lay.Notes()
 	for i := range notes {
-		fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"alphabetic\" text-anchor=\"start\" font-size=\"%dpx\" letter-spacing=\"0\">%s</text>\n", length(lay.Margin()), length(lay.Margin()+notes[i].Style.LineHeight+y), notes[i].Style.FontSize, notes[i].Text)
+		dir := notes[i].Style.Direction.resolve(notes[i].Text)
+		fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"alphabetic\" text-anchor=\"%s\"%s font-size=\"%dpx\" letter-spacing=\"0\"%s%s>%s</text>\n", length(lay.Margin()), length(lay.Margin()+notes[i].Style.LineHeight+y), leftAnchor(dir), directionAttrs(dir), notes[i].Style.FontSize, fontAttrs(notes[i].Style), haloAttrs(notes[i].Style), notes[i].Text)
 		y += notes[i].Style.LineHeight
 	}
 
+	// Draw the wedges of a fan chart
+	if wl, ok := lay.(wedger); ok {
+		for _, w := range wl.Wedges() {
+			svgWedge(buf, w, wl.Options())
+		}
+	}
+
+	// A family's relationship marker is drawn with the lines to its children so that a single link
+	// wraps them all
+	connectors := lay.Connectors()
+	families, grouped := linkedFamilies(lay.Blurbs(), connectors)
+
 	// Draw blurbs
 	for _, b := range lay.Blurbs() {
 		_ = b
 		if lay.Debug() {
-			fmt.Fprintf(buf, "<!-- blurb %s (left=%d, top=%d, width=%d, height=%d) -->\n", b.HeadingTexts.Lines[0], b.Left(), b.TopPos, b.Width, b.Height)
+			fmt.Fprintf(buf, "<!-- blurb %s (id=%d, left=%d, top=%d, width=%d, height=%d, leftpad=%d, noshift=%v, keeptightright=%s, leftneighbour=%s, parent=%s) -->\n", b.HeadingTexts.Lines[0], b.ID, b.Left(), b.TopPos, b.Width, b.Height, b.LeftPad, b.NoShift, debugRef(b.KeepTightRight), debugRef(b.LeftNeighbour), debugRef(b.Parent))
 			fmt.Fprintf(buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"#eeeeee\"/>", length(b.Left()), length(b.TopPos), length(b.Width), length(b.Height))
 		}
-		textAnchor := "start"
-		textx := length(b.Left())
-		if b.CentreText {
-			textAnchor = "middle"
-			textx = length(b.X())
+		if grouped[b] {
+			continue
+		}
+		svgBlurb(buf, b, opts)
+	}
+
+	// Add lines
+	for _, c := range connectors {
+		if c.Link == "" {
+			svgConnector(buf, c, opts)
+			continue
+		}
+		f, ok := families[c]
+		if !ok {
+			// drawn with the first connector of its family
+			continue
 		}
-		fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"hanging\" text-anchor=\"%s\">\n", textx, length(b.TopPos), textAnchor)
-		for _, line := range b.HeadingTexts.Lines {
-			fmt.Fprintf(buf, "<tspan x=\"%s\" dy=\"%s\" font-size=\"%dpx\" fill=\"%s\">%s</tspan>\n", textx, length(b.HeadingTexts.Style.LineHeight), b.HeadingTexts.Style.FontSize, b.HeadingTexts.Style.Color, line)
+		fmt.Fprintf(buf, "<a href=\"%s\">\n", html.EscapeString(f.link))
+		if f.marker != nil {
+			svgBlurbContent(buf, f.marker, opts)
 		}
-		for _, line := range b.DetailTexts.Lines {
-			fmt.Fprintf(buf, "<tspan x=\"%s\" dy=\"%s\" font-size=\"%dpx\" fill=\"%s\">%s</tspan>\n", textx, length(b.DetailTexts.Style.LineHeight), b.DetailTexts.Style.FontSize, b.DetailTexts.Style.Color, line)
+		for _, o := range f.connectors {
+			svgConnector(buf, o, opts)
 		}
-		fmt.Fprintf(buf, "</text>\n")
+		fmt.Fprintf(buf, "</a>\n")
 	}
 
-	// Add lines
-	for _, b := range lay.Connectors() {
-		var data string
-		for i, p := range b.Points {
-			if i == 0 {
-				data = fmt.Sprintf("M %s,%s", length(p.X), length(p.Y))
-				continue
+	// Add any labels outside the blurbs, such as generation labels
+	if ll, ok := lay.(labeler); ok {
+		for _, lb := range ll.GenerationLabels() {
+			dir := lb.Style.Direction.resolve(lb.Text)
+			fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"middle\" text-anchor=\"%s\"%s font-size=\"%dpx\" fill=\"%s\"%s%s>%s</text>\n", length(lb.Left), length(lb.Y), leftAnchor(dir), directionAttrs(dir), lb.Style.FontSize, lb.Style.Color, fontAttrs(lb.Style), haloAttrs(lb.Style), lb.Text)
+		}
+	}
+
+	// Add any notes attached to individual people at the bottom of the chart
+	if fl, ok := lay.(footnoter); ok {
+		footnotes := fl.Footnotes()
+		y := lay.Height() - lay.Margin()
+		for i := len(footnotes) - 1; i >= 0; i-- {
+			dir := footnotes[i].Style.Direction.resolve(footnotes[i].Text)
+			fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"alphabetic\" text-anchor=\"%s\"%s font-size=\"%dpx\" letter-spacing=\"0\"%s%s>%s</text>\n", length(lay.Margin()), length(y), leftAnchor(dir), directionAttrs(dir), footnotes[i].Style.FontSize, fontAttrs(footnotes[i].Style), haloAttrs(footnotes[i].Style), footnotes[i].Text)
+			y -= footnotes[i].Style.LineHeight
+		}
+	}
+}
+
+// svgConnector writes the line of a connector.
+func svgConnector(buf *errWriter, c *Connector, opts *SVGOptions) {
+	line := connectorLine(c, opts)
+	stroke, strokeWidth := line.Color, strconv.FormatFloat(line.Width, 'f', 7, 64)
+	dash := ""
+	if line.Dashed {
+		dash = ";stroke-dasharray:8,6"
+	}
+	lineCap, lineJoin := "butt", "miter"
+	if opts.ConnectorLineCap != "" {
+		lineCap = opts.ConnectorLineCap
+	}
+	if opts.ConnectorLineJoin != "" {
+		lineJoin = opts.ConnectorLineJoin
+	}
+	fmt.Fprintf(buf, "<path style=\"fill:none;fill-opacity:0.75000000;fill-rule:evenodd;stroke:%s;stroke-width:%s;stroke-linecap:%s;stroke-linejoin:%s;stroke-miterlimit:4.0000000;stroke-opacity:1.0000000%s\" d=\"%s\" />\n", stroke, strokeWidth, lineCap, lineJoin, dash, connectorPath(c))
+}
+
+// svgBlurb writes a blurb, wrapped in its link if it has one.
+func svgBlurb(buf *errWriter, b *Blurb, opts *SVGOptions) {
+	if b.Link != "" {
+		fmt.Fprintf(buf, "<a href=\"%s\">\n", html.EscapeString(b.Link))
+		defer fmt.Fprintf(buf, "</a>\n")
+	}
+	svgBlurbContent(buf, b, opts)
+}
+
+// svgBlurbContent writes the text of a blurb along with any border, marker shape, sex symbol or note
+// references it has.
+func svgBlurbContent(buf *errWriter, b *Blurb, opts *SVGOptions) {
+	if len(b.Meta) > 0 {
+		// the blurb is grouped so scripts can read its metadata from the data attributes
+		fmt.Fprintf(buf, "<g%s>\n", dataAttrs(b.Meta))
+		defer fmt.Fprintf(buf, "</g>\n")
+	}
+	for _, op := range blurbShapes(b, opts) {
+		svgShape(buf, op)
+	}
+
+	textAnchor := "start"
+	textx := length(b.Left())
+	if b.CentreText {
+		textAnchor = "middle"
+		textx = length(b.X())
+	}
+	fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"hanging\" text-anchor=\"%s\">\n", textx, length(b.TopPos), textAnchor)
+	y := b.TopPos // the hanging baseline of the previous line
+	for _, line := range blurbLines(b) {
+		style := line.Style
+		anchorAttr := ""
+		if anchor := svgAnchor(line.Anchor, style.Direction); anchor != textAnchor {
+			anchorAttr = fmt.Sprintf(" text-anchor=\"%s\"", anchor)
+		}
+		baseline := line.Y + style.LineHeight
+		if line.InColumn {
+			// each line is positioned absolutely within its column
+			fmt.Fprintf(buf, "<tspan x=\"%s\" y=\"%s\"%s%s font-size=\"%dpx\" fill=\"%s\"%s%s>%s</tspan>\n", length(line.X), length(baseline), anchorAttr, directionAttrs(style.Direction), style.FontSize, style.Color, fontAttrs(style), haloAttrs(style), line.Text)
+		} else {
+			fmt.Fprintf(buf, "<tspan x=\"%s\" dy=\"%s\"%s%s font-size=\"%dpx\" fill=\"%s\"%s%s>%s</tspan>\n", length(line.X), length(baseline-y), anchorAttr, directionAttrs(style.Direction), style.FontSize, style.Color, fontAttrs(style), haloAttrs(style), line.Text)
+		}
+		y = baseline
+	}
+	fmt.Fprintf(buf, "</text>\n")
+
+	for _, op := range blurbMarks(b, opts) {
+		svgShape(buf, op)
+	}
+}
+
+// svgShape writes a drawing operation that is part of a blurb.
+func svgShape(buf *errWriter, op DrawOp) {
+	switch op := op.(type) {
+	case DrawRect:
+		fill := op.Fill
+		if fill == "" {
+			fill = "none"
+		}
+		dash := ""
+		if op.Dashed {
+			dash = ` stroke-dasharray="4,3"`
+		}
+		fmt.Fprintf(buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" rx=\"%s\" fill=\"%s\" stroke=\"%s\" stroke-width=\"%s\"%s/>\n", length(op.X), length(op.Y), length(op.Width), length(op.Height), length(op.Radius), fill, op.Stroke, strconv.FormatFloat(op.StrokeWidth, 'f', -1, 64), dash)
+	case DrawCircle:
+		fmt.Fprintf(buf, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" fill=\"%s\"/>\n", length(op.X), length(op.Y), length(op.Radius), op.Fill)
+	case DrawPolygon:
+		points := make([]string, len(op.Points))
+		for i, p := range op.Points {
+			points[i] = length(p.X) + "," + length(p.Y)
+		}
+		fmt.Fprintf(buf, "<polygon points=\"%s\" fill=\"%s\"/>\n", strings.Join(points, " "), op.Fill)
+	case DrawText:
+		// the hanging baseline is placed at the foot of the line, as for the lines of a blurb
+		dir := op.Style.Direction.resolve(op.Text)
+		fmt.Fprintf(buf, "<text x=\"%s\" y=\"%s\" dominant-baseline=\"hanging\" text-anchor=\"%s\"%s font-size=\"%dpx\" fill=\"%s\"%s%s>%s</text>\n", length(op.X), length(op.Y+op.Style.LineHeight), svgAnchor(op.Anchor, dir), directionAttrs(dir), op.Style.FontSize, op.Style.Color, fontAttrs(op.Style), haloAttrs(op.Style), op.Text)
+	}
+}
+
+// svgAnchor returns the text-anchor that places the given edge of a line of text written in the
+// given direction at its horizontal position. The anchor is relative to the direction of the text.
+func svgAnchor(edge Alignment, dir Direction) string {
+	switch edge {
+	case AlignCentre:
+		return "middle"
+	case AlignRight:
+		if dir == DirectionRTL {
+			return "start"
+		}
+		return "end"
+	default:
+		return leftAnchor(dir)
+	}
+}
+
+// dataAttrs returns a data-* attribute for each key in meta, in order of key. Keys are lower cased
+// and any character that may not appear in an attribute name, such as a space, is replaced by a
+// hyphen, so "Record ID" is written as data-record-id. A key that is empty once sanitised, or that
+// is the same as an earlier key once sanitised, is skipped.
+func dataAttrs(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var s strings.Builder
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		name := dataAttrName(k)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		fmt.Fprintf(&s, " data-%s=\"%s\"", name, html.EscapeString(meta[k]))
+	}
+	return s.String()
+}
+
+// dataAttrName returns the key in lower case with every character other than a letter, digit,
+// hyphen, underscore or full stop replaced by a hyphen.
+func dataAttrName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, strings.TrimSpace(key))
+}
+
+// svgWedge writes the outline and text of a wedge in a fan chart.
+func svgWedge(buf *errWriter, w *Wedge, opts FanLayoutOptions) {
+	if len(w.Meta) > 0 {
+		fmt.Fprintf(buf, "<g%s>\n", dataAttrs(w.Meta))
+		defer fmt.Fprintf(buf, "</g>\n")
+	}
+	fmt.Fprintf(buf, "<path d=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"%s\"/>\n", wedgePath(w), opts.LineColor, length(opts.LineWidth))
+
+	var lines []TextElement
+	for _, sec := range []TextSection{w.HeadingTexts, w.DetailTexts} {
+		for i, line := range sec.Lines {
+			lines = append(lines, TextElement{Text: line, Style: sec.LineStyle(i)})
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	// the lines are centred on the text position
+	var height Pixel
+	for _, line := range lines {
+		height += line.Style.LineHeight
+	}
+	transform := fmt.Sprintf("translate(%s,%s)", length(w.TextPos.X), length(w.TextPos.Y))
+	if w.TextAngle != 0 {
+		transform += fmt.Sprintf(" rotate(%s)", strconv.FormatFloat(w.TextAngle, 'f', 2, 64))
+	}
+	fmt.Fprintf(buf, "<text transform=\"%s\" dominant-baseline=\"middle\" text-anchor=\"middle\">\n", transform)
+	y := -height / 2
+	for _, line := range lines {
+		dir := line.Style.Direction.resolve(line.Text)
+		fmt.Fprintf(buf, "<tspan x=\"0\" y=\"%s\"%s font-size=\"%dpx\" fill=\"%s\"%s%s>%s</tspan>\n", length(y+line.Style.LineHeight/2), directionAttrs(dir), line.Style.FontSize, line.Style.Color, fontAttrs(line.Style), haloAttrs(line.Style), line.Text)
+		y += line.Style.LineHeight
+	}
+	fmt.Fprintf(buf, "</text>\n")
+}
+
+// wedgePath returns the SVG path data outlining a wedge: an arc along its outer edge and either an
+// arc back along its inner edge or lines to the centre of its circle.
+func wedgePath(w *Wedge) string {
+	pt := func(r Pixel, a float64) string {
+		x, y := w.point(float64(r), a)
+		return strconv.FormatFloat(x, 'f', 2, 64) + "," + strconv.FormatFloat(y, 'f', 2, 64)
+	}
+	arc := func(r Pixel, to float64, clockwise bool) string {
+		large, sweep := 0, 0
+		if w.EndAngle-w.StartAngle > 180 {
+			large = 1
+		}
+		if clockwise {
+			sweep = 1
+		}
+		return fmt.Sprintf(" A %s,%s 0 %d %d %s", length(r), length(r), large, sweep, pt(r, to))
+	}
+
+	if w.EndAngle-w.StartAngle >= 360 {
+		// a complete circle is drawn as two halves since an arc can't end where it starts
+		d := "M " + pt(w.OuterRadius, 0) + fmt.Sprintf(" A %s,%s 0 0 1 %s", length(w.OuterRadius), length(w.OuterRadius), pt(w.OuterRadius, 180)) + fmt.Sprintf(" A %s,%s 0 0 1 %s Z", length(w.OuterRadius), length(w.OuterRadius), pt(w.OuterRadius, 0))
+		if w.InnerRadius > 0 {
+			d += " M " + pt(w.InnerRadius, 0) + fmt.Sprintf(" A %s,%s 0 0 0 %s", length(w.InnerRadius), length(w.InnerRadius), pt(w.InnerRadius, 180)) + fmt.Sprintf(" A %s,%s 0 0 0 %s Z", length(w.InnerRadius), length(w.InnerRadius), pt(w.InnerRadius, 0))
+		}
+		return d
+	}
+
+	d := "M " + pt(w.InnerRadius, w.StartAngle) + " L " + pt(w.OuterRadius, w.StartAngle) + arc(w.OuterRadius, w.EndAngle, true)
+	if w.InnerRadius > 0 {
+		d += " L " + pt(w.InnerRadius, w.EndAngle) + arc(w.InnerRadius, w.StartAngle, false)
+	}
+	return d + " Z"
+}
+
+// debugRef returns the id of a blurb referred to by another for use in debug output, or "none" if
+// there is no blurb.
+func debugRef(b *Blurb) string {
+	if b == nil {
+		return "none"
+	}
+	return strconv.Itoa(b.ID)
+}
+
+// footnoter is implemented by layouts that collect notes attached to individual people.
+type footnoter interface {
+	Footnotes() []TextElement
+}
+
+// wedger is implemented by layouts that draw each person in a wedge of a fan chart.
+type wedger interface {
+	Wedges() []*Wedge
+	Options() FanLayoutOptions
+}
+
+// titleLiner is implemented by layouts that wrap the title of the chart over several lines.
+type titleLiner interface {
+	TitleLines() []TextElement
+}
+
+// watermarker is implemented by layouts that may have a watermark drawn beneath the chart.
+type watermarker interface {
+	Watermark() TextElement
+}
+
+// labeler is implemented by layouts that label the generations of the chart.
+type labeler interface {
+	GenerationLabels() []Label
+}
+
+// fontFormat returns the media type and CSS format name of the font file with the given content.
+func fontFormat(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte("wOF2")):
+		return "font/woff2", "woff2"
+	case bytes.HasPrefix(data, []byte("wOFF")):
+		return "font/woff", "woff"
+	case bytes.HasPrefix(data, []byte("OTTO")):
+		return "font/otf", "opentype"
+	default:
+		return "font/ttf", "truetype"
+	}
+}
+
+// errWriter wraps a writer and records the first error encountered. Subsequent writes are
+// skipped once an error has occurred.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	if err != nil {
+		ew.err = err
+	}
+	return n, err
+}
+
+// connectorPath returns the SVG path data for a connector. Corners are rounded with quarter
+// circle arcs when the connector has a corner radius. The radius is clamped to half the length
+// of the shorter of the two segments meeting at a corner.
+func connectorPath(c *Connector) string {
+	var data string
+	for i, p := range c.Points {
+		if i == 0 {
+			data = fmt.Sprintf("M %s,%s", length(p.X), length(p.Y))
+			continue
+		}
+		if c.CornerRadius > 0 && i < len(c.Points)-1 {
+			prev, next := c.Points[i-1], c.Points[i+1]
+			inX, inY := sign(p.X-prev.X), sign(p.Y-prev.Y)
+			outX, outY := sign(next.X-p.X), sign(next.Y-p.Y)
+
+			// only round right-angled corners between horizontal and vertical segments
+			straightIn := (inX == 0) != (inY == 0)
+			straightOut := (outX == 0) != (outY == 0)
+			if straightIn && straightOut && inX*outX+inY*outY == 0 {
+				r := min(c.CornerRadius, abs(p.X-prev.X+p.Y-prev.Y)/2, abs(next.X-p.X+next.Y-p.Y)/2)
+				if r > 0 {
+					sweep := 0
+					if inX*outY-inY*outX > 0 {
+						sweep = 1
+					}
+					data += fmt.Sprintf(" L %s,%s", length(p.X-inX*r), length(p.Y-inY*r))
+					data += fmt.Sprintf(" A %s,%s 0 0 %d %s,%s", length(r), length(r), sweep, length(p.X+outX*r), length(p.Y+outY*r))
+					continue
+				}
 			}
-			data += fmt.Sprintf(" L %s,%s", length(p.X), length(p.Y))
 		}
-		fmt.Fprintf(buf, "<path style=\"fill:none;fill-opacity:0.75000000;fill-rule:evenodd;stroke:#000000;stroke-width:2.3750000;stroke-linecap:butt;stroke-linejoin:miter;stroke-miterlimit:4.0000000;stroke-opacity:1.0000000\" d=\"%s\" />\n", data)
+		data += fmt.Sprintf(" L %s,%s", length(p.X), length(p.Y))
 	}
+	return data
+}
 
-	fmt.Fprintln(buf, "</svg>")
+func sign(v Pixel) Pixel {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
 
-	return buf.String(), nil
+func abs(v Pixel) Pixel {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// cssString returns s as a quoted CSS string for use within a style element. Quotes and
+// backslashes are escaped, as are the characters that XML treats as markup, so that other
+// characters, such as accented letters in a font name, are written as they are.
+func cssString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `&`, `\26 `, `<`, `\3c `, `>`, `\3e `).Replace(s) + `"`
 }
 
 func length(v Pixel) string {
 	return fmt.Sprintf("%d", v)
 }
+
+// leftAnchor returns the text-anchor that places the left edge of a line of text written in the
+// given direction at its horizontal position. The anchor is relative to the direction of the text.
+func leftAnchor(dir Direction) string {
+	if dir == DirectionRTL {
+		return "end"
+	}
+	return "start"
+}
+
+// directionAttrs returns the attributes needed to render text in the given direction, or an empty
+// string if the direction is not specified.
+func directionAttrs(dir Direction) string {
+	switch dir {
+	case DirectionLTR:
+		return ` direction="ltr" unicode-bidi="embed"`
+	case DirectionRTL:
+		return ` direction="rtl" unicode-bidi="embed"`
+	default:
+		return ""
+	}
+}
+
+// fontAttrs returns the attributes needed to render text in the given style in a bold weight,
+// italic style or partially transparent, or an empty string if the style needs none of them.
+func fontAttrs(style TextStyle) string {
+	var attrs string
+	if style.Bold {
+		attrs += ` font-weight="bold"`
+	}
+	if style.Italic {
+		attrs += ` font-style="italic"`
+	}
+	if style.Opacity > 0 && style.Opacity < 1 {
+		attrs += fmt.Sprintf(` fill-opacity="%s"`, strconv.FormatFloat(style.Opacity, 'f', -1, 64))
+	}
+	return attrs
+}
+
+// watermarkAngle returns the angle in degrees, measured clockwise, by which a watermark is rotated
+// so that it runs up along the diagonal of a layout of the given size.
+func watermarkAngle(width, height Pixel) float64 {
+	if width <= 0 {
+		return 0
+	}
+	return -math.Atan2(float64(height), float64(width)) * 180 / math.Pi
+}
+
+// haloAttrs returns the attributes needed to draw an outline around text in the given style, or
+// an empty string if the style has no halo. The outline is painted beneath the fill so it does
+// not obscure the text.
+func haloAttrs(style TextStyle) string {
+	if style.Halo == "" {
+		return ""
+	}
+	width := max(style.FontSize/6, 1)
+	return fmt.Sprintf(" stroke=\"%s\" stroke-width=\"%s\" stroke-linejoin=\"round\" paint-order=\"stroke\"", style.Halo, length(width))
+}
+
+// physicalLength converts v to the physical unit using the resolution res and formats it with
+// the unit suffix.
+func physicalLength(v Pixel, unit string, res Resolution) string {
+	in := float64(v) / float64(res)
+	var f float64
+	switch unit {
+	case "px":
+		f = in * float64(CSSResolution)
+	case "pt":
+		f = in * 72
+	case "mm":
+		f = in * 25.4
+	default:
+		f = in
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64) + unit
+}
+
+// scalePixel multiplies v by the scale factor s, rounding to the nearest pixel.
+func scalePixel(v Pixel, s float64) Pixel {
+	return Pixel(float64(v)*s + 0.5)
+}
@@ -0,0 +1,1340 @@
+package gtree
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConnectorPath(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   *Connector
+		want string
+	}{
+		{
+			name: "square corners",
+			in: &Connector{
+				Points: []Point{{X: 0, Y: 100}, {X: 0, Y: 50}, {X: 40, Y: 50}, {X: 40, Y: 0}},
+			},
+			want: "M 0,100 L 0,50 L 40,50 L 40,0",
+		},
+		{
+			name: "rounded corners",
+			in: &Connector{
+				CornerRadius: 6,
+				Points:       []Point{{X: 0, Y: 100}, {X: 0, Y: 50}, {X: 40, Y: 50}, {X: 40, Y: 0}},
+			},
+			want: "M 0,100 L 0,56 A 6,6 0 0 1 6,50 L 34,50 A 6,6 0 0 0 40,44 L 40,0",
+		},
+		{
+			name: "radius clamped by short segment",
+			in: &Connector{
+				CornerRadius: 20,
+				Points:       []Point{{X: 0, Y: 100}, {X: 0, Y: 90}, {X: 40, Y: 90}},
+			},
+			want: "M 0,100 L 0,95 A 5,5 0 0 1 5,90 L 40,90",
+		},
+		{
+			name: "straight line",
+			in: &Connector{
+				CornerRadius: 6,
+				Points:       []Point{{X: 10, Y: 100}, {X: 10, Y: 50}},
+			},
+			want: "M 10,100 L 10,50",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := connectorPath(tc.in)
+			if got != tc.want {
+				t.Errorf("got %q, wanted %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSVGZ(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+
+	data, err := SVGZ(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to open gzip stream: %v", err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+
+	want, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// blurbs are emitted in map order so compare the well-formedness and size rather than the exact content
+	if len(got) != len(want) {
+		t.Errorf("got decompressed length %d, wanted %d", len(got), len(want))
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(got))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decompressed output is not valid xml: %v", err)
+		}
+	}
+}
+
+func TestSVGBackground(t *testing.T) {
+	lay := onePerson.Layout(nil)
+
+	testCases := []struct {
+		name       string
+		background string
+		want       string
+	}{
+		{
+			name:       "white",
+			background: "white",
+			want:       `<rect width="100%" height="100%" fill="white"/>`,
+		},
+		{
+			name:       "custom",
+			background: "#f0e8d8",
+			want:       `<rect width="100%" height="100%" fill="#f0e8d8"/>`,
+		},
+		{
+			name:       "transparent",
+			background: "transparent",
+		},
+		{
+			name:       "empty",
+			background: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultSVGOptions()
+			opts.Background = tc.background
+			s, err := SVGWithOptions(lay, opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.want == "" {
+				if strings.Contains(s, `<rect width="100%"`) {
+					t.Errorf("got background rect, wanted none")
+				}
+				return
+			}
+			if !strings.Contains(s, tc.want) {
+				t.Errorf("missing background rect %q", tc.want)
+			}
+		})
+	}
+}
+
+func TestSVGUnit(t *testing.T) {
+	lay := onePerson.Layout(nil)
+	w, h := lay.Width(), lay.Height()
+
+	opts := DefaultSVGOptions()
+	opts.Unit = "in"
+	s, err := SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf(`<svg width="%.2fin" height="%.2fin" viewBox="0 0 %d %d"`, float64(w)/96, float64(h)/96, w, h)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing root element %q", want)
+	}
+
+	opts.Unit = "furlong"
+	if _, err := SVGWithOptions(lay, opts); err == nil {
+		t.Errorf("got no error for unsupported unit")
+	}
+}
+
+func TestSVGResponsive(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+	w, h := lay.Width(), lay.Height()
+
+	opts := DefaultSVGOptions()
+	opts.Responsive = true
+	s, err := SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf(`<svg viewBox="0 0 %d %d" xmlns=`, w, h)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing root element %q", want)
+	}
+
+	// the viewBox covers the scaled drawing
+	opts.Scale = 2
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = fmt.Sprintf(`<svg viewBox="0 0 %d %d" xmlns=`, 2*w, 2*h)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing scaled root element %q", want)
+	}
+
+	// without the option the drawing has a fixed size
+	s, err = SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, "viewBox") {
+		t.Errorf("got viewBox, wanted fixed width and height")
+	}
+}
+
+func TestSVGUnionMarker(t *testing.T) {
+	testCases := []struct {
+		name   string
+		marker UnionMarker
+		want   string
+	}{
+		{name: "equals", marker: UnionEquals, want: ">=</tspan>"},
+		{name: "dot", marker: UnionDot, want: "<circle "},
+		{name: "diamond", marker: UnionDiamond, want: "<polygon "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultLayoutOptions()
+			opts.UnionMarker = tc.marker
+			lay := onePersonWithSpouseAndChildren.Layout(opts)
+
+			s, err := SVG(lay)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(s, tc.want) {
+				t.Errorf("missing %q", tc.want)
+			}
+			if tc.marker != UnionEquals && strings.Contains(s, ">=</tspan>") {
+				t.Errorf("got marker drawn as text, wanted a shape")
+			}
+
+			rel := lay.blurbs[-2]
+			if rel.Width < opts.HeadingStyle.FontSize/2 {
+				t.Errorf("got marker width %d, wanted room for the shape", rel.Width)
+			}
+		})
+	}
+}
+
+func TestSVGPixelUnit(t *testing.T) {
+	lay := onePerson.Layout(nil)
+	w, h := lay.Width(), lay.Height()
+
+	opts := DefaultSVGOptions()
+	opts.Unit = "px"
+	s, err := SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := fmt.Sprintf(`<svg width="%dpx" height="%dpx" xmlns=`, w, h); !strings.Contains(s, want) {
+		t.Errorf("missing root element %q", want)
+	}
+
+	// at a print resolution each pixel of the layout is larger than a CSS pixel
+	opts.Resolution = 90
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf(`<svg width="%.2fpx" height="%.2fpx" viewBox="0 0 %d %d"`, float64(w)*96/90, float64(h)*96/90, w, h)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing root element %q", want)
+	}
+
+	// the scale applies before conversion
+	opts.Scale = 2
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = fmt.Sprintf(`<svg width="%.2fpx" height="%.2fpx" viewBox="0 0 %d %d"`, float64(2*w)*96/90, float64(2*h)*96/90, 2*w, 2*h)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing scaled root element %q", want)
+	}
+}
+
+func TestSVGSexSymbols(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Person One"},
+			Sex:      Male,
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{
+						ID:       2,
+						Headings: []string{"Person Two"},
+					},
+				},
+			},
+		},
+	}
+
+	opts := DefaultSVGOptions()
+	opts.SexSymbols = true
+	s, err := SVGWithOptions(ch.Layout(nil), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Count(s, Male.Symbol()); got != 1 {
+		t.Errorf("got %d male symbols, wanted 1", got)
+	}
+	if got := strings.Count(s, Female.Symbol()); got != 0 {
+		t.Errorf("got %d female symbols, wanted 0", got)
+	}
+
+	opts.SexSymbols = false
+	s, err = SVGWithOptions(ch.Layout(nil), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, Male.Symbol()) {
+		t.Errorf("got male symbol when symbols are disabled")
+	}
+}
+
+func TestSVGHalo(t *testing.T) {
+	opts := DefaultLayoutOptions()
+
+	s, err := SVG(onePerson.Layout(opts))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, "paint-order") {
+		t.Errorf("got halo when none was configured")
+	}
+
+	opts.HeadingStyle.Halo = "#fff"
+	s, err = SVG(onePerson.Layout(opts))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `stroke="#fff" stroke-width="3" stroke-linejoin="round" paint-order="stroke">Person One</tspan>`
+	if !strings.Contains(s, want) {
+		t.Errorf("missing halo %q", want)
+	}
+}
+
+func TestSVGDetailAlign(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Person One"},
+			Details:  []string{"b. 1900", "d. 1980"},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.DetailAlign = AlignRight
+	lay := ch.Layout(opts)
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, ok := lay.blurbs[1]
+	if !ok {
+		t.Fatalf("blurb not found")
+	}
+	for _, line := range []string{"b. 1900", "d. 1980"} {
+		want := fmt.Sprintf(`<tspan x="%s" dy="%s" text-anchor="end" font-size="%dpx" fill="%s">%s</tspan>`, length(b.Right()), length(opts.DetailStyle.LineHeight), opts.DetailStyle.FontSize, opts.DetailStyle.Color, line)
+		if !strings.Contains(s, want) {
+			t.Errorf("missing right aligned detail %q", want)
+		}
+	}
+
+	want := fmt.Sprintf(`<tspan x="%s" dy="%s" font-size="%dpx" fill="%s">Person One</tspan>`, length(b.Left()), length(opts.HeadingStyle.LineHeight), opts.HeadingStyle.FontSize, opts.HeadingStyle.Color)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing left aligned heading %q", want)
+	}
+}
+
+func TestPersonNotes(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Person One"},
+			Notes:    []string{"Baptism register, St Mary", "1901 census"},
+		},
+	}
+
+	plain := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Person One"},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	lay := ch.Layout(opts)
+
+	b, ok := lay.blurbs[1]
+	if !ok {
+		t.Fatalf("blurb not found")
+	}
+	if diff := cmp.Diff([]int{1, 2}, b.NoteRefs); diff != "" {
+		t.Errorf("note refs mismatch (-want +got):\n%s", diff)
+	}
+
+	wantHeight := plain.Layout(opts).Height() + opts.NoteStyle.LineHeight*3
+	if lay.Height() != wantHeight {
+		t.Errorf("got height %d, wanted %d", lay.Height(), wantHeight)
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{">1,2</text>", ">1. Baptism register, St Mary</text>", ">2. 1901 census</text>"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestSVGMinimumDimensions(t *testing.T) {
+	testCases := []struct {
+		name string
+		lay  Layout
+	}{
+		{
+			name: "one person",
+			lay:  onePerson.Layout(nil),
+		},
+		{
+			name: "one person without text",
+			lay:  onePersonNoText.Layout(nil),
+		},
+		{
+			name: "empty descendant chart",
+			lay:  (&DescendantChart{}).Layout(nil),
+		},
+		{
+			name: "empty ancestor chart",
+			lay:  (&AncestorChart{}).Layout(nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.lay.Width() <= 0 || tc.lay.Height() <= 0 {
+				t.Errorf("got dimensions %dx%d, wanted positive width and height", tc.lay.Width(), tc.lay.Height())
+			}
+
+			s, err := SVG(tc.lay)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(s, "<svg ") || strings.Contains(s, `width="0"`) || strings.Contains(s, `height="0"`) {
+				t.Errorf("got invalid svg: %s", s)
+			}
+		})
+	}
+
+	s, err := SVGWithOptions(onePerson.Layout(nil), &SVGOptions{Scale: 0.001})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(s, `<svg width="1" height="1"`) {
+		t.Errorf("got %q, wanted dimensions clamped to one pixel", s[:80])
+	}
+}
+
+func TestSVGTextDirection(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"דוד כהן"},
+			Details:  []string{"b. 1900"},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	lay := ch.Layout(opts)
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, "unicode-bidi") {
+		t.Errorf("got direction attributes when no direction was specified")
+	}
+
+	opts.HeadingStyle.Direction = DirectionRTL
+	lay = ch.Layout(opts)
+	s, err = SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := lay.blurbs[1]
+	want := fmt.Sprintf(`<tspan x="%s" dy="%s" text-anchor="end" direction="rtl" unicode-bidi="embed" font-size="%dpx" fill="%s">דוד כהן</tspan>`, length(b.Left()), length(opts.HeadingStyle.LineHeight), opts.HeadingStyle.FontSize, opts.HeadingStyle.Color)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing right to left heading %q", want)
+	}
+	want = fmt.Sprintf(`<tspan x="%s" dy="%s" font-size="%dpx" fill="%s">b. 1900</tspan>`, length(b.Left()), length(opts.DetailStyle.LineHeight), opts.DetailStyle.FontSize, opts.DetailStyle.Color)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing left to right detail %q", want)
+	}
+}
+
+func TestDirectionResolve(t *testing.T) {
+	testCases := []struct {
+		dir  Direction
+		text string
+		want Direction
+	}{
+		{dir: DirectionDefault, text: "דוד", want: DirectionDefault},
+		{dir: DirectionRTL, text: "David", want: DirectionRTL},
+		{dir: DirectionAuto, text: "David", want: DirectionLTR},
+		{dir: DirectionAuto, text: "1900 דוד", want: DirectionRTL},
+		{dir: DirectionAuto, text: "محمد", want: DirectionRTL},
+		{dir: DirectionAuto, text: "1900", want: DirectionLTR},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.dir.resolve(tc.text); got != tc.want {
+			t.Errorf("resolve(%d, %q): got %d, wanted %d", tc.dir, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestSVGGenerationLabels(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	natural := onePersonWithSpouseAndChildren.Layout(opts)
+
+	opts.ShowGenerationLabels = true
+	lay := onePersonWithSpouseAndChildren.Layout(opts)
+
+	gutter := MeasureText("Generation 1", opts.GenerationLabelStyle) + opts.Hspace
+	if got, want := lay.Width(), natural.Width()+gutter; got != want {
+		t.Errorf("got width %d, wanted %d", got, want)
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for row, want := range []string{"Generation 1", "Generation 2"} {
+		b := lay.rows[row][0]
+		label := fmt.Sprintf(`<text x="%s" y="%s" dominant-baseline="middle" text-anchor="start" font-size="%dpx" fill="%s">%s</text>`, length(opts.Margin), length(b.TopPos+b.Height/2), opts.GenerationLabelStyle.FontSize, opts.GenerationLabelStyle.Color, want)
+		if !strings.Contains(s, label) {
+			t.Errorf("missing label %q", label)
+		}
+	}
+
+	for _, b := range lay.blurbs {
+		if b.Left() < opts.Margin+gutter {
+			t.Errorf("blurb %d: got left %d, overlapping labels ending at %d", b.ID, b.Left(), opts.Margin+gutter)
+		}
+	}
+}
+
+func TestSVGFont(t *testing.T) {
+	lay := onePerson.Layout(nil)
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, "<style>") {
+		t.Errorf("got style element when no font was configured")
+	}
+
+	opts := DefaultSVGOptions()
+	opts.FontFamily = "Chart Sans"
+	opts.FontData = []byte("wOF2 font data")
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`@font-face { font-family: "Chart Sans"; src: url(data:font/woff2;base64,` + base64.StdEncoding.EncodeToString(opts.FontData) + `) format("woff2"); }`,
+		`text { font-family: "Chart Sans"; }`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	// names are quoted as CSS strings, keeping characters outside ASCII as they are
+	opts = DefaultSVGOptions()
+	opts.FontFamily = `Société "Grotesk" \ Pro`
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `text { font-family: "Société \"Grotesk\" \\ Pro"; }`; !strings.Contains(s, want) {
+		t.Errorf("missing %s", want)
+	}
+
+	// characters that are markup in XML are escaped so the document remains well formed
+	opts.FontFamily = `Smith & Sons <Serif>`
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Errorf("got invalid XML: %v", err)
+	}
+	if want := `text { font-family: "Smith \26  Sons \3c Serif\3e "; }`; !strings.Contains(s, want) {
+		t.Errorf("missing %s", want)
+	}
+}
+
+func TestSVGDashedConnectors(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Person One"},
+			Families: []*DescendantFamily{
+				{
+					Other: &DescendantPerson{ID: 2, Headings: []string{"Person Two"}},
+					Children: []*DescendantPerson{
+						{ID: 3, Headings: []string{"Person Three"}},
+						{ID: 4, Headings: []string{"Person Four"}, Relationship: AdoptedRelationship},
+						{ID: 5, Headings: []string{"Person Five"}},
+					},
+				},
+			},
+		},
+	}
+
+	lay := ch.Layout(nil)
+	for id, want := range map[int]bool{3: false, 4: true, 5: false} {
+		if got := lay.parentConnectors[id][0].Dashed; got != want {
+			t.Errorf("connector to blurb %d: got dashed %v, wanted %v", id, got, want)
+		}
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Count(s, "stroke-dasharray"); got != 1 {
+		t.Errorf("got %d dashed paths, wanted 1", got)
+	}
+
+	// a family relationship applies to all of its children
+	ch.Root.Families[0].Relationship = FosterRelationship
+	lay = ch.Layout(nil)
+	for _, id := range []int{3, 4, 5} {
+		if !lay.parentConnectors[id][0].Dashed {
+			t.Errorf("connector to blurb %d: got solid, wanted dashed", id)
+		}
+	}
+}
+
+func TestSVGDetailColumns(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Root"},
+			Details:  []string{"Farmer", "Baker", "Leeds", "York"},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	opts.DetailColumns = 2
+	lay := ch.Layout(opts)
+	b := lay.blurbs[1]
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the first line of the second column sits level with the first line of the first column
+	sec := b.DetailTexts
+	y := length(b.TopPos + b.HeadingTexts.Style.LineHeight + sec.Style.LineHeight)
+	for _, tc := range []struct {
+		line string
+		x    Pixel
+	}{
+		{line: "Farmer", x: b.Left()},
+		{line: "Leeds", x: b.Left() + sec.ColumnWidth + sec.ColumnGap},
+	} {
+		want := fmt.Sprintf("<tspan x=\"%s\" y=\"%s\"", length(tc.x), y)
+		idx := strings.Index(s, ">"+tc.line+"</tspan>")
+		if idx < 0 || !strings.Contains(s[strings.LastIndex(s[:idx], "<tspan"):idx], want) {
+			t.Errorf("line %q: wanted tspan starting %s", tc.line, want)
+		}
+	}
+}
+
+func TestSVGFocus(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	opts.FocusID = 3
+	lay := onePersonWithSpouseAndChildren.Layout(opts)
+
+	for id, b := range lay.blurbs {
+		if id <= 0 {
+			continue
+		}
+		want := opts.HeadingStyle
+		if id == opts.FocusID {
+			want = opts.FocusStyle
+		}
+		if b.Focus != (id == opts.FocusID) {
+			t.Errorf("blurb %d: got focus %v", id, b.Focus)
+		}
+		if diff := cmp.Diff(want, b.HeadingTexts.Style); diff != "" {
+			t.Errorf("blurb %d: heading style mismatch (-want +got):\n%s", id, diff)
+		}
+	}
+
+	// the focus person is kept further from their sibling
+	natural := onePersonWithSpouseAndChildren.Layout(nil)
+	gap := func(l *DescendantLayout) Pixel { return l.blurbs[4].Left() - l.blurbs[3].Right() }
+	if got, want := gap(lay), gap(natural)+opts.FocusMargin; got != want {
+		t.Errorf("got gap %d beside focus person, wanted %d", got, want)
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Count(s, `font-weight="bold"`); got != 1 {
+		t.Errorf("got %d bold lines, wanted 1", got)
+	}
+	b := lay.blurbs[3]
+	border := fmt.Sprintf("<rect x=\"%s\" y=\"%s\"", length(b.Left()-4), length(b.TopPos-4))
+	if !strings.Contains(s, border) {
+		t.Errorf("focus border not found")
+	}
+}
+
+func TestSVGComposite(t *testing.T) {
+	anc := exampleAncestorChart.Layout(nil)
+	desc := onePersonWithSpouseAndChildren.Layout(nil)
+
+	items := []PlacedLayout{
+		{Layout: anc},
+		{Layout: desc, OffsetX: anc.Width() + 20, OffsetY: 10},
+	}
+	s, err := SVGComposite(items)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	width, height := anc.Width()+20+desc.Width(), max(anc.Height(), 10+desc.Height())
+	if want := fmt.Sprintf("<svg width=\"%s\" height=\"%s\"", length(width), length(height)); !strings.Contains(s, want) {
+		t.Errorf("wanted drawing to start with %s", want)
+	}
+	if got := strings.Count(s, "<svg "); got != 1 {
+		t.Errorf("got %d svg elements, wanted 1", got)
+	}
+
+	// each layout is drawn in its own group, offset as requested
+	for _, item := range items {
+		group := fmt.Sprintf("<g transform=\"translate(%s,%s)\">", length(item.OffsetX), length(item.OffsetY))
+		start := strings.Index(s, group)
+		if start < 0 {
+			t.Errorf("group %s not found", group)
+			continue
+		}
+		end := start + strings.Index(s[start:], "\n</g>")
+		for _, b := range item.Layout.Blurbs() {
+			if !strings.Contains(s[start:end], ">"+b.HeadingTexts.Lines[0]+"</tspan>") {
+				t.Errorf("group %s: blurb %q not found", group, b.HeadingTexts.Lines[0])
+			}
+		}
+	}
+
+	if _, err := SVGComposite([]PlacedLayout{{Layout: desc, OffsetX: -1}}); err == nil {
+		t.Errorf("got no error for negative offset, wanted error")
+	}
+}
+
+func TestBlurbSVG(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+	b := lay.blurbs[4]
+
+	s, err := BlurbSVG(b, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// sized to the blurb with room for a border on each side
+	if want := fmt.Sprintf("<svg width=\"%s\" height=\"%s\"", length(b.Width+10), length(b.Height+10)); !strings.Contains(s, want) {
+		t.Errorf("wanted drawing to start with %s", want)
+	}
+	if want := fmt.Sprintf("<g transform=\"translate(%s,%s)\">", length(5-b.Left()), length(5-b.TopPos)); !strings.Contains(s, want) {
+		t.Errorf("wanted blurb to be moved to the corner with %s", want)
+	}
+
+	// only the one blurb is drawn
+	if !strings.Contains(s, ">Person Four</tspan>") {
+		t.Errorf("blurb text not found")
+	}
+	if got := strings.Count(s, "<tspan "); got != len(b.HeadingTexts.Lines)+len(b.DetailTexts.Lines) {
+		t.Errorf("got %d lines of text, wanted %d", got, len(b.HeadingTexts.Lines)+len(b.DetailTexts.Lines))
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(s))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("output is not valid xml: %v", err)
+		}
+	}
+}
+
+func TestSVGDNAMarkers(t *testing.T) {
+	in := lines(
+		"1. A. Brown #dna",
+		"  2. B. Brown",
+	)
+	p := &Parser{}
+	ch, err := p.Parse(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	natural := ch.Layout(nil)
+	opts := DefaultLayoutOptions()
+	opts.DNAMarkers = true
+	lay := ch.Layout(opts)
+
+	tested, untested := lay.blurbs[1], lay.blurbs[2]
+	if !tested.DNATested || untested.DNATested {
+		t.Errorf("got DNA tested %v and %v, wanted true and false", tested.DNATested, untested.DNATested)
+	}
+	if tested.Width <= natural.blurbs[1].Width {
+		t.Errorf("got width %d, wanted room for the marker beyond %d", tested.Width, natural.blurbs[1].Width)
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Count(s, "<circle "); got != 1 {
+		t.Errorf("got %d markers, wanted 1", got)
+	}
+	if want := `fill="` + DefaultSVGOptions().DNAMarkerColor + `"/>`; !strings.Contains(s, want) {
+		t.Errorf("missing marker colour %s", want)
+	}
+
+	s, err = SVG(natural)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, "<circle ") {
+		t.Errorf("got marker without the DNAMarkers option")
+	}
+}
+
+func TestSVGDebugComments(t *testing.T) {
+	opts := DefaultLayoutOptions()
+	opts.Debug = true
+	lay := onePersonWithSpouseAndChildren.Layout(opts)
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, b := range lay.Blurbs() {
+		want := fmt.Sprintf("leftpad=%d, noshift=%v, keeptightright=%s, leftneighbour=%s, parent=%s) -->", b.LeftPad, b.NoShift, debugRef(b.KeepTightRight), debugRef(b.LeftNeighbour), debugRef(b.Parent))
+		if !strings.Contains(s, fmt.Sprintf("<!-- blurb %s (id=%d, ", b.HeadingTexts.Lines[0], b.ID)) || !strings.Contains(s, want) {
+			t.Errorf("blurb %d: missing debug comment ending %q", b.ID, want)
+		}
+	}
+
+	// the person is kept close to the marker of their family and the children hang from it
+	for _, want := range []string{"(id=1, ", "keeptightright=-2", "parent=-2"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	if s, _ := SVG(onePersonWithSpouseAndChildren.Layout(nil)); strings.Contains(s, "<!-- blurb") {
+		t.Errorf("got debug comments without the Debug option")
+	}
+}
+
+func TestSVGConnectorLineStyle(t *testing.T) {
+	lay := onePersonWithSpouseAndChildren.Layout(nil)
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "stroke-linecap:butt;stroke-linejoin:miter;"; !strings.Contains(s, want) {
+		t.Errorf("missing default line style %q", want)
+	}
+
+	opts := DefaultSVGOptions()
+	opts.ConnectorLineCap = "round"
+	opts.ConnectorLineJoin = "bevel"
+	s, err = SVGWithOptions(lay, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "stroke-linecap:round;stroke-linejoin:bevel;"
+	if got, paths := strings.Count(s, want), strings.Count(s, "<path "); got != paths || got == 0 {
+		t.Errorf("got %d of %d connectors with line style %q", got, paths, want)
+	}
+
+	opts.ConnectorLineJoin = "wobbly"
+	if _, err := SVGWithOptions(lay, opts); err == nil {
+		t.Errorf("got no error for unsupported line join")
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	in := lines(
+		"1. A. Brown (b. 1819)",
+		"  sp. B. Green",
+		"   2. C. Brown",
+		"   2. D. Brown",
+	)
+
+	s, err := RenderDescendantSVG(context.Background(), strings.NewReader(in), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Fatalf("output is not well formed: %v", err)
+	}
+	for _, want := range []string{"A. Brown", "b. 1819", "B. Green", "C. Brown", "D. Brown"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("descendant chart missing %q", want)
+		}
+	}
+
+	s, err = RenderAncestorSVG(context.Background(), strings.NewReader(in), 3, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"A. Brown", "B. Green", "C. Brown"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("ancestor chart missing %q", want)
+		}
+	}
+	if strings.Contains(s, "D. Brown") {
+		t.Errorf("ancestor chart unexpectedly contains sibling")
+	}
+
+	if _, err := RenderDescendantSVG(context.Background(), strings.NewReader("2. A. Brown\n"), nil); err == nil {
+		t.Errorf("got no error for invalid input")
+	}
+	if _, err := RenderAncestorSVG(context.Background(), strings.NewReader(in), 99, nil); err == nil {
+		t.Errorf("got no error for unknown id")
+	}
+}
+
+func TestSVGFontStyles(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"Anne Brown"},
+			Details:  []string{"d. 1901"},
+		},
+	}
+
+	natural := ch.Layout(nil)
+	opts := DefaultLayoutOptions()
+	opts.HeadingStyle.Bold = true
+	opts.DetailStyle.Italic = true
+	lay := ch.Layout(opts)
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`font-weight="bold">Anne Brown</tspan>`,
+		`font-style="italic">d. 1901</tspan>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if got := strings.Count(s, "font-weight="); got != 1 {
+		t.Errorf("got %d bold elements, wanted 1", got)
+	}
+
+	// bold text is measured as regular unless a width is given for it
+	if got, want := lay.blurbs[1].Width, natural.blurbs[1].Width; got != want {
+		t.Errorf("got width %d, wanted %d", got, want)
+	}
+	opts.HeadingStyle.BoldWidth = 1.5
+	if got, want := MeasureText("Anne Brown", opts.HeadingStyle), Pixel(float64(MeasureText("Anne Brown", DefaultLayoutOptions().HeadingStyle))*1.5+0.5); got != want {
+		t.Errorf("got bold text width %d, wanted %d", got, want)
+	}
+	if got := ch.Layout(opts).blurbs[1].Width; got <= natural.blurbs[1].Width {
+		t.Errorf("got width %d, wanted more than %d for wider bold text", got, natural.blurbs[1].Width)
+	}
+}
+
+func TestSVGWatermark(t *testing.T) {
+	natural := onePersonWithSpouseAndChildren.Layout(nil)
+
+	opts := DefaultLayoutOptions()
+	opts.Watermark = "© 2024 Family Archive"
+	opts.WatermarkStyle.Opacity = 0.2
+	lay := onePersonWithSpouseAndChildren.Layout(opts)
+
+	if lay.Width() != natural.Width() || lay.Height() != natural.Height() {
+		t.Errorf("got size %dx%d, wanted %dx%d as without a watermark", lay.Width(), lay.Height(), natural.Width(), natural.Height())
+	}
+	for id, b := range natural.blurbs {
+		if lay.blurbs[id].Left() != b.Left() || lay.blurbs[id].TopPos != b.TopPos {
+			t.Errorf("blurb %d: got position (%d,%d), wanted (%d,%d)", id, lay.blurbs[id].Left(), lay.blurbs[id].TopPos, b.Left(), b.TopPos)
+		}
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Fatalf("output is not well formed: %v", err)
+	}
+	want := fmt.Sprintf(`fill-opacity="0.2">%s</text>`, opts.Watermark)
+	if !strings.Contains(s, want) {
+		t.Errorf("missing watermark %s", want)
+	}
+	// the watermark is drawn beneath the blurbs
+	if strings.Index(s, opts.Watermark) > strings.Index(s, "Person One") {
+		t.Errorf("watermark drawn after the blurbs")
+	}
+
+	s, err = SVG(natural)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(s, `fill-opacity="`) {
+		t.Errorf("got watermark without one being set")
+	}
+}
+
+func TestSVGBlurbMeta(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Meta:     map[string]string{"url": "https://example.com/?id=1&tree=2", "record": "I1"},
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}, Meta: map[string]string{"record": "I2"}},
+					Children: []*DescendantPerson{{ID: 3, Headings: []string{"C. Brown"}}},
+				},
+			},
+		},
+	}
+
+	lay := ch.Layout(nil)
+	if diff := cmp.Diff(map[string]string{"record": "I2"}, lay.blurbs[2].Meta); diff != "" {
+		t.Errorf("spouse meta mismatch (-want +got):\n%s", diff)
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Fatalf("output is not well formed: %v", err)
+	}
+	for _, want := range []string{
+		`<g data-record="I1" data-url="https://example.com/?id=1&amp;tree=2">`,
+		`<g data-record="I2">`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing group %s", want)
+		}
+	}
+	if got := strings.Count(s, "data-"); got != 3 {
+		t.Errorf("got %d data attributes, wanted 3 as the child has no metadata", got)
+	}
+
+	anc, err := ch.AncestorsOf(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err = SVG(anc.Layout(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(s, `data-record="I1"`) {
+		t.Errorf("ancestor chart missing data attribute for parent")
+	}
+}
+
+func TestSVGBlurbMetaKeys(t *testing.T) {
+	testCases := []struct {
+		meta map[string]string
+		want string
+	}{
+		{meta: map[string]string{"Record ID": "I1"}, want: ` data-record-id="I1"`},
+		{meta: map[string]string{`a"b`: "x", "c<d>": "y"}, want: ` data-a-b="x" data-c-d-="y"`},
+		{meta: map[string]string{"a b": "1", "a-b": "2"}, want: ` data-a-b="1"`},
+		{meta: map[string]string{" ": "blank", "ok": "1"}, want: ` data-ok="1"`},
+	}
+
+	for _, tc := range testCases {
+		ch := &DescendantChart{Root: &DescendantPerson{ID: 1, Headings: []string{"A. Brown"}, Meta: tc.meta}}
+		s, err := SVG(ch.Layout(nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+			t.Errorf("meta %v: output is not well formed: %v", tc.meta, err)
+		}
+		if want := "<g" + tc.want + ">"; !strings.Contains(s, want) {
+			t.Errorf("meta %v: missing %s", tc.meta, want)
+		}
+	}
+}
+
+func TestSVGFamilyLink(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Link:     "javascript:toggle(2)&more",
+					Children: []*DescendantPerson{{ID: 3, Headings: []string{"C. Brown"}}, {ID: 4, Headings: []string{"D. Brown"}}},
+				},
+				{
+					Other:    &DescendantPerson{ID: 5, Headings: []string{"E. White"}},
+					Children: []*DescendantPerson{{ID: 6, Headings: []string{"F. Brown"}}},
+				},
+			},
+		},
+	}
+
+	lay := ch.Layout(nil)
+	if got, want := lay.blurbs[-2].Link, "javascript:toggle(2)&more"; got != want {
+		t.Errorf("got marker link %q, wanted %q", got, want)
+	}
+	if got := lay.blurbs[-5].Link; got != "" {
+		t.Errorf("got marker link %q for a family without one", got)
+	}
+	for id, want := range map[int]string{3: "javascript:toggle(2)&more", 4: "javascript:toggle(2)&more", 6: ""} {
+		for _, c := range lay.parentConnectors[id] {
+			if c.Link != want {
+				t.Errorf("child %d: got connector link %q, wanted %q", id, c.Link, want)
+			}
+		}
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Fatalf("output is not well formed: %v", err)
+	}
+
+	// a single anchor wraps the marker and the lines joining the family's children to it, and
+	// nothing else
+	anchor := `<a href="javascript:toggle(2)&amp;more">`
+	if got, want := strings.Count(s, anchor), 1; got != want {
+		t.Fatalf("got %d anchors, wanted %d", got, want)
+	}
+	start := strings.Index(s, anchor)
+	end := strings.Index(s[start:], "</a>")
+	if end == -1 {
+		t.Fatalf("anchor is not closed")
+	}
+	wrapped := s[start : start+end]
+	if !strings.Contains(wrapped, ">= (1)</tspan>") {
+		t.Errorf("anchor does not wrap the relationship marker")
+	}
+	for _, text := range []string{"A. Brown", "B. Green", "E. White"} {
+		if strings.Contains(wrapped, text) {
+			t.Errorf("anchor wraps blurb %q", text)
+		}
+	}
+	want := make(map[*Connector]bool)
+	for _, id := range []int{3, 4} {
+		for _, c := range lay.parentConnectors[id] {
+			want[c] = true
+		}
+	}
+	if got := strings.Count(wrapped, "<path "); got != len(want) {
+		t.Errorf("got %d connectors in anchor, wanted %d", got, len(want))
+	}
+	for c := range want {
+		if !strings.Contains(wrapped, `d="`+connectorPath(c)+`"`) {
+			t.Errorf("anchor does not wrap the connector to %v", c.Children)
+		}
+	}
+	for _, c := range lay.parentConnectors[6] {
+		if strings.Contains(wrapped, `d="`+connectorPath(c)+`"`) {
+			t.Errorf("anchor wraps the connector to %v from another family", c.Children)
+		}
+	}
+}
+
+func TestSVGFamilyLinkShared(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:       1,
+			Headings: []string{"A. Brown"},
+			Families: []*DescendantFamily{
+				{
+					Other:    &DescendantPerson{ID: 2, Headings: []string{"B. Green"}},
+					Link:     "#",
+					Children: []*DescendantPerson{{ID: 3, Headings: []string{"C. Brown"}}, {ID: 4, Headings: []string{"D. Brown"}}},
+				},
+				{
+					Other:    &DescendantPerson{ID: 5, Headings: []string{"E. White"}},
+					Link:     "#",
+					Children: []*DescendantPerson{{ID: 6, Headings: []string{"F. Brown"}}},
+				},
+			},
+		},
+	}
+
+	lay := ch.Layout(nil)
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Fatalf("output is not well formed: %v", err)
+	}
+
+	// each family has its own anchor wrapping its marker and the lines to its children
+	anchor := `<a href="#">`
+	if got, want := strings.Count(s, anchor), 2; got != want {
+		t.Fatalf("got %d anchors, wanted %d", got, want)
+	}
+	var anchors []string
+	for rest := s; strings.Contains(rest, anchor); {
+		start := strings.Index(rest, anchor)
+		end := strings.Index(rest[start:], "</a>")
+		anchors = append(anchors, rest[start:start+end])
+		rest = rest[start+end:]
+	}
+	for _, f := range []struct {
+		marker   string
+		children []int
+		others   []int
+	}{
+		{marker: ">= (1)</tspan>", children: []int{3, 4}, others: []int{6}},
+		{marker: ">= (2)</tspan>", children: []int{6}, others: []int{3, 4}},
+	} {
+		i := slices.IndexFunc(anchors, func(a string) bool { return strings.Contains(a, f.marker) })
+		if i == -1 {
+			t.Errorf("no anchor wraps the marker %s", f.marker)
+			continue
+		}
+		wrapped := anchors[i]
+		want := 0
+		for _, id := range f.children {
+			for _, c := range lay.parentConnectors[id] {
+				want++
+				if !strings.Contains(wrapped, `d="`+connectorPath(c)+`"`) {
+					t.Errorf("anchor for marker %s does not wrap the connector to %v", f.marker, c.Children)
+				}
+			}
+		}
+		if got := strings.Count(wrapped, "<path "); got != want {
+			t.Errorf("anchor for marker %s: got %d connectors, wanted %d", f.marker, got, want)
+		}
+		for _, id := range f.others {
+			for _, c := range lay.parentConnectors[id] {
+				if strings.Contains(wrapped, `d="`+connectorPath(c)+`"`) {
+					t.Errorf("anchor for marker %s wraps the connector to %v from another family", f.marker, c.Children)
+				}
+			}
+		}
+	}
+
+	// drawing operations group each family separately too
+	groups := 0
+	for _, op := range DrawOps(lay, nil) {
+		if g, ok := op.(DrawGroup); ok && g.Link == "#" {
+			groups++
+		}
+	}
+	if got, want := groups, 2; got != want {
+		t.Errorf("got %d linked groups of drawing operations, wanted %d", got, want)
+	}
+}
+
+func TestSVGDetailStyles(t *testing.T) {
+	ch := &DescendantChart{
+		Root: &DescendantPerson{
+			ID:           1,
+			Headings:     []string{"A. Brown"},
+			Details:      []string{"b. 1820", "occupation: carpenter", "d. 1901"},
+			DetailStyles: []*TextStyle{{Color: "#008000"}, nil, {Color: "#808080", FontSize: 10, LineHeight: 24}},
+		},
+	}
+
+	opts := DefaultLayoutOptions()
+	lay := ch.Layout(opts)
+	b := lay.blurbs[1]
+
+	var colors []string
+	for i := range b.DetailTexts.Lines {
+		colors = append(colors, b.DetailTexts.LineStyle(i).Color)
+	}
+	if diff := cmp.Diff([]string{"#008000", opts.DetailStyle.Color, "#808080"}, colors); diff != "" {
+		t.Errorf("line colours mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := b.DetailTexts.LineStyle(0).FontSize, opts.DetailStyle.FontSize; got != want {
+		t.Errorf("got inherited font size %d, wanted %d", got, want)
+	}
+	if got, want := b.Height, opts.HeadingStyle.LineHeight+3*24; got != want {
+		t.Errorf("got height %d, wanted %d using the tallest line height", got, want)
+	}
+
+	s, err := SVG(lay)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := xml.Unmarshal([]byte(s), new(struct{})); err != nil {
+		t.Fatalf("output is not well formed: %v", err)
+	}
+	for _, want := range []string{
+		fmt.Sprintf(`font-size="%dpx" fill="#008000">b. 1820</tspan>`, opts.DetailStyle.FontSize),
+		fmt.Sprintf(`font-size="%dpx" fill="%s">occupation: carpenter</tspan>`, opts.DetailStyle.FontSize, opts.DetailStyle.Color),
+		`font-size="10px" fill="#808080">d. 1901</tspan>`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}